@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 )
 
@@ -44,6 +45,16 @@ const (
 	Down    OperatingState = "DOWN"
 )
 
+// PropertySource indicates where the actual values of the device properties are collected from
+type PropertySource string
+
+const (
+	// CoreCommandSource reads the device properties actively by sending read commands to the device
+	CoreCommandSource PropertySource = "CoreCommand"
+	// CoreDataSource uses the latest readings that the device has reported to the edge platform
+	CoreDataSource PropertySource = "CoreData"
+)
+
 type ProtocolProperties map[string]string
 
 // DeviceSpec defines the desired state of Device
@@ -72,23 +83,89 @@ type DeviceSpec struct {
 	Managed bool `json:"managed,omitempty"`
 	// NodePool indicates which nodePool the device comes from
 	NodePool string `json:"nodePool,omitempty"`
-	// TODO support the following field
 	// A list of auto-generated events coming from the device
-	// AutoEvents     []AutoEvent                   `json:"autoEvents"`
+	AutoEvents []AutoEvent `json:"autoEvents,omitempty"`
 	// DeviceProperties represents the expected state of the device's properties
 	DeviceProperties map[string]DesiredPropertyState `json:"deviceProperties,omitempty"`
+	// PropertySource indicates where the actual property values of the device are collected from,
+	// the default source of the controller is used if it is not set
+	// +kubebuilder:validation:Enum=CoreCommand;CoreData
+	// +optional
+	PropertySource PropertySource `json:"propertySource,omitempty"`
+	// DeletionPolicy decides whether the device is deleted from the edge platform or left there
+	// when it is deleted from OpenYurt, the default policy of the controller is used if it is not set
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// AutoEvent makes the edge platform read a resource or command of the device periodically
+type AutoEvent struct {
+	// Interval of the readings, e.g. "500ms", "10s"
+	Interval string `json:"interval"`
+	// OnChange indicates that an event is only generated when the reading changes
+	OnChange bool `json:"onChange,omitempty"`
+	// SourceName is the name of the deviceResource or deviceCommand to read
+	SourceName string `json:"sourceName"`
 }
 
 type DesiredPropertyState struct {
 	Name         string `json:"name"`
 	PutURL       string `json:"putURL,omitempty"`
 	DesiredValue string `json:"desiredValue"`
+	// DesiredParameters are the values of the parameters of a deviceCommand operating several deviceResources,
+	// keyed by the names of the deviceResources. They are sent together by a single set command, and the
+	// desiredValue must be empty if they are set
+	// +optional
+	DesiredParameters map[string]string `json:"desiredParameters,omitempty"`
+	// RefreshInterval is how often the actual value is read from the device by the syncer,
+	// the value read last is used in between. The property is read in every round if it is not set
+	// +optional
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+	// MaxStaleness is how old the value read last may be to be compared with the desired value,
+	// the property is read from the device on every reconciliation if it is not set
+	// +optional
+	MaxStaleness *metav1.Duration `json:"maxStaleness,omitempty"`
 }
 
 type ActualPropertyState struct {
 	Name        string `json:"name"`
 	GetURL      string `json:"getURL,omitempty"`
 	ActualValue string `json:"actualValue"`
+	// ActualParameters are the values of the deviceResources read by a deviceCommand operating several deviceResources,
+	// keyed by the names of the deviceResources
+	// +optional
+	ActualParameters map[string]string `json:"actualParameters,omitempty"`
+	// Units of the actual value, taken from the deviceResource in the deviceProfile
+	Units string `json:"units,omitempty"`
+	// ObjectValue is the actual value of an Object property as structured JSON,
+	// the actualValue holds the same value serialized
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	ObjectValue *runtime.RawExtension `json:"objectValue,omitempty"`
+	// Binary describes the actual value of a Binary property, the actualValue is the hash of the payload
+	// +optional
+	Binary *BinaryValue `json:"binary,omitempty"`
+}
+
+// BinaryValue describes the binary value of a property, the payload itself is kept out of the status of the device
+type BinaryValue struct {
+	// MediaType of the payload, e.g. image/jpeg
+	MediaType string `json:"mediaType,omitempty"`
+	// Size of the payload in bytes
+	Size int64 `json:"size"`
+	// Hash is the sha256 digest of the payload, in the form of sha256:<hex>
+	Hash string `json:"hash"`
+	// PayloadRef refers to the ConfigMap holding the payload,
+	// it is not set if the payload is larger than the limit of the controller
+	// +optional
+	PayloadRef *PayloadReference `json:"payloadRef,omitempty"`
+}
+
+// PayloadReference refers to a key in the binaryData of a ConfigMap in the namespace of the device
+type PayloadReference struct {
+	ConfigMapName string `json:"configMapName"`
+	Key           string `json:"key"`
 }
 
 // DeviceStatus defines the observed state of Device
@@ -108,6 +185,8 @@ type DeviceStatus struct {
 	AdminState AdminState `json:"adminState,omitempty"`
 	// Operating state (up/down/unknown)
 	OperatingState OperatingState `json:"operatingState,omitempty"`
+	// AutoEvents that the device is configured with on the edge platform
+	AutoEvents []AutoEvent `json:"autoEvents,omitempty"`
 	// current device state
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
@@ -0,0 +1,138 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+)
+
+const (
+	// DeviceCommandCompletedCondition indicates that the deviceCommand has been run on the edge platform,
+	// the condition is false with the error as its message if the command failed
+	DeviceCommandCompletedCondition clusterv1.ConditionType = "DeviceCommandCompleted"
+
+	// DefaultDeviceCommandTimeoutSeconds is the timeout of a deviceCommand that does not specify one
+	DefaultDeviceCommandTimeoutSeconds int32 = 30
+)
+
+// CommandOperation is the operation a deviceCommand performs on the device
+type CommandOperation string
+
+const (
+	// CommandOperationGet reads the command from the device
+	CommandOperationGet CommandOperation = "get"
+	// CommandOperationSet writes the parameters of the command to the device
+	CommandOperationSet CommandOperation = "set"
+)
+
+// DeviceCommandPhase is the phase of a deviceCommand in its lifecycle
+type DeviceCommandPhase string
+
+const (
+	// DeviceCommandPending means the command has not been sent to the edge platform yet
+	DeviceCommandPending DeviceCommandPhase = "Pending"
+	// DeviceCommandRunning means the command has been sent to the edge platform and is waiting for the result
+	DeviceCommandRunning DeviceCommandPhase = "Running"
+	// DeviceCommandSucceeded means the command has been run on the device successfully
+	DeviceCommandSucceeded DeviceCommandPhase = "Succeeded"
+	// DeviceCommandFailed means the command could not be run on the device
+	DeviceCommandFailed DeviceCommandPhase = "Failed"
+)
+
+// DeviceCommandSpec defines the desired state of DeviceCommand
+type DeviceCommandSpec struct {
+	// DeviceName is the name of the device in the same namespace the command is run on
+	DeviceName string `json:"deviceName"`
+	// Command is the name of the deviceCommand or deviceResource in the profile of the device
+	Command string `json:"command"`
+	// Operation is either get or set
+	// +kubebuilder:validation:Enum=get;set
+	Operation CommandOperation `json:"operation"`
+	// Parameters are the values written to the device by a set command, keyed by the deviceResource names
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// TimeoutSeconds is the time the edge platform has to run the command, 30 seconds by default
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// DeviceCommandStatus defines the observed state of DeviceCommand
+type DeviceCommandStatus struct {
+	// Phase is the phase of the command, a command that reaches Succeeded or Failed is never run again
+	Phase DeviceCommandPhase `json:"phase,omitempty"`
+	// StartTime is the time the command was sent to the edge platform
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the command succeeded or failed
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Readings are the values returned by a get command, keyed by the deviceResource names
+	// +optional
+	Readings map[string]string `json:"readings,omitempty"`
+	// Error is the reason the command failed
+	// +optional
+	Error string `json:"error,omitempty"`
+	// current deviceCommand state
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// IsCompleted reports whether the deviceCommand has succeeded or failed
+func (s *DeviceCommandStatus) IsCompleted() bool {
+	return s.Phase == DeviceCommandSucceeded || s.Phase == DeviceCommandFailed
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=dcmd
+//+kubebuilder:printcolumn:name="DEVICE",type="string",JSONPath=".spec.deviceName",description="The device the command is run on"
+//+kubebuilder:printcolumn:name="COMMAND",type="string",JSONPath=".spec.command",description="The name of the command"
+//+kubebuilder:printcolumn:name="OPERATION",type="string",JSONPath=".spec.operation",description="The operation of the command"
+//+kubebuilder:printcolumn:name="PHASE",type="string",JSONPath=".status.phase",description="The phase of the command"
+//+kubebuilder:printcolumn:name="AGE",type="date",JSONPath=".metadata.creationTimestamp"
+
+// DeviceCommand is the Schema for the devicecommands API
+type DeviceCommand struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DeviceCommandSpec   `json:"spec,omitempty"`
+	Status DeviceCommandStatus `json:"status,omitempty"`
+}
+
+func (dc *DeviceCommand) SetConditions(conditions clusterv1.Conditions) {
+	dc.Status.Conditions = conditions
+}
+
+func (dc *DeviceCommand) GetConditions() clusterv1.Conditions {
+	return dc.Status.Conditions
+}
+
+//+kubebuilder:object:root=true
+
+// DeviceCommandList contains a list of DeviceCommand
+type DeviceCommandList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DeviceCommand `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DeviceCommand{}, &DeviceCommandList{})
+}
@@ -18,10 +18,15 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 )
 
 const (
 	DeviceProfileFinalizer = "v1alpha1.deviceProfile.finalizer"
+	// DeviceProfileSyncedCondition indicates that the deviceProfile exists in both OpenYurt and edge platform
+	DeviceProfileSyncedCondition clusterv1.ConditionType = "DeviceProfileSynced"
+	// DeviceProfileUpdatedCondition indicates that the latest changes of the deviceProfile have been applied to the edge platform
+	DeviceProfileUpdatedCondition clusterv1.ConditionType = "DeviceProfileUpdated"
 )
 
 type DeviceResource struct {
@@ -49,7 +54,9 @@ type ResourceProperties struct {
 	ValueType    string `json:"valueType,omitempty"`
 }
 
-type DeviceCommand struct {
+// DeviceProfileCommand is a command of the deviceProfile operating its deviceResources. It was named DeviceCommand,
+// which now names the kind of the commands sent to the devices, so the old name can't be kept as an alias.
+type DeviceProfileCommand struct {
 	Name               string              `json:"name"`
 	IsHidden           bool                `json:"isHidden"`
 	ReadWrite          string              `json:"readWrite"`
@@ -72,15 +79,23 @@ type DeviceProfileSpec struct {
 	// Model of the device
 	Model string `json:"model,omitempty"`
 	// Labels used to search for groups of profiles on EdgeX Foundry
-	Labels          []string         `json:"labels,omitempty"`
-	DeviceResources []DeviceResource `json:"deviceResources,omitempty"`
-	DeviceCommands  []DeviceCommand  `json:"deviceCommands,omitempty"`
+	Labels          []string               `json:"labels,omitempty"`
+	DeviceResources []DeviceResource       `json:"deviceResources,omitempty"`
+	DeviceCommands  []DeviceProfileCommand `json:"deviceCommands,omitempty"`
+	// DeletionPolicy decides whether the deviceProfile is deleted from the edge platform or left there
+	// when it is deleted from OpenYurt, the default policy of the controller is used if it is not set
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 // DeviceProfileStatus defines the observed state of DeviceProfile
 type DeviceProfileStatus struct {
 	EdgeId string `json:"id,omitempty"`
 	Synced bool   `json:"synced,omitempty"`
+	// current deviceProfile state
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -102,6 +117,14 @@ type DeviceProfile struct {
 	Status DeviceProfileStatus `json:"status,omitempty"`
 }
 
+func (dp *DeviceProfile) SetConditions(conditions clusterv1.Conditions) {
+	dp.Status.Conditions = conditions
+}
+
+func (dp *DeviceProfile) GetConditions() clusterv1.Conditions {
+	return dp.Status.Conditions
+}
+
 //+kubebuilder:object:root=true
 
 // DeviceProfileList contains a list of DeviceProfile
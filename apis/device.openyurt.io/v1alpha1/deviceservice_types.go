@@ -44,6 +44,11 @@ type DeviceServiceSpec struct {
 	Managed bool `json:"managed,omitempty"`
 	// NodePool indicates which nodePool the deviceService comes from
 	NodePool string `json:"nodePool,omitempty"`
+	// DeletionPolicy decides whether the deviceService is deleted from the edge platform or left there
+	// when it is deleted from OpenYurt, the default policy of the controller is used if it is not set
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 // DeviceServiceStatus defines the observed state of DeviceService
@@ -16,6 +16,8 @@ limitations under the License.
 
 package v1alpha1
 
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
 type EdgeXObject interface {
 	IsAddedToEdgeX() bool
 }
@@ -27,3 +29,18 @@ func (dp *DeviceProfile) IsAddedToEdgeX() bool {
 func (d *Device) IsAddedToEdgeX() bool {
 	return d.Status.Synced
 }
+
+// EdgeAvailableCondition indicates that the edge platform could be reached when the object was last reconciled,
+// it is false while the edge platform is unavailable or its circuit breaker is open
+const EdgeAvailableCondition clusterv1.ConditionType = "EdgeAvailable"
+
+// DeletionPolicy decides what happens to the object on the edge platform when its OpenYurt object is deleted
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the object from the edge platform as well
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves the object on the edge platform and labels it as orphaned,
+	// so that it is not imported into OpenYurt again by the syncers
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
@@ -22,6 +22,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1alpha4"
 )
@@ -29,6 +30,23 @@ import (
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ActualPropertyState) DeepCopyInto(out *ActualPropertyState) {
 	*out = *in
+	if in.ActualParameters != nil {
+		in, out := &in.ActualParameters, &out.ActualParameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ObjectValue != nil {
+		in, out := &in.ObjectValue, &out.ObjectValue
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Binary != nil {
+		in, out := &in.Binary, &out.Binary
+		*out = new(BinaryValue)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ActualPropertyState.
@@ -41,9 +59,61 @@ func (in *ActualPropertyState) DeepCopy() *ActualPropertyState {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoEvent) DeepCopyInto(out *AutoEvent) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoEvent.
+func (in *AutoEvent) DeepCopy() *AutoEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BinaryValue) DeepCopyInto(out *BinaryValue) {
+	*out = *in
+	if in.PayloadRef != nil {
+		in, out := &in.PayloadRef, &out.PayloadRef
+		*out = new(PayloadReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BinaryValue.
+func (in *BinaryValue) DeepCopy() *BinaryValue {
+	if in == nil {
+		return nil
+	}
+	out := new(BinaryValue)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DesiredPropertyState) DeepCopyInto(out *DesiredPropertyState) {
 	*out = *in
+	if in.DesiredParameters != nil {
+		in, out := &in.DesiredParameters, &out.DesiredParameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.RefreshInterval != nil {
+		in, out := &in.RefreshInterval, &out.RefreshInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxStaleness != nil {
+		in, out := &in.MaxStaleness, &out.MaxStaleness
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DesiredPropertyState.
@@ -86,21 +156,122 @@ func (in *Device) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceCommand) DeepCopyInto(out *DeviceCommand) {
 	*out = *in
-	if in.ResourceOperations != nil {
-		in, out := &in.ResourceOperations, &out.ResourceOperations
-		*out = make([]ResourceOperation, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceCommand.
+func (in *DeviceCommand) DeepCopy() *DeviceCommand {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceCommand)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DeviceCommand) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceCommandList) DeepCopyInto(out *DeviceCommandList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DeviceCommand, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceCommand.
-func (in *DeviceCommand) DeepCopy() *DeviceCommand {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceCommandList.
+func (in *DeviceCommandList) DeepCopy() *DeviceCommandList {
 	if in == nil {
 		return nil
 	}
-	out := new(DeviceCommand)
+	out := new(DeviceCommandList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DeviceCommandList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceCommandSpec) DeepCopyInto(out *DeviceCommandSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceCommandSpec.
+func (in *DeviceCommandSpec) DeepCopy() *DeviceCommandSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceCommandSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceCommandStatus) DeepCopyInto(out *DeviceCommandStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Readings != nil {
+		in, out := &in.Readings, &out.Readings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1alpha4.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceCommandStatus.
+func (in *DeviceCommandStatus) DeepCopy() *DeviceCommandStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceCommandStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -143,7 +314,7 @@ func (in *DeviceProfile) DeepCopyInto(out *DeviceProfile) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceProfile.
@@ -164,6 +335,28 @@ func (in *DeviceProfile) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeviceProfileCommand) DeepCopyInto(out *DeviceProfileCommand) {
+	*out = *in
+	if in.ResourceOperations != nil {
+		in, out := &in.ResourceOperations, &out.ResourceOperations
+		*out = make([]ResourceOperation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceProfileCommand.
+func (in *DeviceProfileCommand) DeepCopy() *DeviceProfileCommand {
+	if in == nil {
+		return nil
+	}
+	out := new(DeviceProfileCommand)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceProfileList) DeepCopyInto(out *DeviceProfileList) {
 	*out = *in
@@ -213,7 +406,7 @@ func (in *DeviceProfileSpec) DeepCopyInto(out *DeviceProfileSpec) {
 	}
 	if in.DeviceCommands != nil {
 		in, out := &in.DeviceCommands, &out.DeviceCommands
-		*out = make([]DeviceCommand, len(*in))
+		*out = make([]DeviceProfileCommand, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -233,6 +426,13 @@ func (in *DeviceProfileSpec) DeepCopy() *DeviceProfileSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeviceProfileStatus) DeepCopyInto(out *DeviceProfileStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1alpha4.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeviceProfileStatus.
@@ -394,11 +594,16 @@ func (in *DeviceSpec) DeepCopyInto(out *DeviceSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AutoEvents != nil {
+		in, out := &in.AutoEvents, &out.AutoEvents
+		*out = make([]AutoEvent, len(*in))
+		copy(*out, *in)
+	}
 	if in.DeviceProperties != nil {
 		in, out := &in.DeviceProperties, &out.DeviceProperties
 		*out = make(map[string]DesiredPropertyState, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 }
@@ -420,9 +625,14 @@ func (in *DeviceStatus) DeepCopyInto(out *DeviceStatus) {
 		in, out := &in.DeviceProperties, &out.DeviceProperties
 		*out = make(map[string]ActualPropertyState, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.AutoEvents != nil {
+		in, out := &in.AutoEvents, &out.AutoEvents
+		*out = make([]AutoEvent, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make(v1alpha4.Conditions, len(*in))
@@ -463,6 +673,21 @@ func (in ProtocolProperties) DeepCopy() ProtocolProperties {
 	return *out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PayloadReference) DeepCopyInto(out *PayloadReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PayloadReference.
+func (in *PayloadReference) DeepCopy() *PayloadReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PayloadReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceOperation) DeepCopyInto(out *ResourceOperation) {
 	*out = *in
@@ -20,9 +20,15 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/openyurtio/device-controller/pkg/clients"
+	// register the compiled-in edge platform drivers
+	_ "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
+	_ "github.com/openyurtio/device-controller/pkg/clients/fake"
 	"github.com/openyurtio/device-controller/pkg/controllers"
 	"github.com/openyurtio/device-controller/pkg/controllers/util"
+	"github.com/openyurtio/device-controller/pkg/webhooks"
 
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 
@@ -41,6 +47,9 @@ import (
 	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
 )
 
+// controllerName is the component of the events recorded by yurt-device-controller
+const controllerName = "yurt-device-controller"
+
 var (
 	scheme   = runtime.NewScheme()
 	setupLog = ctrl.Log.WithName("setup")
@@ -84,6 +93,8 @@ func Run(opts *options.YurtDeviceControllerOptions, stopCh <-chan struct{}) {
 		LeaderElection:         opts.EnableLeaderElection,
 		LeaderElectionID:       "yurt-device-controller",
 		Namespace:              opts.Namespace,
+		Port:                   opts.WebhookPort,
+		CertDir:                opts.WebhookCertDir,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -113,15 +124,40 @@ func Run(opts *options.YurtDeviceControllerOptions, stopCh <-chan struct{}) {
 		}
 	}
 
+	// open the driver of the edge platform shared by the reconcilers and syncers
+	driverCfg, err := newDriverConfig(mgr, opts)
+	if err != nil {
+		setupLog.Error(err, "invalid edge platform settings")
+		os.Exit(1)
+	}
+	driver, err := clients.OpenDriver(opts.EdgePlatform, driverCfg)
+	if err != nil {
+		setupLog.Error(err, "unable to open the edge platform driver", "platform", opts.EdgePlatform)
+		os.Exit(1)
+	}
+
+	// subscribe to the changes on the edge platform once for all the syncers
+	var bus *controllers.EdgeEventBus
+	if opts.MessageBusAddr != "" {
+		eventCli, err := driver.NewEventClient()
+		if err != nil {
+			setupLog.Error(err, "unable to create the edge event client")
+			os.Exit(1)
+		}
+		bus = controllers.NewEdgeEventBus(eventCli)
+		mgr.Add(bus)
+	}
+
 	// setup the DeviceProfile Reconciler and Syncer
 	if err = (&controllers.DeviceProfileReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr, opts); err != nil {
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(controllerName),
+	}).SetupWithManager(mgr, driver, opts); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DeviceProfile")
 		os.Exit(1)
 	}
-	dfs, err := controllers.NewDeviceProfileSyncer(mgr.GetClient(), opts)
+	dfs, err := controllers.NewDeviceProfileSyncer(mgr.GetClient(), mgr.GetEventRecorderFor(controllerName), driver, bus, opts)
 	if err != nil {
 		setupLog.Error(err, "unable to create syncer", "syncer", "DeviceProfile")
 		os.Exit(1)
@@ -130,13 +166,14 @@ func Run(opts *options.YurtDeviceControllerOptions, stopCh <-chan struct{}) {
 
 	// setup the Device Reconciler and Syncer
 	if err = (&controllers.DeviceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr, opts); err != nil {
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(controllerName),
+	}).SetupWithManager(mgr, driver, opts); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Device")
 		os.Exit(1)
 	}
-	ds, err := controllers.NewDeviceSyncer(mgr.GetClient(), opts)
+	ds, err := controllers.NewDeviceSyncer(mgr.GetClient(), mgr.GetEventRecorderFor(controllerName), driver, bus, opts)
 	if err != nil {
 		setupLog.Error(err, "unable to create syncer", "controller", "Device")
 		os.Exit(1)
@@ -145,20 +182,39 @@ func Run(opts *options.YurtDeviceControllerOptions, stopCh <-chan struct{}) {
 
 	// setup the DeviceService Reconciler and Syncer
 	if err = (&controllers.DeviceServiceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr, opts); err != nil {
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(controllerName),
+	}).SetupWithManager(mgr, driver, opts); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DeviceService")
 		os.Exit(1)
 	}
-	dss, err := controllers.NewDeviceServiceSyncer(mgr.GetClient(), opts)
+	dss, err := controllers.NewDeviceServiceSyncer(mgr.GetClient(), mgr.GetEventRecorderFor(controllerName), driver, bus, opts)
 	if err != nil {
 		setupLog.Error(err, "unable to create syncer", "syncer", "DeviceService")
 		os.Exit(1)
 	}
 	mgr.Add(dss.NewDeviceServiceSyncerRunnable())
+
+	// setup the DeviceCommand Reconciler
+	if err = (&controllers.DeviceCommandReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor(controllerName),
+	}).SetupWithManager(mgr, driver, opts); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "DeviceCommand")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
+	// setup the admission webhooks
+	if opts.EnableWebhooks {
+		if err = webhooks.SetupWebhooksWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhooks")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("health", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -185,3 +241,34 @@ func preflightCheck(mgr ctrl.Manager, opts *options.YurtDeviceControllerOptions)
 	}
 	return nil
 }
+
+// newDriverConfig maps the options onto the config the edge platform driver is opened with,
+// the token secret is read with the API reader of the manager since only the single secret may be got
+func newDriverConfig(mgr ctrl.Manager, opts *options.YurtDeviceControllerOptions) (clients.DriverConfig, error) {
+	cfg := clients.DriverConfig{
+		CoreDataAddr:        opts.CoreDataAddr,
+		CoreMetadataAddr:    opts.CoreMetadataAddr,
+		CoreCommandAddr:     opts.CoreCommandAddr,
+		ListPageSize:        int(opts.EdgeListPageSize),
+		MessageBusAddr:      opts.MessageBusAddr,
+		MessageBusBaseTopic: opts.MessageBusBaseTopic,
+		RequestRetries:      int(opts.EdgeRequestRetries),
+		BreakerThreshold:    int(opts.BreakerThreshold),
+		BreakerOpenTimeout:  time.Duration(opts.BreakerOpenTimeout) * time.Second,
+		TLS:                 opts.EdgeTLS,
+		CAFile:              opts.EdgeCAFile,
+		CertFile:            opts.EdgeCertFile,
+		KeyFile:             opts.EdgeKeyFile,
+		TokenFile:           opts.EdgeTokenFile,
+		TokenSecretKey:      opts.EdgeTokenSecretKey,
+		TokenRefreshPeriod:  time.Duration(opts.EdgeTokenRefresh) * time.Second,
+	}
+	if opts.EdgeTokenFile == "" && opts.EdgeTokenSecret != "" {
+		var err error
+		if cfg.TokenSecretNamespace, cfg.TokenSecretName, err = options.SplitTokenSecret(opts); err != nil {
+			return cfg, err
+		}
+		cfg.SecretReader = mgr.GetAPIReader()
+	}
+	return cfg, nil
+}
@@ -19,6 +19,10 @@ package options
 import (
 	"fmt"
 	"net"
+	"net/url"
+	"strings"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 
 	"github.com/spf13/pflag"
 )
@@ -34,6 +38,31 @@ type YurtDeviceControllerOptions struct {
 	CoreMetadataAddr     string
 	CoreCommandAddr      string
 	EdgeSyncPeriod       uint
+	PropertySource       string
+	EdgePlatform         string
+	EnableWebhooks       bool
+	WebhookPort          int
+	WebhookCertDir       string
+	MessageBusAddr       string
+	MessageBusBaseTopic  string
+	EdgeResyncPeriod     uint
+	PropertyWorkers      int
+	PropertyTimeout      uint
+	SyncRoundBudget      uint
+	EdgeRequestRetries   uint
+	BreakerThreshold     uint
+	BreakerOpenTimeout   uint
+	EdgeTLS              bool
+	EdgeCAFile           string
+	EdgeCertFile         string
+	EdgeKeyFile          string
+	EdgeTokenFile        string
+	EdgeTokenSecret      string
+	EdgeTokenSecretKey   string
+	EdgeTokenRefresh     uint
+	EdgeListPageSize     uint
+	PayloadMaxSize       uint
+	DeletionPolicy       string
 }
 
 func NewYurtDeviceControllerOptions() *YurtDeviceControllerOptions {
@@ -47,6 +76,31 @@ func NewYurtDeviceControllerOptions() *YurtDeviceControllerOptions {
 		CoreMetadataAddr:     "edgex-core-metadata:59881",
 		CoreCommandAddr:      "edgex-core-command:59882",
 		EdgeSyncPeriod:       5,
+		PropertySource:       string(devicev1alpha1.CoreCommandSource),
+		EdgePlatform:         "edgex-foundry",
+		EnableWebhooks:       false,
+		WebhookPort:          9443,
+		WebhookCertDir:       "",
+		MessageBusAddr:       "",
+		MessageBusBaseTopic:  "edgex",
+		EdgeResyncPeriod:     300,
+		PropertyWorkers:      10,
+		PropertyTimeout:      3,
+		SyncRoundBudget:      0,
+		EdgeRequestRetries:   3,
+		BreakerThreshold:     5,
+		BreakerOpenTimeout:   30,
+		EdgeTLS:              false,
+		EdgeCAFile:           "",
+		EdgeCertFile:         "",
+		EdgeKeyFile:          "",
+		EdgeTokenFile:        "",
+		EdgeTokenSecret:      "",
+		EdgeTokenSecretKey:   "token",
+		EdgeTokenRefresh:     60,
+		EdgeListPageSize:     500,
+		PayloadMaxSize:       256 * 1024,
+		DeletionPolicy:       string(devicev1alpha1.DeletionPolicyDelete),
 	}
 }
 
@@ -54,6 +108,39 @@ func ValidateOptions(options *YurtDeviceControllerOptions) error {
 	if err := ValidateEdgePlatformAddress(options); err != nil {
 		return err
 	}
+	if err := ValidatePropertySource(options); err != nil {
+		return err
+	}
+	if err := ValidateDeletionPolicy(options); err != nil {
+		return err
+	}
+	if options.EdgePlatform == "" {
+		return fmt.Errorf("edge platform should not be empty")
+	}
+	if options.EnableWebhooks && (options.WebhookPort <= 0 || options.WebhookPort > 65535) {
+		return fmt.Errorf("invalid webhook port: %d", options.WebhookPort)
+	}
+	if err := ValidateMessageBus(options); err != nil {
+		return err
+	}
+	if options.PropertyWorkers <= 0 {
+		return fmt.Errorf("property workers should be greater than 0")
+	}
+	if options.PropertyTimeout == 0 {
+		return fmt.Errorf("property timeout should be greater than 0")
+	}
+	if options.BreakerThreshold > 0 && options.BreakerOpenTimeout == 0 {
+		return fmt.Errorf("circuit breaker open timeout should be greater than 0")
+	}
+	if err := ValidateEdgeSecurity(options); err != nil {
+		return err
+	}
+	if options.EdgeListPageSize == 0 {
+		return fmt.Errorf("edge list page size should be greater than 0")
+	}
+	if options.PayloadMaxSize > 1024*1024 {
+		return fmt.Errorf("binary payload max size should not exceed 1MiB, the size limit of a ConfigMap")
+	}
 	return nil
 }
 
@@ -67,16 +154,119 @@ func (o *YurtDeviceControllerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.CoreMetadataAddr, "core-metadata-address", "edgex-core-metadata:59881", "The address of edge core-metadata service.")
 	fs.StringVar(&o.CoreCommandAddr, "core-command-address", "edgex-core-command:59882", "The address of edge core-command service.")
 	fs.UintVar(&o.EdgeSyncPeriod, "edge-sync-period", 5, "The period of the device management platform synchronizing the device status to the cloud.(in seconds,not less than 5 seconds)")
+	fs.StringVar(&o.PropertySource, "property-source", o.PropertySource, "The default source of the actual device property values, CoreCommand reads the properties from devices actively, CoreData uses the latest readings reported by devices.")
+	fs.StringVar(&o.EdgePlatform, "edge-platform", o.EdgePlatform, "The edge platform managing the devices, it selects the driver used to talk to the platform.")
+	fs.BoolVar(&o.EnableWebhooks, "enable-webhooks", o.EnableWebhooks, "Enable the admission webhooks defaulting and validating the devices, deviceProfiles and deviceServices.")
+	fs.IntVar(&o.WebhookPort, "webhook-port", o.WebhookPort, "The port the webhook server serves at.")
+	fs.StringVar(&o.WebhookCertDir, "webhook-cert-dir", o.WebhookCertDir, "The directory containing the serving certificate tls.crt and key tls.key of the webhook server, the default directory of controller-runtime is used if it is empty.")
+	fs.StringVar(&o.MessageBusAddr, "message-bus-address", o.MessageBusAddr, "The address of the MQTT message bus of the edge platform, e.g. tcp://edgex-mqtt-broker:1883. If it is set, the changes on the edge platform are synchronized as soon as they are published, and a full synchronization only runs every edge-resync-period.")
+	fs.StringVar(&o.MessageBusBaseTopic, "message-bus-base-topic", o.MessageBusBaseTopic, "The base topic the edge platform publishes its system events and readings under.")
+	fs.UintVar(&o.EdgeResyncPeriod, "edge-resync-period", o.EdgeResyncPeriod, "The period of the full synchronization when the message bus is used.(in seconds)")
+	fs.IntVar(&o.PropertyWorkers, "property-workers", o.PropertyWorkers, "The number of devices whose properties are refreshed concurrently in a round of synchronization.")
+	fs.UintVar(&o.PropertyTimeout, "property-timeout", o.PropertyTimeout, "The deadline of refreshing the properties of a device.(in seconds)")
+	fs.UintVar(&o.SyncRoundBudget, "sync-round-budget", o.SyncRoundBudget, "The time a round of synchronization may spend on refreshing the device properties, the devices not refreshed in time keep their values and are refreshed first in the next round. It is the edge-sync-period if 0.(in seconds)")
+	fs.UintVar(&o.EdgeRequestRetries, "edge-request-retries", o.EdgeRequestRetries, "The number of times a request to the edge platform that failed for a transient reason is sent again, with exponential backoff and jitter.")
+	fs.UintVar(&o.BreakerThreshold, "circuit-breaker-threshold", o.BreakerThreshold, "The number of consecutive failures of a service of the edge platform that opens its circuit breaker, the circuit breakers are disabled if 0.")
+	fs.UintVar(&o.BreakerOpenTimeout, "circuit-breaker-open-timeout", o.BreakerOpenTimeout, "How long an open circuit breaker refuses the requests before a probe request is let through.(in seconds)")
+	fs.BoolVar(&o.EdgeTLS, "edge-tls", o.EdgeTLS, "Use HTTPS to talk to the edge platform, e.g. EdgeX running in secure mode behind its API gateway.")
+	fs.StringVar(&o.EdgeCAFile, "edge-ca-file", o.EdgeCAFile, "The CA bundle verifying the certificates of the edge platform, the system roots are used if it is empty.")
+	fs.StringVar(&o.EdgeCertFile, "edge-cert-file", o.EdgeCertFile, "The optional client certificate presented to the edge platform.")
+	fs.StringVar(&o.EdgeKeyFile, "edge-key-file", o.EdgeKeyFile, "The key of the client certificate presented to the edge platform.")
+	fs.StringVar(&o.EdgeTokenFile, "edge-token-file", o.EdgeTokenFile, "The file holding the JWT or bearer token sent to the edge platform, e.g. a mounted secret.")
+	fs.StringVar(&o.EdgeTokenSecret, "edge-token-secret", o.EdgeTokenSecret, "The secret holding the JWT or bearer token sent to the edge platform, in the form of [namespace/]name, the namespace defaults to the namespace option.")
+	fs.StringVar(&o.EdgeTokenSecretKey, "edge-token-secret-key", o.EdgeTokenSecretKey, "The key of the token in the edge-token-secret.")
+	fs.UintVar(&o.EdgeTokenRefresh, "edge-token-refresh-period", o.EdgeTokenRefresh, "How long a token is used before it is loaded again from the file or secret, so that the rotated tokens are picked up, 0 to only load it again once it is rejected.(in seconds)")
+	fs.UintVar(&o.EdgeListPageSize, "edge-list-page-size", o.EdgeListPageSize, "The number of objects fetched per request when listing the objects on the edge platform, it should not exceed the MaxResultCount of EdgeX.")
+	fs.UintVar(&o.PayloadMaxSize, "binary-payload-max-size", o.PayloadMaxSize, "The size limit of the payload of a binary property stored in the ConfigMap of the device, the larger payloads are only reported by their sizes and hashes, no payload is stored if 0.(in bytes)")
+	fs.StringVar(&o.DeletionPolicy, "default-deletion-policy", o.DeletionPolicy, "The default deletion policy of the devices, deviceProfiles and deviceServices, Delete deletes the objects from the edge platform together with the OpenYurt objects, Orphan leaves them on the edge platform.")
 }
 
 func ValidateEdgePlatformAddress(options *YurtDeviceControllerOptions) error {
 	addrs := []string{options.CoreDataAddr, options.CoreMetadataAddr, options.CoreCommandAddr}
 	for _, addr := range addrs {
 		if addr != "" {
-			if _, _, err := net.SplitHostPort(addr); err != nil {
+			// the address may have a path prefix, e.g. the route of the service on the API gateway of EdgeX
+			hostPort := strings.SplitN(addr, "/", 2)[0]
+			if _, _, err := net.SplitHostPort(hostPort); err != nil {
 				return fmt.Errorf("invalid address: %s", err)
 			}
 		}
 	}
 	return nil
 }
+
+func ValidatePropertySource(options *YurtDeviceControllerOptions) error {
+	switch devicev1alpha1.PropertySource(options.PropertySource) {
+	case devicev1alpha1.CoreCommandSource, devicev1alpha1.CoreDataSource:
+		return nil
+	}
+	return fmt.Errorf("invalid property source: %s, must be %s or %s",
+		options.PropertySource, devicev1alpha1.CoreCommandSource, devicev1alpha1.CoreDataSource)
+}
+
+func ValidateDeletionPolicy(options *YurtDeviceControllerOptions) error {
+	switch devicev1alpha1.DeletionPolicy(options.DeletionPolicy) {
+	case devicev1alpha1.DeletionPolicyDelete, devicev1alpha1.DeletionPolicyOrphan:
+		return nil
+	}
+	return fmt.Errorf("invalid deletion policy: %s, must be %s or %s",
+		options.DeletionPolicy, devicev1alpha1.DeletionPolicyDelete, devicev1alpha1.DeletionPolicyOrphan)
+}
+
+func ValidateMessageBus(options *YurtDeviceControllerOptions) error {
+	if options.MessageBusAddr == "" {
+		return nil
+	}
+	u, err := url.Parse(options.MessageBusAddr)
+	if err != nil {
+		return fmt.Errorf("invalid message bus address: %s", err)
+	}
+	switch u.Scheme {
+	case "tcp", "ssl", "ws", "wss":
+	default:
+		return fmt.Errorf("invalid message bus address: %s, the scheme must be tcp, ssl, ws or wss", options.MessageBusAddr)
+	}
+	if options.MessageBusBaseTopic == "" {
+		return fmt.Errorf("message bus base topic should not be empty")
+	}
+	if options.EdgeResyncPeriod < options.EdgeSyncPeriod {
+		return fmt.Errorf("edge resync period %d should not be less than the edge sync period %d", options.EdgeResyncPeriod, options.EdgeSyncPeriod)
+	}
+	return nil
+}
+
+func ValidateEdgeSecurity(options *YurtDeviceControllerOptions) error {
+	if !options.EdgeTLS && (options.EdgeCAFile != "" || options.EdgeCertFile != "" || options.EdgeKeyFile != "") {
+		return fmt.Errorf("edge CA and client certificate require edge TLS to be enabled")
+	}
+	if !options.EdgeTLS && (options.EdgeTokenFile != "" || options.EdgeTokenSecret != "") {
+		return fmt.Errorf("edge token requires edge TLS to be enabled, it is never sent in cleartext")
+	}
+	if (options.EdgeCertFile == "") != (options.EdgeKeyFile == "") {
+		return fmt.Errorf("edge client certificate and key should be set together")
+	}
+	if options.EdgeTokenFile != "" && options.EdgeTokenSecret != "" {
+		return fmt.Errorf("edge token file and edge token secret should not be set together")
+	}
+	if options.EdgeTokenSecret != "" {
+		if _, _, err := SplitTokenSecret(options); err != nil {
+			return err
+		}
+		if options.EdgeTokenSecretKey == "" {
+			return fmt.Errorf("edge token secret key should not be empty")
+		}
+	}
+	return nil
+}
+
+// SplitTokenSecret returns the namespace and name of the edge token secret
+func SplitTokenSecret(options *YurtDeviceControllerOptions) (string, string, error) {
+	parts := strings.Split(options.EdgeTokenSecret, "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return options.Namespace, parts[0], nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	}
+	return "", "", fmt.Errorf("invalid edge token secret: %s, must be [namespace/]name", options.EdgeTokenSecret)
+}
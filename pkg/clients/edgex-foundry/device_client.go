@@ -19,11 +19,11 @@ package edgex_foundry
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
@@ -33,21 +33,47 @@ import (
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/common"
 	edgex_resp "github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/responses"
 	"github.com/go-resty/resty/v2"
-	"golang.org/x/net/publicsuffix"
 	"k8s.io/klog/v2"
 )
 
+// LatestEventsLimit is the number of the latest events fetched from core-data to resolve the device property values
+const LatestEventsLimit = 100
+
+// ProfileCommandsTTL is how long the commands of a deviceProfile listed from core-command are reused for its devices,
+// so that the changed commands of the deviceProfile are picked up
+const ProfileCommandsTTL = time.Minute
+
 type EdgexDeviceClient struct {
 	*resty.Client
+	// scheme is the scheme of the URLs of EdgeX, https if the client connects over TLS
+	scheme          string
 	CoreMetaAddr    string
 	CoreCommandAddr string
+	CoreDataAddr    string
+	// PageSize is the number of devices fetched per request by List and ListPages
+	PageSize int
+	// Cache keeps the property values read from the devices, the properties are read from the devices
+	// again once they are older than their refresh interval or max staleness
+	Cache *clients.PropertyCache
+	// commands caches the commands of the deviceProfiles resolving the latest readings
+	commands *profileCommandCache
 }
 
-func NewEdgexDeviceClient(coreMetaAddr, coreCommandAddr string) *EdgexDeviceClient {
+func NewEdgexDeviceClient(coreMetaAddr, coreCommandAddr, coreDataAddr string) *EdgexDeviceClient {
+	return newEdgexDeviceClient(plainConnection, coreMetaAddr, coreCommandAddr, coreDataAddr)
+}
+
+// newEdgexDeviceClient creates the client connecting to EdgeX with conn
+func newEdgexDeviceClient(conn *connection, coreMetaAddr, coreCommandAddr, coreDataAddr string) *EdgexDeviceClient {
 	return &EdgexDeviceClient{
-		Client:          resty.New(),
+		scheme:          conn.scheme,
+		Client:          newRestyClient(conn),
 		CoreMetaAddr:    coreMetaAddr,
 		CoreCommandAddr: coreCommandAddr,
+		CoreDataAddr:    coreDataAddr,
+		PageSize:        DefaultListPageSize,
+		Cache:           clients.NewPropertyCache(),
+		commands:        &profileCommandCache{entries: map[string]profileCommands{}},
 	}
 }
 
@@ -60,13 +86,15 @@ func (efc *EdgexDeviceClient) Create(ctx context.Context, device *devicev1alpha1
 	if err != nil {
 		return nil, err
 	}
-	postPath := fmt.Sprintf("http://%s%s", efc.CoreMetaAddr, DevicePath)
-	resp, err := efc.R().
+	postPath := fmt.Sprintf("%s://%s%s", efc.scheme, efc.CoreMetaAddr, DevicePath)
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	resp, err := request.
 		SetBody(reqBody).Post(postPath)
 	if err != nil {
 		return nil, err
 	} else if resp.StatusCode() != http.StatusMultiStatus {
-		return nil, fmt.Errorf("create device on edgex foundry failed, the response is : %s", resp.Body())
+		return nil, newResponseError(resp)
 	}
 
 	var edgexResps []*common.BaseWithIdResponse
@@ -79,7 +107,7 @@ func (efc *EdgexDeviceClient) Create(ctx context.Context, device *devicev1alpha1
 			createdDevice.Status.EdgeId = edgexResps[0].Id
 			createdDevice.Status.Synced = true
 		} else {
-			return nil, fmt.Errorf("create device on edgex foundry failed, the response is : %s", resp.Body())
+			return nil, newItemError(resp, edgexResps[0].BaseResponse)
 		}
 	} else {
 		return nil, fmt.Errorf("edgex BaseWithIdResponse count mismatch device cound, the response is : %s", resp.Body())
@@ -90,45 +118,55 @@ func (efc *EdgexDeviceClient) Create(ctx context.Context, device *devicev1alpha1
 // Delete function sends a request to EdgeX to delete a device
 func (efc *EdgexDeviceClient) Delete(ctx context.Context, name string, options clients.DeleteOptions) error {
 	klog.V(5).Infof("will delete the Device: %s", name)
-	delURL := fmt.Sprintf("http://%s%s/name/%s", efc.CoreMetaAddr, DevicePath, name)
-	resp, err := efc.R().Delete(delURL)
+	delURL := fmt.Sprintf("%s://%s%s/name/%s", efc.scheme, efc.CoreMetaAddr, DevicePath, name)
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	resp, err := request.Delete(delURL)
 	if err != nil {
 		return err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return errors.New(string(resp.Body()))
+		return newResponseError(resp)
 	}
+	efc.Cache.Invalidate(name)
 	return nil
 }
 
-// Update is used to set the admin or operating state of the device by unique name of the device.
-// TODO support to update other fields
+// Update sends a PATCH request to EdgeX to update all the fields of the device by the unique name of the device,
+// the admin and operating state are only updated when they are set.
 func (efc *EdgexDeviceClient) Update(ctx context.Context, device *devicev1alpha1.Device, options clients.UpdateOptions) (*devicev1alpha1.Device, error) {
-	actualDeviceName := getEdgeDeviceName(device)
-	putURL := fmt.Sprintf("http://%s%s/name/%s", efc.CoreMetaAddr, DevicePath, actualDeviceName)
 	if device == nil {
 		return nil, nil
 	}
-	updateData := map[string]string{}
-	if device.Spec.AdminState != "" {
-		updateData["adminState"] = string(device.Spec.AdminState)
-	}
-	if device.Spec.OperatingState != "" {
-		updateData["operatingState"] = string(device.Spec.OperatingState)
-	}
-	if len(updateData) == 0 {
-		return nil, nil
+	actualDeviceName := getEdgeDeviceName(device)
+	req := makeEdgeXUpdateDeviceRequest([]*devicev1alpha1.Device{device}, options.Fields)
+	klog.V(5).Infof("will update the Device: %s", actualDeviceName)
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
 	}
-
-	data, _ := json.Marshal(updateData)
-	rep, err := resty.New().R().
+	patchURL := fmt.Sprintf("%s://%s%s", efc.scheme, efc.CoreMetaAddr, DevicePath)
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	resp, err := request.
 		SetHeader("Content-Type", "application/json").
-		SetBody(data).
-		Put(putURL)
+		SetBody(reqBody).
+		Patch(patchURL)
 	if err != nil {
 		return nil, err
-	} else if rep.StatusCode() != http.StatusOK {
-		return nil, fmt.Errorf("failed to update device: %s, get response: %s", actualDeviceName, string(rep.Body()))
+	} else if resp.StatusCode() != http.StatusMultiStatus {
+		return nil, newResponseError(resp)
+	}
+
+	var edgexResps []*common.BaseResponse
+	if err = json.Unmarshal(resp.Body(), &edgexResps); err != nil {
+		return nil, err
+	}
+	if len(edgexResps) != 1 {
+		return nil, fmt.Errorf("edgex BaseResponse count mismatch device count, the response is : %s", resp.Body())
+	}
+	if edgexResps[0].StatusCode != http.StatusOK {
+		return nil, newItemError(resp, *edgexResps[0])
 	}
 	return device, nil
 }
@@ -137,13 +175,15 @@ func (efc *EdgexDeviceClient) Update(ctx context.Context, device *devicev1alpha1
 func (efc *EdgexDeviceClient) Get(ctx context.Context, deviceName string, options clients.GetOptions) (*devicev1alpha1.Device, error) {
 	klog.V(5).Infof("will get Devices: %s", deviceName)
 	var dResp edgex_resp.DeviceResponse
-	getURL := fmt.Sprintf("http://%s%s/name/%s", efc.CoreMetaAddr, DevicePath, deviceName)
-	resp, err := efc.R().Get(getURL)
+	getURL := fmt.Sprintf("%s://%s%s/name/%s", efc.scheme, efc.CoreMetaAddr, DevicePath, deviceName)
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	resp, err := request.Get(getURL)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode() == http.StatusNotFound {
-		return nil, fmt.Errorf("Device %s not found", deviceName)
+	if resp.StatusCode() != http.StatusOK {
+		return nil, newResponseError(resp)
 	}
 	err = json.Unmarshal(resp.Body(), &dResp)
 	if err != nil {
@@ -153,33 +193,82 @@ func (efc *EdgexDeviceClient) Get(ctx context.Context, deviceName string, option
 	return &device, err
 }
 
-// List is used to get all device objects on edge platform
-// TODO:support label filtering according to options
+// getListDeviceURL maps the selectors onto the filtered endpoints of EdgeX, the devices are listed by the name
+// of their deviceService or deviceProfile if it is selected, otherwise by their labels
+func getListDeviceURL(scheme, address string, opts clients.ListOptions, offset, limit int) (string, error) {
+	if err := clients.ValidateFieldSelector("devices", opts.FieldSelector, clients.ServiceNameField, clients.ProfileNameField); err != nil {
+		return "", err
+	}
+	path := "/all"
+	if name := opts.FieldSelector[clients.ServiceNameField]; name != "" {
+		path = "/service/name/" + url.PathEscape(name)
+	} else if name := opts.FieldSelector[clients.ProfileNameField]; name != "" {
+		path = "/profile/name/" + url.PathEscape(name)
+	}
+	return fmt.Sprintf("%s://%s%s%s?%s", scheme, address, DevicePath, path, listQuery(path, opts, offset, limit)), nil
+}
+
+// List is used to get the device objects on edge platform which are selected by the options
 func (efc *EdgexDeviceClient) List(ctx context.Context, options clients.ListOptions) ([]devicev1alpha1.Device, error) {
-	lp := fmt.Sprintf("http://%s%s/all?limit=-1", efc.CoreMetaAddr, DevicePath)
-	resp, err := efc.R().EnableTrace().Get(lp)
+	var res []devicev1alpha1.Device
+	err := efc.ListPages(ctx, options, func(devices []devicev1alpha1.Device) error {
+		res = append(res, devices...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	var mdResp edgex_resp.MultiDevicesResponse
-	if err := json.Unmarshal(resp.Body(), &mdResp); err != nil {
-		return nil, err
-	}
-	var res []devicev1alpha1.Device
-	for _, dp := range mdResp.Devices {
-		res = append(res, toKubeDevice(dp))
-	}
 	return res, nil
 }
 
+// ListPages pages through the devices on edge platform which are selected by the options, PageSize devices at a time
+func (efc *EdgexDeviceClient) ListPages(ctx context.Context, options clients.ListOptions, fn func(devices []devicev1alpha1.Device) error) error {
+	return listPages(efc.PageSize, func(offset, limit int) (int, int, error) {
+		lp, err := getListDeviceURL(efc.scheme, efc.CoreMetaAddr, options, offset, limit)
+		if err != nil {
+			return 0, 0, err
+		}
+		request, cancel := newRequest(ctx, efc.Client)
+		defer cancel()
+		resp, err := request.EnableTrace().Get(lp)
+		if err != nil {
+			return 0, 0, err
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return 0, 0, newResponseError(resp)
+		}
+		var mdResp edgex_resp.MultiDevicesResponse
+		if err := json.Unmarshal(resp.Body(), &mdResp); err != nil {
+			return 0, 0, err
+		}
+		var res []devicev1alpha1.Device
+		for _, dp := range mdResp.Devices {
+			d := toKubeDevice(dp)
+			if options.Matches(d.Spec.Labels, clients.DeviceFields(&d)) {
+				res = append(res, d)
+			}
+		}
+		if len(res) != 0 {
+			if err := fn(res); err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(mdResp.Devices), int(mdResp.TotalCount), nil
+	})
+}
+
 func (efc *EdgexDeviceClient) GetPropertyState(ctx context.Context, propertyName string, d *devicev1alpha1.Device, options clients.GetOptions) (*devicev1alpha1.ActualPropertyState, error) {
 	actualDeviceName := getEdgeDeviceName(d)
+	// the value read last is used if it is not older than the max staleness of the property
+	if aps, ok := efc.Cache.Get(actualDeviceName, propertyName, clients.PropertyMaxStaleness(d, propertyName)); ok {
+		return aps, nil
+	}
 	// get the old property from status
 	oldAps, exist := d.Status.DeviceProperties[propertyName]
 	propertyGetURL := ""
 	// 1. query the Get URL of a property
 	if !exist || (exist && oldAps.GetURL == "") {
-		coreCommands, err := efc.GetCommandResponseByName(actualDeviceName)
+		coreCommands, err := efc.GetCommandResponseByName(ctx, actualDeviceName)
 		if err != nil {
 			return &devicev1alpha1.ActualPropertyState{}, err
 		}
@@ -190,47 +279,38 @@ func (efc *EdgexDeviceClient) GetPropertyState(ctx context.Context, propertyName
 			}
 		}
 		if propertyGetURL == "" {
-			return nil, &clients.NotFoundError{}
+			return nil, &clients.NotFoundError{StatusError: clients.StatusError{
+				Message: fmt.Sprintf("no get command of property %s", propertyName)}}
 		}
 	} else {
 		propertyGetURL = oldAps.GetURL
 	}
 	// 2. get the actual property value by the getURL
-	actualPropertyState := devicev1alpha1.ActualPropertyState{
-		Name:   propertyName,
-		GetURL: propertyGetURL,
+	resp, err := efc.getPropertyState(ctx, propertyGetURL)
+	if err != nil {
+		return nil, err
 	}
-	if resp, err := getPropertyState(propertyGetURL); err != nil {
+	var eResp edgex_resp.EventResponse
+	if err := json.Unmarshal(resp.Body(), &eResp); err != nil {
 		return nil, err
-	} else {
-		var eResp edgex_resp.EventResponse
-		if err := json.Unmarshal(resp.Body(), &eResp); err != nil {
-			return nil, err
-		}
-		actualPropertyState.ActualValue = getPropertyValueFromEvent(propertyName, eResp.Event)
 	}
+	actualPropertyState := getPropertyStateFromEvent(propertyName, propertyName, eResp.Event)
+	actualPropertyState.GetURL = propertyGetURL
+	efc.Cache.Set(actualDeviceName, actualPropertyState)
 	return &actualPropertyState, nil
 }
 
-// getPropertyState returns different error messages according to the status code
-func getPropertyState(getURL string) (*resty.Response, error) {
-	cookieJar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
-	// TODO: no need to instantiate a client for every request
-	resp, err := resty.NewWithClient(&http.Client{
-		Jar:     cookieJar,
-		Timeout: 10 * time.Second,
-	}).R().Get(getURL)
+// getPropertyState returns the typed error of the status code, e.g. a LockedError if the device is
+// locked (AdminState) or down (OperatingState)
+func (efc *EdgexDeviceClient) getPropertyState(ctx context.Context, getURL string) (*resty.Response, error) {
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	resp, err := request.Get(getURL)
 	if err != nil {
 		return resp, err
 	}
-	if resp.StatusCode() == 400 {
-		err = errors.New("request is in an invalid state")
-	} else if resp.StatusCode() == 404 {
-		err = errors.New("the requested resource does not exist")
-	} else if resp.StatusCode() == 423 {
-		err = errors.New("the device is locked (AdminState) or down (OperatingState)")
-	} else if resp.StatusCode() == 500 {
-		err = errors.New("an unexpected error occurred on the server")
+	if resp.StatusCode() != http.StatusOK {
+		err = newResponseError(resp)
 	}
 	return resp, err
 }
@@ -241,8 +321,9 @@ func (efc *EdgexDeviceClient) UpdatePropertyState(ctx context.Context, propertyN
 
 	dps := d.Spec.DeviceProperties[propertyName]
 	parameterName := dps.Name
-	if dps.PutURL == "" {
-		putCmd, err := efc.getPropertyPut(acturalDeviceName, dps.Name)
+	// the parameters are checked against those of the set command
+	if dps.PutURL == "" || len(dps.DesiredParameters) != 0 {
+		putCmd, err := efc.getPropertyPut(ctx, acturalDeviceName, dps.Name)
 		if err != nil {
 			return err
 		}
@@ -250,33 +331,70 @@ func (efc *EdgexDeviceClient) UpdatePropertyState(ctx context.Context, propertyN
 		if len(putCmd.Parameters) == 1 {
 			parameterName = putCmd.Parameters[0].ResourceName
 		}
+		if err := checkCommandParameters(putCmd, dps.DesiredParameters); err != nil {
+			return err
+		}
+	}
+	// set the device property to desired state, all the parameters are sent by a single command
+	bodyMap := dps.DesiredParameters
+	if len(bodyMap) == 0 {
+		bodyMap = map[string]string{parameterName: dps.DesiredValue}
 	}
-	// set the device property to desired state
-	bodyMap := make(map[string]string)
-	bodyMap[parameterName] = dps.DesiredValue
 	body, _ := json.Marshal(bodyMap)
-	klog.V(5).Infof("setting the property to desired value", "propertyName", parameterName, "desiredValue", string(body))
-	rep, err := resty.New().R().
+	klog.V(5).InfoS("setting the property to desired value", "propertyName", propertyName, "desiredValue", string(body))
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	rep, err := request.
 		SetHeader("Content-Type", "application/json").
 		SetBody(body).
 		Put(dps.PutURL)
 	if err != nil {
 		return err
 	} else if rep.StatusCode() != http.StatusOK {
-		return fmt.Errorf("failed to set property: %s, get response: %s", dps.Name, string(rep.Body()))
+		return newResponseError(rep)
 	} else if rep.Body() != nil {
 		// If the parameters are illegal, such as out of range, the 200 status code is also returned, but the description appears in the body
 		a := string(rep.Body())
 		if strings.Contains(a, "execWriteCmd") {
-			return fmt.Errorf("failed to set property: %s, get response: %s", dps.Name, string(rep.Body()))
+			return &clients.InvalidRequestError{StatusError: clients.StatusError{
+				StatusCode: rep.StatusCode(), Message: strings.TrimSpace(a)}}
+		}
+	}
+	efc.Cache.Invalidate(acturalDeviceName, propertyName)
+	return nil
+}
+
+// checkCommandParameters checks that every parameter is a resource operated by the set command,
+// and that none of the command's parameters is missing
+func checkCommandParameters(putCmd dtos.CoreCommand, parameters map[string]string) error {
+	if len(parameters) == 0 {
+		return nil
+	}
+	for name := range parameters {
+		found := false
+		for _, p := range putCmd.Parameters {
+			if p.ResourceName == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &clients.InvalidRequestError{StatusError: clients.StatusError{
+				Message: fmt.Sprintf("%s is not a parameter of command %s", name, putCmd.Name)}}
+		}
+	}
+	for _, p := range putCmd.Parameters {
+		if _, ok := parameters[p.ResourceName]; !ok {
+			return &clients.InvalidRequestError{StatusError: clients.StatusError{
+				Message: fmt.Sprintf("parameter %s of command %s is missing", p.ResourceName, putCmd.Name)}}
 		}
 	}
 	return nil
 }
 
 // Gets the models.Put from edgex foundry which is used to set the device property's value
-func (efc *EdgexDeviceClient) getPropertyPut(deviceName, cmdName string) (dtos.CoreCommand, error) {
-	coreCommands, err := efc.GetCommandResponseByName(deviceName)
+func (efc *EdgexDeviceClient) getPropertyPut(ctx context.Context, deviceName, cmdName string) (dtos.CoreCommand, error) {
+	coreCommands, err := efc.GetCommandResponseByName(ctx, deviceName)
 	if err != nil {
 		return dtos.CoreCommand{}, err
 	}
@@ -285,7 +403,8 @@ func (efc *EdgexDeviceClient) getPropertyPut(deviceName, cmdName string) (dtos.C
 			return c, nil
 		}
 	}
-	return dtos.CoreCommand{}, errors.New("corresponding command is not found")
+	return dtos.CoreCommand{}, &clients.NotFoundError{StatusError: clients.StatusError{
+		Message: fmt.Sprintf("no set command %s of device %s", cmdName, deviceName)}}
 }
 
 // ListPropertiesState gets all the actual property information about a device
@@ -294,14 +413,23 @@ func (efc *EdgexDeviceClient) ListPropertiesState(ctx context.Context, device *d
 
 	dpsm := map[string]devicev1alpha1.DesiredPropertyState{}
 	apsm := map[string]devicev1alpha1.ActualPropertyState{}
-	coreCommands, err := efc.GetCommandResponseByName(actualDeviceName)
+	coreCommands, err := efc.GetCommandResponseByName(ctx, actualDeviceName)
 	if err != nil {
 		return dpsm, apsm, err
 	}
 
 	for _, c := range coreCommands {
+		// the properties are read one by one, give up once the deadline is exceeded
+		if ctx != nil && ctx.Err() != nil {
+			return dpsm, apsm, ctx.Err()
+		}
 		// DesiredPropertyState only store the basic information and does not set DesiredValue
 		if c.Get {
+			// the value read last is used until the refresh interval of the property has passed
+			if cached, ok := efc.Cache.Get(actualDeviceName, c.Name, clients.PropertyRefreshInterval(device, c.Name)); ok {
+				apsm[c.Name] = *cached
+				continue
+			}
 			getURL := fmt.Sprintf("%s%s", c.Url, c.Path)
 			aps, ok := apsm[c.Name]
 			if ok {
@@ -310,7 +438,7 @@ func (efc *EdgexDeviceClient) ListPropertiesState(ctx context.Context, device *d
 				aps = devicev1alpha1.ActualPropertyState{Name: c.Name, GetURL: getURL}
 			}
 			apsm[c.Name] = aps
-			resp, err := getPropertyState(getURL)
+			resp, err := efc.getPropertyState(ctx, getURL)
 			if err != nil {
 				klog.V(5).ErrorS(err, "getPropertyState failed", "propertyName", c.Name, "deviceName", actualDeviceName)
 			} else {
@@ -326,48 +454,169 @@ func (efc *EdgexDeviceClient) ListPropertiesState(ctx context.Context, device *d
 					readingName = expectParams[0].ResourceName
 				}
 				klog.V(5).Infof("get reading name %s for command %s of device %s", readingName, c.Name, device.Name)
-				actualValue := getPropertyValueFromEvent(readingName, event)
-				aps.ActualValue = actualValue
+				getURL := aps.GetURL
+				aps = getPropertyStateFromEvent(c.Name, readingName, event)
+				aps.GetURL = getURL
 				apsm[c.Name] = aps
+				efc.Cache.Set(actualDeviceName, aps)
 			}
 		}
 	}
 	return dpsm, apsm, nil
 }
 
-// The actual property value is resolved from the returned event
-func getPropertyValueFromEvent(resName string, event dtos.Event) string {
-	actualValue := ""
+// ListLatestPropertiesState gets the latest readings of a device from core-data, the device itself will not be visited.
+// The properties are named after the read commands of the device like those read actively, a command operating
+// several resources is only reported once all of them have been read.
+func (efc *EdgexDeviceClient) ListLatestPropertiesState(ctx context.Context, device *devicev1alpha1.Device, options clients.ListOptions) (map[string]devicev1alpha1.ActualPropertyState, error) {
+	actualDeviceName := getEdgeDeviceName(device)
+	coreCommands, err := efc.getProfileCommands(ctx, device)
+	if err != nil {
+		return nil, err
+	}
+	klog.V(5).Infof("will get the latest events of device: %s", actualDeviceName)
+
+	getURL := fmt.Sprintf("%s://%s%s/device/name/%s?limit=%d", efc.scheme, efc.CoreDataAddr, EventPath, actualDeviceName, LatestEventsLimit)
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	resp, err := request.Get(getURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, newResponseError(resp)
+	}
+	var meResp edgex_resp.MultiEventsResponse
+	if err := json.Unmarshal(resp.Body(), &meResp); err != nil {
+		return nil, err
+	}
+
+	// core-data returns the events sorted from newest to oldest, so the first reading of a resource is the latest one
+	latest := map[string]dtos.BaseReading{}
+	for _, e := range meResp.Events {
+		for _, r := range e.Readings {
+			if _, exist := latest[r.ResourceName]; !exist {
+				latest[r.ResourceName] = r
+			}
+		}
+	}
+
+	apsm := map[string]devicev1alpha1.ActualPropertyState{}
+	for _, c := range coreCommands {
+		if !c.Get || len(c.Parameters) == 0 {
+			continue
+		}
+		if len(c.Parameters) == 1 {
+			if r, ok := latest[c.Parameters[0].ResourceName]; ok {
+				apsm[c.Name] = getReadingState(c.Name, r)
+			}
+			continue
+		}
+		aps := devicev1alpha1.ActualPropertyState{Name: c.Name, ActualParameters: make(map[string]string, len(c.Parameters))}
+		for _, p := range c.Parameters {
+			if r, ok := latest[p.ResourceName]; ok {
+				aps.ActualParameters[p.ResourceName] = getReadingValue(r)
+			}
+		}
+		if len(aps.ActualParameters) == len(c.Parameters) {
+			apsm[c.Name] = aps
+		}
+	}
+	return apsm, nil
+}
+
+// getPropertyStateFromEvent returns the state of the property from the reading of the resource in the event,
+// the actual value is empty if the event has no such reading. The readings of a command operating several
+// resources are the actual parameters of the property.
+func getPropertyStateFromEvent(propertyName, resName string, event dtos.Event) devicev1alpha1.ActualPropertyState {
+	aps := devicev1alpha1.ActualPropertyState{Name: propertyName}
 	for _, r := range event.Readings {
 		if resName == r.ResourceName {
-			if r.SimpleReading.Value != "" {
-				actualValue = r.SimpleReading.Value
-			} else if len(r.BinaryReading.BinaryValue) != 0 {
-				// TODO: how to demonstrate binary data
-				actualValue = fmt.Sprintf("%s:%s", r.BinaryReading.MediaType, "blob value")
-			} else if r.ObjectReading.ObjectValue != nil {
-				serializedBytes, _ := json.Marshal(r.ObjectReading.ObjectValue)
-				actualValue = string(serializedBytes)
-			}
+			aps = getReadingState(propertyName, r)
 			break
 		}
 	}
+	if len(event.Readings) > 1 {
+		aps.ActualParameters = make(map[string]string, len(event.Readings))
+		for _, r := range event.Readings {
+			aps.ActualParameters[r.ResourceName] = getReadingValue(r)
+		}
+	}
+	return aps
+}
+
+// getReadingState returns the state of the property from a reading, the payload of a binary reading is carried
+// in base64 until it is stored and an object reading is kept as structured JSON
+func getReadingState(propertyName string, r dtos.BaseReading) devicev1alpha1.ActualPropertyState {
+	if len(r.BinaryReading.BinaryValue) != 0 {
+		return clients.NewBinaryPropertyState(propertyName, r.BinaryReading.MediaType, r.BinaryReading.BinaryValue)
+	} else if r.ObjectReading.ObjectValue != nil {
+		return clients.NewObjectPropertyState(propertyName, r.ObjectReading.ObjectValue)
+	}
+	return devicev1alpha1.ActualPropertyState{Name: propertyName, ActualValue: r.SimpleReading.Value}
+}
+
+// getReadingValue serializes the value of a reading to string, a binary reading is represented by the hash of its payload
+func getReadingValue(r dtos.BaseReading) string {
+	actualValue := ""
+	if r.SimpleReading.Value != "" {
+		actualValue = r.SimpleReading.Value
+	} else if len(r.BinaryReading.BinaryValue) != 0 {
+		actualValue = clients.PayloadHash(r.BinaryReading.BinaryValue)
+	} else if r.ObjectReading.ObjectValue != nil {
+		serializedBytes, _ := json.Marshal(r.ObjectReading.ObjectValue)
+		actualValue = string(serializedBytes)
+	}
 	return actualValue
 }
 
+// profileCommandCache keeps the commands of the deviceProfiles by name, the devices of a deviceProfile have the same
+// commands except for their URLs
+type profileCommandCache struct {
+	mu      sync.Mutex
+	entries map[string]profileCommands
+}
+
+type profileCommands struct {
+	commands []dtos.CoreCommand
+	listedAt time.Time
+}
+
+// getProfileCommands returns the commands of the deviceProfile of the device, they are listed from core-command
+// once they are older than ProfileCommandsTTL. The URLs of the returned commands may be those of another device.
+func (efc *EdgexDeviceClient) getProfileCommands(ctx context.Context, device *devicev1alpha1.Device) ([]dtos.CoreCommand, error) {
+	c := efc.commands
+	c.mu.Lock()
+	pc, ok := c.entries[device.Spec.Profile]
+	c.mu.Unlock()
+	if ok && time.Since(pc.listedAt) < ProfileCommandsTTL {
+		return pc.commands, nil
+	}
+	commands, err := efc.GetCommandResponseByName(ctx, getEdgeDeviceName(device))
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[device.Spec.Profile] = profileCommands{commands: commands, listedAt: time.Now()}
+	c.mu.Unlock()
+	return commands, nil
+}
+
 // GetCommandResponseByName gets all commands supported by the device
-func (efc *EdgexDeviceClient) GetCommandResponseByName(deviceName string) ([]dtos.CoreCommand, error) {
+func (efc *EdgexDeviceClient) GetCommandResponseByName(ctx context.Context, deviceName string) ([]dtos.CoreCommand, error) {
 	klog.V(5).Infof("will get CommandResponses of device: %s", deviceName)
 
 	var dcr edgex_resp.DeviceCoreCommandResponse
-	getURL := fmt.Sprintf("http://%s%s/name/%s", efc.CoreCommandAddr, CommandResponsePath, deviceName)
+	getURL := fmt.Sprintf("%s://%s%s/name/%s", efc.scheme, efc.CoreCommandAddr, CommandResponsePath, deviceName)
 
-	resp, err := efc.R().Get(getURL)
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	resp, err := request.Get(getURL)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode() == http.StatusNotFound {
-		return nil, errors.New("Item not found")
+	if resp.StatusCode() != http.StatusOK {
+		return nil, newResponseError(resp)
 	}
 	err = json.Unmarshal(resp.Body(), &dcr)
 	if err != nil {
@@ -375,3 +624,58 @@ func (efc *EdgexDeviceClient) GetCommandResponseByName(deviceName string) ([]dto
 	}
 	return dcr.DeviceCoreCommand.CoreCommands, nil
 }
+
+// GetCommand issues the command to the device through core-command and returns the readings of the returned event
+func (efc *EdgexDeviceClient) GetCommand(ctx context.Context, commandName string, device *devicev1alpha1.Device, options clients.GetOptions) (map[string]string, error) {
+	getURL := efc.getCommandURL(getEdgeDeviceName(device), commandName)
+	resp, err := efc.getPropertyState(ctx, getURL)
+	if err != nil {
+		return nil, err
+	}
+	var eResp edgex_resp.EventResponse
+	if err := json.Unmarshal(resp.Body(), &eResp); err != nil {
+		return nil, err
+	}
+	readings := make(map[string]string, len(eResp.Event.Readings))
+	for _, r := range eResp.Event.Readings {
+		readings[r.ResourceName] = getReadingValue(r)
+	}
+	return readings, nil
+}
+
+// SetCommand sends the parameters to the device by the set command through core-command
+func (efc *EdgexDeviceClient) SetCommand(ctx context.Context, commandName string, device *devicev1alpha1.Device, parameters map[string]string, options clients.UpdateOptions) error {
+	// the parameters are checked against those of the set command
+	putCmd, err := efc.getPropertyPut(ctx, getEdgeDeviceName(device), commandName)
+	if err != nil {
+		return err
+	}
+	if err := checkCommandParameters(putCmd, parameters); err != nil {
+		return err
+	}
+	putURL := efc.getCommandURL(getEdgeDeviceName(device), commandName)
+	body, _ := json.Marshal(parameters)
+	klog.V(5).Infof("setting the command %s of device %s: %s", commandName, device.GetName(), string(body))
+	request, cancel := newRequest(ctx, efc.Client)
+	defer cancel()
+	rep, err := request.
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Put(putURL)
+	if err != nil {
+		return err
+	} else if rep.StatusCode() != http.StatusOK {
+		return newResponseError(rep)
+	} else if a := string(rep.Body()); strings.Contains(a, "execWriteCmd") {
+		// an illegal parameter is reported in the body of a response with the 200 status code
+		return &clients.InvalidRequestError{StatusError: clients.StatusError{
+			StatusCode: rep.StatusCode(), Message: strings.TrimSpace(a)}}
+	}
+	// the command may write any property of the device
+	efc.Cache.Invalidate(getEdgeDeviceName(device))
+	return nil
+}
+
+func (efc *EdgexDeviceClient) getCommandURL(deviceName, commandName string) string {
+	return fmt.Sprintf("%s://%s%s/name/%s/%s", efc.scheme, efc.CoreCommandAddr, CommandResponsePath, deviceName, commandName)
+}
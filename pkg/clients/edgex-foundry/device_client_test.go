@@ -0,0 +1,600 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+	"github.com/openyurtio/device-controller/pkg/clients/edgex-foundry/edgextest"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestServer(t *testing.T) *edgextest.Server {
+	s := edgextest.NewServer()
+	t.Cleanup(s.Close)
+	s.AddDeviceService(dtos.DeviceService{Name: "device-virtual", BaseAddress: "http://edgex-device-virtual:59900", AdminState: "UNLOCKED"})
+	s.AddDeviceProfile(dtos.DeviceProfile{
+		Name: "Random-Integer-Device",
+		DeviceResources: []dtos.DeviceResource{
+			{Name: "Int8", Properties: dtos.ResourceProperties{ValueType: "Int8", ReadWrite: "RW", DefaultValue: "0"}},
+			{Name: "Int16", Properties: dtos.ResourceProperties{ValueType: "Int16", ReadWrite: "R"}},
+		},
+	})
+	return s
+}
+
+func newTestDevice(name string) *devicev1alpha1.Device {
+	return &devicev1alpha1.Device{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: devicev1alpha1.DeviceSpec{
+			AdminState:     devicev1alpha1.UnLocked,
+			OperatingState: devicev1alpha1.Up,
+			Service:        "device-virtual",
+			Profile:        "Random-Integer-Device",
+			Protocols: map[string]devicev1alpha1.ProtocolProperties{
+				"other": {"Address": "device-virtual-int-01"},
+			},
+		},
+	}
+}
+
+func newTestDeviceClient(s *edgextest.Server) *EdgexDeviceClient {
+	return NewEdgexDeviceClient(s.MetadataAddr(), s.CommandAddr(), s.DataAddr())
+}
+
+func TestCreateDevice(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+
+	created, err := cli.Create(context.TODO(), newTestDevice("random-integer-device"), clients.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+	ed, ok := s.Device("random-integer-device")
+	if !ok {
+		t.Fatalf("expected the device to be created on EdgeX")
+	}
+	if !created.Status.Synced || created.Status.EdgeId != ed.Id {
+		t.Errorf("expected the device to be synced with edge id %s, got status %+v", ed.Id, created.Status)
+	}
+
+	// the multi-status response carries the conflict of the single item
+	if _, err := cli.Create(context.TODO(), newTestDevice("random-integer-device"), clients.CreateOptions{}); err == nil {
+		t.Errorf("expected creating a duplicated device to fail")
+	}
+	d := newTestDevice("random-float-device")
+	d.Spec.Profile = "Random-Float-Device"
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err == nil {
+		t.Errorf("expected creating a device with a nonexistent profile to fail")
+	}
+}
+
+func TestUpdateDeviceFields(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	s.AddDevice(dtos.Device{Name: "random-integer-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device",
+		AdminState: "UNLOCKED", OperatingState: "UP", Location: "building-1", Labels: []string{"virtual"},
+		AutoEvents: []dtos.AutoEvent{{Interval: "10s", SourceName: "Int8"}}})
+
+	// only the given fields are patched, the values set on EdgeX alone are kept
+	d := newTestDevice("random-integer-device")
+	d.Spec.Description = "a random integer device"
+	if _, err := cli.Update(context.TODO(), d, clients.UpdateOptions{Fields: []string{"description"}}); err != nil {
+		t.Fatalf("failed to update the device: %v", err)
+	}
+	ed, _ := s.Device("random-integer-device")
+	if ed.Description != d.Spec.Description {
+		t.Errorf("expected the description %q, got %q", d.Spec.Description, ed.Description)
+	}
+	if ed.Location != "building-1" || !reflect.DeepEqual(ed.Labels, []string{"virtual"}) || len(ed.AutoEvents) != 1 {
+		t.Errorf("expected the fields that were not given to be kept, got %+v", ed)
+	}
+
+	// all the fields are sent if none is given
+	if _, err := cli.Update(context.TODO(), d, clients.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the device: %v", err)
+	}
+	ed, _ = s.Device("random-integer-device")
+	if ed.Location != "" || len(ed.Labels) != 0 || len(ed.AutoEvents) != 0 {
+		t.Errorf("expected all the fields to be updated, got %+v", ed)
+	}
+}
+
+func TestListDevicesWithSelectors(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	s.AddDeviceService(dtos.DeviceService{Name: "device-modbus", AdminState: "UNLOCKED"})
+	s.AddDevice(dtos.Device{Name: "random-integer-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device", Labels: []string{"virtual", "floor=1"}})
+	s.AddDevice(dtos.Device{Name: "random-float-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device", Labels: []string{"virtual"}})
+	s.AddDevice(dtos.Device{Name: "modbus-device", ServiceName: "device-modbus", ProfileName: "Random-Integer-Device", Labels: []string{"floor=1"}})
+
+	tests := []struct {
+		name    string
+		options clients.ListOptions
+		want    []string
+	}{
+		{"all", clients.ListOptions{}, []string{"modbus-device", "random-float-device", "random-integer-device"}},
+		{"label", clients.ListOptions{LabelSelector: map[string]string{"virtual": ""}}, []string{"random-float-device", "random-integer-device"}},
+		{"all labels", clients.ListOptions{LabelSelector: map[string]string{"virtual": "", "floor": "1"}}, []string{"random-integer-device"}},
+		{"service", clients.ListOptions{FieldSelector: map[string]string{clients.ServiceNameField: "device-modbus"}}, []string{"modbus-device"}},
+		{"service and label", clients.ListOptions{
+			FieldSelector: map[string]string{clients.ServiceNameField: "device-virtual"},
+			LabelSelector: map[string]string{"floor": "1"}}, []string{"random-integer-device"}},
+		{"profile", clients.ListOptions{FieldSelector: map[string]string{clients.ProfileNameField: "Random-Float-Device"}}, nil},
+	}
+	for _, tt := range tests {
+		devices, err := cli.List(context.TODO(), tt.options)
+		if err != nil {
+			t.Errorf("%s: failed to list devices: %v", tt.name, err)
+			continue
+		}
+		var names []string
+		for _, d := range devices {
+			names = append(names, d.Name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("%s: expected devices %v, got %v", tt.name, tt.want, names)
+		}
+	}
+
+	_, err := cli.List(context.TODO(), clients.ListOptions{FieldSelector: map[string]string{clients.ManufacturerField: "IOTech"}})
+	if !clients.IsInvalidRequestErr(err) {
+		t.Errorf("expected an unsupported field selector to be rejected, got %v", err)
+	}
+}
+
+func TestListDevicesPageByPage(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	cli.PageSize = 2
+	for i := 0; i < 5; i++ {
+		s.AddDevice(dtos.Device{Name: fmt.Sprintf("random-device-%d", i), ServiceName: "device-virtual", ProfileName: "Random-Integer-Device"})
+	}
+
+	var pages []int
+	requests := s.RequestCount()
+	err := cli.ListPages(context.TODO(), clients.ListOptions{}, func(devices []devicev1alpha1.Device) error {
+		pages = append(pages, len(devices))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to list devices: %v", err)
+	}
+	if !reflect.DeepEqual(pages, []int{2, 2, 1}) {
+		t.Errorf("expected pages of 2, 2 and 1 devices, got %v", pages)
+	}
+	if n := s.RequestCount() - requests; n != 3 {
+		t.Errorf("expected a request per page, got %d requests", n)
+	}
+
+	stop := errors.New("stop")
+	pages = nil
+	err = cli.ListPages(context.TODO(), clients.ListOptions{}, func(devices []devicev1alpha1.Device) error {
+		pages = append(pages, len(devices))
+		return stop
+	})
+	if err != stop || len(pages) != 1 {
+		t.Errorf("expected the iteration to stop at the first page, got %v after %d pages", err, len(pages))
+	}
+
+	devices, err := cli.List(context.TODO(), clients.ListOptions{})
+	if err != nil || len(devices) != 5 {
+		t.Errorf("expected 5 devices, got %d: %v", len(devices), err)
+	}
+}
+
+func TestDevicePropertyState(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	d := newTestDevice("random-integer-device")
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+
+	d.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Int8": {Name: "Int8", DesiredValue: "42"},
+	}
+	if err := cli.UpdatePropertyState(context.TODO(), "Int8", d, clients.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the property: %v", err)
+	}
+	if v, _ := s.Reading("random-integer-device", "Int8"); v != "42" {
+		t.Errorf("expected the reading of Int8 to be 42, got %q", v)
+	}
+	aps, err := cli.GetPropertyState(context.TODO(), "Int8", d, clients.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the property: %v", err)
+	}
+	if aps.ActualValue != "42" || aps.GetURL == "" {
+		t.Errorf("expected the actual value 42 with the get URL, got %+v", aps)
+	}
+
+	d.Spec.DeviceProperties["Int16"] = devicev1alpha1.DesiredPropertyState{Name: "Int16", DesiredValue: "7"}
+	if err := cli.UpdatePropertyState(context.TODO(), "Int16", d, clients.UpdateOptions{}); err == nil {
+		t.Errorf("expected updating the read-only property to fail")
+	}
+
+	s.SetReading("random-integer-device", "Int16", "9")
+	latest, err := cli.ListLatestPropertiesState(context.TODO(), d, clients.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the latest properties: %v", err)
+	}
+	if latest["Int16"].ActualValue != "9" {
+		t.Errorf("expected the latest value of Int16 to be 9, got %+v", latest)
+	}
+}
+
+func TestDevicePropertyCache(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	now := time.Now()
+	cli.Cache.Now = func() time.Time { return now }
+	d := newTestDevice("random-integer-device")
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+	d.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Int8":  {Name: "Int8", RefreshInterval: &metav1.Duration{Duration: time.Minute}},
+		"Int16": {Name: "Int16", MaxStaleness: &metav1.Duration{Duration: 10 * time.Second}},
+	}
+	s.SetReading("random-integer-device", "Int8", "1")
+	s.SetReading("random-integer-device", "Int16", "1")
+	if _, _, err := cli.ListPropertiesState(context.TODO(), d, clients.ListOptions{}); err != nil {
+		t.Fatalf("failed to list the properties: %v", err)
+	}
+
+	// Int8 is not read again within its refresh interval, Int16 is read in every round
+	s.SetReading("random-integer-device", "Int8", "2")
+	s.SetReading("random-integer-device", "Int16", "2")
+	now = now.Add(30 * time.Second)
+	_, apsm, err := cli.ListPropertiesState(context.TODO(), d, clients.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the properties: %v", err)
+	}
+	if apsm["Int8"].ActualValue != "1" || apsm["Int16"].ActualValue != "2" {
+		t.Errorf("expected Int8 to be cached and Int16 to be read, got %+v", apsm)
+	}
+	now = now.Add(time.Minute)
+	if _, apsm, _ = cli.ListPropertiesState(context.TODO(), d, clients.ListOptions{}); apsm["Int8"].ActualValue != "2" {
+		t.Errorf("expected Int8 to be read once its refresh interval has passed, got %+v", apsm["Int8"])
+	}
+
+	// Int16 is compared with the desired value by the value read last if it's not older than its max staleness
+	s.SetReading("random-integer-device", "Int16", "3")
+	if aps, err := cli.GetPropertyState(context.TODO(), "Int16", d, clients.GetOptions{}); err != nil || aps.ActualValue != "2" {
+		t.Errorf("expected the cached value of Int16, got %+v, %v", aps, err)
+	}
+	now = now.Add(10 * time.Second)
+	if aps, err := cli.GetPropertyState(context.TODO(), "Int16", d, clients.GetOptions{}); err != nil || aps.ActualValue != "3" {
+		t.Errorf("expected Int16 to be read once it is stale, got %+v, %v", aps, err)
+	}
+
+	// a property written to the device is read again
+	d.Spec.DeviceProperties["Int8"] = devicev1alpha1.DesiredPropertyState{Name: "Int8", DesiredValue: "42", MaxStaleness: &metav1.Duration{Duration: time.Hour}}
+	if _, err := cli.GetPropertyState(context.TODO(), "Int8", d, clients.GetOptions{}); err != nil {
+		t.Fatalf("failed to get the property: %v", err)
+	}
+	if err := cli.UpdatePropertyState(context.TODO(), "Int8", d, clients.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the property: %v", err)
+	}
+	if aps, err := cli.GetPropertyState(context.TODO(), "Int8", d, clients.GetOptions{}); err != nil || aps.ActualValue != "42" {
+		t.Errorf("expected Int8 to be read after it was written, got %+v, %v", aps, err)
+	}
+}
+
+func TestBinaryAndObjectProperties(t *testing.T) {
+	s := newTestServer(t)
+	s.AddDeviceProfile(dtos.DeviceProfile{
+		Name: "Camera",
+		DeviceResources: []dtos.DeviceResource{
+			{Name: "Snapshot", Properties: dtos.ResourceProperties{ValueType: "Binary", ReadWrite: "R", MediaType: "image/jpeg"}},
+			{Name: "Info", Properties: dtos.ResourceProperties{ValueType: "Object", ReadWrite: "R"}},
+		},
+	})
+	cli := newTestDeviceClient(s)
+	d := newTestDevice("camera")
+	d.Spec.Profile = "Camera"
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+	payload := []byte{0xff, 0xd8, 0xff, 0xe0}
+	s.SetReading("camera", "Snapshot", base64.StdEncoding.EncodeToString(payload))
+	s.SetReading("camera", "Info", `{"model":"c1","resolution":[1920,1080]}`)
+
+	aps, err := cli.GetPropertyState(context.TODO(), "Snapshot", d, clients.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the property: %v", err)
+	}
+	expected := &devicev1alpha1.BinaryValue{MediaType: "image/jpeg", Size: 4, Hash: clients.PayloadHash(payload)}
+	if !reflect.DeepEqual(aps.Binary, expected) {
+		t.Errorf("expected binary value %+v, got %+v", expected, aps.Binary)
+	}
+	if got, ok := clients.BinaryPayload(aps); !ok || !reflect.DeepEqual(got, payload) {
+		t.Errorf("expected the payload to be carried by the actual value, got %v", got)
+	}
+
+	apsm, err := cli.ListLatestPropertiesState(context.TODO(), d, clients.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to get the latest readings: %v", err)
+	}
+	if info := apsm["Info"]; info.ObjectValue == nil || string(info.ObjectValue.Raw) != `{"model":"c1","resolution":[1920,1080]}` {
+		t.Errorf("expected the object to be kept as structured JSON, got %+v", info)
+	}
+
+	// the readings of a command represent a binary value by the hash of its payload
+	readings, err := cli.GetCommand(context.TODO(), "Snapshot", d, clients.GetOptions{})
+	if err != nil || readings["Snapshot"] != clients.PayloadHash(payload) {
+		t.Errorf("expected the hash of the payload, got %v, %v", readings, err)
+	}
+}
+
+func TestMultiParameterProperty(t *testing.T) {
+	s := newTestServer(t)
+	s.AddDeviceProfile(dtos.DeviceProfile{
+		Name: "Color-Light",
+		DeviceResources: []dtos.DeviceResource{
+			{Name: "R", Properties: dtos.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+			{Name: "G", Properties: dtos.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+			{Name: "B", Properties: dtos.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+		},
+		DeviceCommands: []dtos.DeviceCommand{
+			{Name: "Color", ReadWrite: "RW", ResourceOperations: []dtos.ResourceOperation{
+				{DeviceResource: "R"}, {DeviceResource: "G"}, {DeviceResource: "B"},
+			}},
+		},
+	})
+	cli := newTestDeviceClient(s)
+	d := newTestDevice("color-light")
+	d.Spec.Profile = "Color-Light"
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+	color := map[string]string{"R": "255", "G": "128", "B": "0"}
+	d.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Color": {Name: "Color", DesiredParameters: color},
+	}
+
+	// the command is listed to check the parameters, then all of them are sent by a single PUT
+	before := s.RequestCount()
+	if err := cli.UpdatePropertyState(context.TODO(), "Color", d, clients.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update the property: %v", err)
+	}
+	if n := s.RequestCount() - before; n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+	for name, value := range color {
+		if v, _ := s.Reading("color-light", name); v != value {
+			t.Errorf("expected %s to be set to %s, got %q", name, value, v)
+		}
+	}
+	aps, err := cli.GetPropertyState(context.TODO(), "Color", d, clients.GetOptions{})
+	if err != nil || !reflect.DeepEqual(aps.ActualParameters, color) {
+		t.Errorf("expected the actual parameters %v, got %+v, %v", color, aps, err)
+	}
+
+	d.Spec.DeviceProperties["Color"] = devicev1alpha1.DesiredPropertyState{Name: "Color", DesiredParameters: map[string]string{"W": "1"}}
+	if err := cli.UpdatePropertyState(context.TODO(), "Color", d, clients.UpdateOptions{}); !clients.IsInvalidRequestErr(err) {
+		t.Errorf("expected an invalid request error for an unknown parameter, got %v", err)
+	}
+	d.Spec.DeviceProperties["Color"] = devicev1alpha1.DesiredPropertyState{Name: "Color", DesiredParameters: map[string]string{"R": "1"}}
+	if err := cli.UpdatePropertyState(context.TODO(), "Color", d, clients.UpdateOptions{}); !clients.IsInvalidRequestErr(err) {
+		t.Errorf("expected an invalid request error for the missing parameters, got %v", err)
+	}
+	if err := cli.SetCommand(context.TODO(), "Color", d, map[string]string{"R": "1"}, clients.UpdateOptions{}); !clients.IsInvalidRequestErr(err) {
+		t.Errorf("expected the set command with the missing parameters to be rejected, got %v", err)
+	}
+}
+
+func TestLatestPropertiesState(t *testing.T) {
+	s := newTestServer(t)
+	s.AddDeviceProfile(dtos.DeviceProfile{
+		Name: "Color-Light",
+		DeviceResources: []dtos.DeviceResource{
+			{Name: "SwitchState", Properties: dtos.ResourceProperties{ValueType: "Bool", ReadWrite: "RW"}},
+			{Name: "R", Properties: dtos.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+			{Name: "G", Properties: dtos.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+			{Name: "B", Properties: dtos.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+		},
+		DeviceCommands: []dtos.DeviceCommand{
+			{Name: "Switch", ReadWrite: "RW", ResourceOperations: []dtos.ResourceOperation{{DeviceResource: "SwitchState"}}},
+			{Name: "Color", ReadWrite: "RW", ResourceOperations: []dtos.ResourceOperation{
+				{DeviceResource: "R"}, {DeviceResource: "G"}, {DeviceResource: "B"},
+			}},
+		},
+	})
+	cli := newTestDeviceClient(s)
+	d := newTestDevice("color-light")
+	d.Spec.Profile = "Color-Light"
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+	s.SetReading("color-light", "SwitchState", "false")
+	s.SetReading("color-light", "SwitchState", "true")
+	s.SetReading("color-light", "R", "255")
+	s.SetReading("color-light", "G", "128")
+
+	apsm, err := cli.ListLatestPropertiesState(context.TODO(), d, clients.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the latest properties: %v", err)
+	}
+	// the readings are named after the commands like the properties read actively
+	if apsm["Switch"].ActualValue != "true" || apsm["SwitchState"].ActualValue != "true" {
+		t.Errorf("expected the latest reading of SwitchState to be the value of both Switch and SwitchState, got %+v", apsm)
+	}
+	if _, ok := apsm["Color"]; ok {
+		t.Errorf("expected Color not to be reported before all its parameters are read, got %+v", apsm["Color"])
+	}
+
+	s.SetReading("color-light", "B", "0")
+	requests := s.RequestCount()
+	if apsm, err = cli.ListLatestPropertiesState(context.TODO(), d, clients.ListOptions{}); err != nil {
+		t.Fatalf("failed to list the latest properties: %v", err)
+	}
+	// the commands of the deviceProfile are cached, only the latest events are fetched
+	if n := s.RequestCount() - requests; n != 1 {
+		t.Errorf("expected a single request for the latest events, got %d requests", n)
+	}
+	color := map[string]string{"R": "255", "G": "128", "B": "0"}
+	if !reflect.DeepEqual(apsm["Color"].ActualParameters, color) {
+		t.Errorf("expected the actual parameters of Color to be %v, got %+v", color, apsm["Color"])
+	}
+}
+
+func TestGetPropertyStateOfLockedDevice(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	d := newTestDevice("random-integer-device")
+	d.Spec.AdminState = devicev1alpha1.Locked
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+
+	if _, err := cli.GetPropertyState(context.TODO(), "Int8", d, clients.GetOptions{}); !clients.IsLockedErr(err) {
+		t.Errorf("expected reading the property of a locked device to fail with a locked error, got %v", err)
+	}
+}
+
+func TestDeviceCommand(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	d := newTestDevice("random-integer-device")
+	if _, err := cli.Create(context.TODO(), d, clients.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create the device: %v", err)
+	}
+
+	if err := cli.SetCommand(context.TODO(), "Int8", d, map[string]string{"Int8": "42"}, clients.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to run the set command: %v", err)
+	}
+	readings, err := cli.GetCommand(context.TODO(), "Int8", d, clients.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to run the get command: %v", err)
+	}
+	if readings["Int8"] != "42" {
+		t.Errorf("expected the reading of Int8 to be 42, got %+v", readings)
+	}
+
+	if err := cli.SetCommand(context.TODO(), "Int8", d, map[string]string{"Int16": "7"}, clients.UpdateOptions{}); !clients.IsInvalidRequestErr(err) {
+		t.Errorf("expected setting a resource that is not a parameter of the command to be rejected, got %v", err)
+	}
+	if _, err := cli.GetCommand(context.TODO(), "NoSuchCommand", d, clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Errorf("expected an unknown command to be not found, got %v", err)
+	}
+}
+
+func TestDeviceClientFaults(t *testing.T) {
+	setTestResilience(t, Resilience{Retries: 2, RetryWaitTime: time.Millisecond, RetryMaxWaitTime: 10 * time.Millisecond})
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	s.AddDevice(dtos.Device{Name: "random-integer-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device"})
+
+	// a transient failure is retried
+	s.FailRequests(1, http.StatusServiceUnavailable)
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err != nil {
+		t.Errorf("expected the request to succeed after a retry: %v", err)
+	}
+	s.FailRequests(-1, http.StatusServiceUnavailable)
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); !clients.IsRetryableErr(err) {
+		t.Errorf("expected the request to fail with a retryable error, got %v", err)
+	}
+	s.FailRequests(-1, http.StatusInternalServerError)
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err == nil || clients.IsRetryableErr(err) {
+		t.Errorf("expected the request to fail with a non-retryable error, got %v", err)
+	}
+	// the transport retries an idempotent request once on a dropped keep-alive connection
+	s.DropConnections(-1)
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err == nil {
+		t.Errorf("expected the request to fail with a dropped connection")
+	}
+	s.Reset()
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err != nil {
+		t.Errorf("expected the request to succeed after the faults are removed: %v", err)
+	}
+	if _, err := cli.Get(context.TODO(), "random-float-device", clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+}
+
+func TestDeviceClientTypedErrors(t *testing.T) {
+	setTestResilience(t, Resilience{})
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	s.AddDevice(dtos.Device{Name: "random-integer-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device"})
+
+	tests := []struct {
+		statusCode int
+		is         func(error) bool
+	}{
+		{http.StatusBadRequest, clients.IsInvalidRequestErr},
+		{http.StatusNotFound, clients.IsNotFoundErr},
+		{http.StatusConflict, clients.IsConflictErr},
+		{http.StatusLocked, clients.IsLockedErr},
+		{http.StatusServiceUnavailable, clients.IsUnavailableErr},
+		{http.StatusGatewayTimeout, clients.IsTimeoutErr},
+	}
+	for _, tt := range tests {
+		s.FailRequests(1, tt.statusCode)
+		_, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{})
+		var se *clients.StatusError
+		if !tt.is(err) {
+			t.Errorf("status %d: unexpected error %v", tt.statusCode, err)
+		} else if errors.As(err, &se) {
+			t.Errorf("status %d: expected a typed error rather than %v", tt.statusCode, err)
+		}
+	}
+
+	s.FailRequests(1, http.StatusInternalServerError)
+	_, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{})
+	var se *clients.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected a status error of %d, got %v", http.StatusInternalServerError, err)
+	}
+}
+
+func TestDeviceClientHonorsContext(t *testing.T) {
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	s.AddDevice(dtos.Device{Name: "random-integer-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device"})
+	s.SetLatency(5 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := cli.Get(ctx, "random-integer-device", clients.GetOptions{}); !clients.IsTimeoutErr(err) {
+		t.Errorf("expected the request to be aborted by the deadline of the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the request to be aborted within the deadline, took %v", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := cli.List(ctx, clients.ListOptions{}); err == nil {
+		t.Errorf("expected the request to fail with a cancelled context")
+	}
+}
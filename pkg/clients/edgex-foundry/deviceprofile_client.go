@@ -21,10 +21,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 	devcli "github.com/openyurtio/device-controller/pkg/clients"
 
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/common"
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/responses"
 
@@ -34,59 +36,123 @@ import (
 
 type EdgexDeviceProfile struct {
 	*resty.Client
+	// scheme is the scheme of the URLs of EdgeX, https if the client connects over TLS
+	scheme       string
 	CoreMetaAddr string
+	// PageSize is the number of deviceProfiles fetched per request by List and ListPages
+	PageSize int
 }
 
 func NewEdgexDeviceProfile(coreMetaAddr string) *EdgexDeviceProfile {
+	return newEdgexDeviceProfile(plainConnection, coreMetaAddr)
+}
+
+// newEdgexDeviceProfile creates the client connecting to EdgeX with conn
+func newEdgexDeviceProfile(conn *connection, coreMetaAddr string) *EdgexDeviceProfile {
 	return &EdgexDeviceProfile{
-		Client:       resty.New(),
+		scheme:       conn.scheme,
+		Client:       newRestyClient(conn),
 		CoreMetaAddr: coreMetaAddr,
+		PageSize:     DefaultListPageSize,
 	}
 }
 
-// TODO: support label filtering
-func getListDeviceProfileURL(address string, opts devcli.ListOptions) (string, error) {
-	url := fmt.Sprintf("http://%s%s/all?limit=-1", address, DeviceProfilePath)
-	return url, nil
+// getListDeviceProfileURL maps the selectors onto the filtered endpoints of EdgeX, the deviceProfiles are listed
+// by their manufacturer and model if they are selected, otherwise by their labels
+func getListDeviceProfileURL(scheme, address string, opts devcli.ListOptions, offset, limit int) (string, error) {
+	if err := devcli.ValidateFieldSelector("deviceProfiles", opts.FieldSelector, devcli.ManufacturerField, devcli.ModelField); err != nil {
+		return "", err
+	}
+	path := "/all"
+	manufacturer, model := opts.FieldSelector[devcli.ManufacturerField], opts.FieldSelector[devcli.ModelField]
+	switch {
+	case manufacturer != "" && model != "":
+		path = "/manufacturer/" + url.PathEscape(manufacturer) + "/model/" + url.PathEscape(model)
+	case manufacturer != "":
+		path = "/manufacturer/" + url.PathEscape(manufacturer)
+	case model != "":
+		path = "/model/" + url.PathEscape(model)
+	}
+	return fmt.Sprintf("%s://%s%s%s?%s", scheme, address, DeviceProfilePath, path, listQuery(path, opts, offset, limit)), nil
 }
 
 func (cdc *EdgexDeviceProfile) List(ctx context.Context, opts devcli.ListOptions) ([]v1alpha1.DeviceProfile, error) {
 	klog.V(5).Info("will list DeviceProfiles")
-	lp, err := getListDeviceProfileURL(cdc.CoreMetaAddr, opts)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := cdc.R().EnableTrace().Get(lp)
+	var deviceProfiles []v1alpha1.DeviceProfile
+	err := cdc.ListPages(ctx, opts, func(dps []v1alpha1.DeviceProfile) error {
+		deviceProfiles = append(deviceProfiles, dps...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	var mdpResp responses.MultiDeviceProfilesResponse
-	if err := json.Unmarshal(resp.Body(), &mdpResp); err != nil {
-		return nil, err
-	}
-	var deviceProfiles []v1alpha1.DeviceProfile
-	for _, dp := range mdpResp.Profiles {
-		deviceProfiles = append(deviceProfiles, toKubeDeviceProfile(&dp))
-	}
 	return deviceProfiles, nil
 }
 
+// ListPages pages through the deviceProfiles on edge platform which are selected by the options,
+// PageSize deviceProfiles at a time
+func (cdc *EdgexDeviceProfile) ListPages(ctx context.Context, opts devcli.ListOptions, fn func(deviceProfiles []v1alpha1.DeviceProfile) error) error {
+	return listPages(cdc.PageSize, func(offset, limit int) (int, int, error) {
+		lp, err := getListDeviceProfileURL(cdc.scheme, cdc.CoreMetaAddr, opts, offset, limit)
+		if err != nil {
+			return 0, 0, err
+		}
+		request, cancel := newRequest(ctx, cdc.Client)
+		defer cancel()
+		resp, err := request.EnableTrace().Get(lp)
+		if err != nil {
+			return 0, 0, err
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return 0, 0, newResponseError(resp)
+		}
+		var mdpResp responses.MultiDeviceProfilesResponse
+		if err := json.Unmarshal(resp.Body(), &mdpResp); err != nil {
+			return 0, 0, err
+		}
+		var deviceProfiles []v1alpha1.DeviceProfile
+		for _, dp := range mdpResp.Profiles {
+			kubedp := toKubeDeviceProfile(&dp)
+			if opts.Matches(kubedp.Spec.Labels, devcli.DeviceProfileFields(&kubedp)) {
+				deviceProfiles = append(deviceProfiles, kubedp)
+			}
+		}
+		if len(deviceProfiles) != 0 {
+			if err := fn(deviceProfiles); err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(mdpResp.Profiles), int(mdpResp.TotalCount), nil
+	})
+}
+
 func (cdc *EdgexDeviceProfile) Get(ctx context.Context, name string, opts devcli.GetOptions) (*v1alpha1.DeviceProfile, error) {
 	klog.V(5).Infof("will get DeviceProfiles: %s", name)
-	var dpResp responses.DeviceProfileResponse
-	getURL := fmt.Sprintf("http://%s%s/name/%s", cdc.CoreMetaAddr, DeviceProfilePath, name)
-	resp, err := cdc.R().Get(getURL)
+	edp, err := cdc.getEdgeXDeviceProfile(ctx, name)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode() == http.StatusNotFound {
-		return nil, fmt.Errorf("DeviceProfile %s not found", name)
+	kubedp := toKubeDeviceProfile(&edp)
+	return &kubedp, nil
+}
+
+// getEdgeXDeviceProfile gets the deviceProfile as it is on EdgeX
+func (cdc *EdgexDeviceProfile) getEdgeXDeviceProfile(ctx context.Context, name string) (dtos.DeviceProfile, error) {
+	var dpResp responses.DeviceProfileResponse
+	getURL := fmt.Sprintf("%s://%s%s/name/%s", cdc.scheme, cdc.CoreMetaAddr, DeviceProfilePath, name)
+	request, cancel := newRequest(ctx, cdc.Client)
+	defer cancel()
+	resp, err := request.Get(getURL)
+	if err != nil {
+		return dtos.DeviceProfile{}, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return dtos.DeviceProfile{}, newResponseError(resp)
 	}
 	if err = json.Unmarshal(resp.Body(), &dpResp); err != nil {
-		return nil, err
+		return dtos.DeviceProfile{}, err
 	}
-	kubedp := toKubeDeviceProfile(&dpResp.Profile)
-	return &kubedp, nil
+	return dpResp.Profile, nil
 }
 
 func (cdc *EdgexDeviceProfile) Create(ctx context.Context, deviceProfile *v1alpha1.DeviceProfile, opts devcli.CreateOptions) (*v1alpha1.DeviceProfile, error) {
@@ -97,13 +163,15 @@ func (cdc *EdgexDeviceProfile) Create(ctx context.Context, deviceProfile *v1alph
 	if err != nil {
 		return nil, err
 	}
-	postURL := fmt.Sprintf("http://%s%s", cdc.CoreMetaAddr, DeviceProfilePath)
-	resp, err := cdc.R().SetBody(reqBody).Post(postURL)
+	postURL := fmt.Sprintf("%s://%s%s", cdc.scheme, cdc.CoreMetaAddr, DeviceProfilePath)
+	request, cancel := newRequest(ctx, cdc.Client)
+	defer cancel()
+	resp, err := request.SetBody(reqBody).Post(postURL)
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode() != http.StatusMultiStatus {
-		return nil, fmt.Errorf("create edgex deviceProfile err: %s", string(resp.Body())) // 假定 resp.Body() 存了 msg 信息
+		return nil, newResponseError(resp)
 	}
 	var edgexResps []*common.BaseWithIdResponse
 	if err = json.Unmarshal(resp.Body(), &edgexResps); err != nil {
@@ -115,7 +183,7 @@ func (cdc *EdgexDeviceProfile) Create(ctx context.Context, deviceProfile *v1alph
 			createdDeviceProfile.Status.EdgeId = edgexResps[0].Id
 			createdDeviceProfile.Status.Synced = true
 		} else {
-			return nil, fmt.Errorf("create deviceprofile on edgex foundry failed, the response is : %s", resp.Body())
+			return nil, newItemError(resp, edgexResps[0].BaseResponse)
 		}
 	} else {
 		return nil, fmt.Errorf("edgex BaseWithIdResponse count mismatch DeviceProfile count, the response is : %s", resp.Body())
@@ -123,20 +191,58 @@ func (cdc *EdgexDeviceProfile) Create(ctx context.Context, deviceProfile *v1alph
 	return createdDeviceProfile, err
 }
 
-// TODO: edgex does not support update DeviceProfile
+// Update sends a PUT request to EdgeX to replace the deviceProfile with the given one. EdgeX has no partial update
+// of deviceProfiles, if opts.Fields are given, the deviceProfile on EdgeX is fetched and put back with only
+// these fields changed, so that the others are kept as they are on EdgeX
 func (cdc *EdgexDeviceProfile) Update(ctx context.Context, deviceProfile *v1alpha1.DeviceProfile, opts devcli.UpdateOptions) (*v1alpha1.DeviceProfile, error) {
-	return nil, nil
+	edp := toEdgeXDeviceProfile(deviceProfile)
+	if len(opts.Fields) != 0 {
+		var err error
+		if edp, err = cdc.getEdgeXDeviceProfile(ctx, getEdgeDeviceProfileName(deviceProfile)); err != nil {
+			return nil, err
+		}
+		patchEdgeXDeviceProfile(&edp, deviceProfile, opts.Fields)
+	}
+	req := makeEdgeXDeviceProfileDTOsRequest([]dtos.DeviceProfile{edp})
+	klog.V(5).Infof("will update the DeviceProfile: %s", deviceProfile.Name)
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	putURL := fmt.Sprintf("%s://%s%s", cdc.scheme, cdc.CoreMetaAddr, DeviceProfilePath)
+	request, cancel := newRequest(ctx, cdc.Client)
+	defer cancel()
+	resp, err := request.SetBody(reqBody).Put(putURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusMultiStatus {
+		return nil, newResponseError(resp)
+	}
+	var edgexResps []*common.BaseResponse
+	if err = json.Unmarshal(resp.Body(), &edgexResps); err != nil {
+		return nil, err
+	}
+	if len(edgexResps) != 1 {
+		return nil, fmt.Errorf("edgex BaseResponse count mismatch DeviceProfile count, the response is : %s", resp.Body())
+	}
+	if edgexResps[0].StatusCode != http.StatusOK {
+		return nil, newItemError(resp, *edgexResps[0])
+	}
+	return deviceProfile.DeepCopy(), nil
 }
 
 func (cdc *EdgexDeviceProfile) Delete(ctx context.Context, name string, opts devcli.DeleteOptions) error {
 	klog.V(5).Infof("will delete the DeviceProfile: %s", name)
-	delURL := fmt.Sprintf("http://%s%s/name/%s", cdc.CoreMetaAddr, DeviceProfilePath, name)
-	resp, err := cdc.R().Delete(delURL)
+	delURL := fmt.Sprintf("%s://%s%s/name/%s", cdc.scheme, cdc.CoreMetaAddr, DeviceProfilePath, name)
+	request, cancel := newRequest(ctx, cdc.Client)
+	defer cancel()
+	resp, err := request.Delete(delURL)
 	if err != nil {
 		return err
 	}
 	if resp.StatusCode() != http.StatusOK {
-		return fmt.Errorf("delete edgex deviceProfile err: %s", string(resp.Body())) // 假定 resp.Body() 存了 msg 信息
+		return newResponseError(resp)
 	}
 	return nil
 }
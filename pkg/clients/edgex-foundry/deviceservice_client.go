@@ -19,7 +19,6 @@ package edgex_foundry
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 
@@ -35,13 +34,24 @@ import (
 
 type EdgexDeviceServiceClient struct {
 	*resty.Client
+	// scheme is the scheme of the URLs of EdgeX, https if the client connects over TLS
+	scheme       string
 	CoreMetaAddr string
+	// PageSize is the number of deviceServices fetched per request by List and ListPages
+	PageSize int
 }
 
 func NewEdgexDeviceServiceClient(coreMetaAddr string) *EdgexDeviceServiceClient {
+	return newEdgexDeviceServiceClient(plainConnection, coreMetaAddr)
+}
+
+// newEdgexDeviceServiceClient creates the client connecting to EdgeX with conn
+func newEdgexDeviceServiceClient(conn *connection, coreMetaAddr string) *EdgexDeviceServiceClient {
 	return &EdgexDeviceServiceClient{
-		Client:       resty.New(),
+		scheme:       conn.scheme,
+		Client:       newRestyClient(conn),
 		CoreMetaAddr: coreMetaAddr,
+		PageSize:     DefaultListPageSize,
 	}
 }
 
@@ -54,13 +64,15 @@ func (eds *EdgexDeviceServiceClient) Create(ctx context.Context, deviceService *
 	if err != nil {
 		return nil, err
 	}
-	postPath := fmt.Sprintf("http://%s%s", eds.CoreMetaAddr, DeviceServicePath)
-	resp, err := eds.R().
+	postPath := fmt.Sprintf("%s://%s%s", eds.scheme, eds.CoreMetaAddr, DeviceServicePath)
+	request, cancel := newRequest(ctx, eds.Client)
+	defer cancel()
+	resp, err := request.
 		SetBody(jsonBody).Post(postPath)
 	if err != nil {
 		return nil, err
 	} else if resp.StatusCode() != http.StatusMultiStatus {
-		return nil, fmt.Errorf("create DeviceService on edgex foundry failed, the response is : %s", resp.Body())
+		return nil, newResponseError(resp)
 	}
 
 	var edgexResps []*common.BaseWithIdResponse
@@ -73,7 +85,7 @@ func (eds *EdgexDeviceServiceClient) Create(ctx context.Context, deviceService *
 			createdDeviceService.Status.EdgeId = edgexResps[0].Id
 			createdDeviceService.Status.Synced = true
 		} else {
-			return nil, fmt.Errorf("create DeviceService on edgex foundry failed, the response is : %s", resp.Body())
+			return nil, newItemError(resp, edgexResps[0].BaseResponse)
 		}
 	} else {
 		return nil, fmt.Errorf("edgex BaseWithIdResponse count mismatch DeviceService count, the response is : %s", resp.Body())
@@ -84,58 +96,70 @@ func (eds *EdgexDeviceServiceClient) Create(ctx context.Context, deviceService *
 // Delete function sends a request to EdgeX to delete a deviceService
 func (eds *EdgexDeviceServiceClient) Delete(ctx context.Context, name string, option edgeCli.DeleteOptions) error {
 	klog.V(5).InfoS("will delete the DeviceService", "DeviceService", name)
-	delURL := fmt.Sprintf("http://%s%s/name/%s", eds.CoreMetaAddr, DeviceServicePath, name)
-	resp, err := eds.R().Delete(delURL)
+	delURL := fmt.Sprintf("%s://%s%s/name/%s", eds.scheme, eds.CoreMetaAddr, DeviceServicePath, name)
+	request, cancel := newRequest(ctx, eds.Client)
+	defer cancel()
+	resp, err := request.Delete(delURL)
 	if err != nil {
 		return err
 	}
-	if string(resp.Body()) != "true" {
-		return errors.New(string(resp.Body()))
+	if resp.StatusCode() != http.StatusOK {
+		return newResponseError(resp)
 	}
 	return nil
 }
 
-// Update is used to set the admin or operating state of the deviceService by unique name of the deviceService.
-// TODO support to update other fields
+// Update is used to update the fields of the deviceService by unique name of the deviceService,
+// only options.Fields are updated if they are given
 func (eds *EdgexDeviceServiceClient) Update(ctx context.Context, ds *v1alpha1.DeviceService, options edgeCli.UpdateOptions) (*v1alpha1.DeviceService, error) {
-	patchURL := fmt.Sprintf("http://%s%s", eds.CoreMetaAddr, DeviceServicePath)
 	if ds == nil {
 		return nil, nil
 	}
-
-	if ds.Status.EdgeId == "" {
-		return nil, fmt.Errorf("failed to update deviceservice %s with empty edgex id", ds.Name)
-	}
-	edgeDs := toEdgexDeviceService(ds)
-	edgeDs.Id = ds.Status.EdgeId
-	dsJson, err := json.Marshal(&edgeDs)
+	req := makeEdgeXUpdateDeviceServiceRequest([]*v1alpha1.DeviceService{ds}, options.Fields)
+	klog.V(5).InfoS("will update the DeviceService", "DeviceService", getEdgeDeviceServiceName(ds))
+	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := eds.R().
-		SetBody(dsJson).Patch(patchURL)
+	patchURL := fmt.Sprintf("%s://%s%s", eds.scheme, eds.CoreMetaAddr, DeviceServicePath)
+	request, cancel := newRequest(ctx, eds.Client)
+	defer cancel()
+	resp, err := request.
+		SetHeader("Content-Type", "application/json").
+		SetBody(reqBody).
+		Patch(patchURL)
 	if err != nil {
 		return nil, err
+	} else if resp.StatusCode() != http.StatusMultiStatus {
+		return nil, newResponseError(resp)
 	}
 
-	if resp.StatusCode() == http.StatusOK || resp.StatusCode() == http.StatusMultiStatus {
-		return ds, nil
-	} else {
-		return nil, fmt.Errorf("request to patch deviceservice failed, errcode:%d", resp.StatusCode())
+	var edgexResps []*common.BaseResponse
+	if err = json.Unmarshal(resp.Body(), &edgexResps); err != nil {
+		return nil, err
+	}
+	if len(edgexResps) != 1 {
+		return nil, fmt.Errorf("edgex BaseResponse count mismatch deviceService count, the response is : %s", resp.Body())
 	}
+	if edgexResps[0].StatusCode != http.StatusOK {
+		return nil, newItemError(resp, *edgexResps[0])
+	}
+	return ds, nil
 }
 
 // Get is used to query the deviceService information corresponding to the deviceService name
 func (eds *EdgexDeviceServiceClient) Get(ctx context.Context, name string, options edgeCli.GetOptions) (*v1alpha1.DeviceService, error) {
 	klog.V(5).InfoS("will get DeviceServices", "DeviceService", name)
 	var dsResp responses.DeviceServiceResponse
-	getURL := fmt.Sprintf("http://%s%s/name/%s", eds.CoreMetaAddr, DeviceServicePath, name)
-	resp, err := eds.R().Get(getURL)
+	getURL := fmt.Sprintf("%s://%s%s/name/%s", eds.scheme, eds.CoreMetaAddr, DeviceServicePath, name)
+	request, cancel := newRequest(ctx, eds.Client)
+	defer cancel()
+	resp, err := request.Get(getURL)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode() == http.StatusNotFound {
-		return nil, fmt.Errorf("deviceservice %s not found", name)
+	if resp.StatusCode() != http.StatusOK {
+		return nil, newResponseError(resp)
 	}
 	err = json.Unmarshal(resp.Body(), &dsResp)
 	if err != nil {
@@ -145,24 +169,56 @@ func (eds *EdgexDeviceServiceClient) Get(ctx context.Context, name string, optio
 	return &ds, nil
 }
 
-// List is used to get all deviceService objects on edge platform
+// List is used to get the deviceService objects on edge platform which are selected by the labels of the options.
 // The Hanoi version currently supports only a single label and does not support other filters
 func (eds *EdgexDeviceServiceClient) List(ctx context.Context, options edgeCli.ListOptions) ([]v1alpha1.DeviceService, error) {
 	klog.V(5).Info("will list DeviceServices")
-	lp := fmt.Sprintf("http://%s%s/all?limit=-1", eds.CoreMetaAddr, DeviceServicePath)
-	resp, err := eds.R().
-		EnableTrace().
-		Get(lp)
+	var res []v1alpha1.DeviceService
+	err := eds.ListPages(ctx, options, func(dss []v1alpha1.DeviceService) error {
+		res = append(res, dss...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	var mdsResponse responses.MultiDeviceServicesResponse
-	if err := json.Unmarshal(resp.Body(), &mdsResponse); err != nil {
-		return nil, err
-	}
-	var res []v1alpha1.DeviceService
-	for _, ds := range mdsResponse.Services {
-		res = append(res, toKubeDeviceService(ds))
-	}
 	return res, nil
 }
+
+// ListPages pages through the deviceServices on edge platform which are selected by the labels of the options,
+// PageSize deviceServices at a time
+func (eds *EdgexDeviceServiceClient) ListPages(ctx context.Context, options edgeCli.ListOptions, fn func(deviceServices []v1alpha1.DeviceService) error) error {
+	if err := edgeCli.ValidateFieldSelector("deviceServices", options.FieldSelector); err != nil {
+		return err
+	}
+	return listPages(eds.PageSize, func(offset, limit int) (int, int, error) {
+		lp := fmt.Sprintf("%s://%s%s/all?%s", eds.scheme, eds.CoreMetaAddr, DeviceServicePath, listQuery("/all", options, offset, limit))
+		request, cancel := newRequest(ctx, eds.Client)
+		defer cancel()
+		resp, err := request.
+			EnableTrace().
+			Get(lp)
+		if err != nil {
+			return 0, 0, err
+		}
+		if resp.StatusCode() != http.StatusOK {
+			return 0, 0, newResponseError(resp)
+		}
+		var mdsResponse responses.MultiDeviceServicesResponse
+		if err := json.Unmarshal(resp.Body(), &mdsResponse); err != nil {
+			return 0, 0, err
+		}
+		var res []v1alpha1.DeviceService
+		for _, ds := range mdsResponse.Services {
+			kubeds := toKubeDeviceService(ds)
+			if options.Matches(kubeds.Spec.Labels, nil) {
+				res = append(res, kubeds)
+			}
+		}
+		if len(res) != 0 {
+			if err := fn(res); err != nil {
+				return 0, 0, err
+			}
+		}
+		return len(mdsResponse.Services), int(mdsResponse.TotalCount), nil
+	})
+}
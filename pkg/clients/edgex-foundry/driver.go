@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"fmt"
+
+	"github.com/openyurtio/device-controller/pkg/clients"
+)
+
+// DriverName is the name by which the EdgeX Foundry driver is registered
+const DriverName = "edgex-foundry"
+
+func init() {
+	clients.RegisterDriver(DriverName, openDriver)
+}
+
+// edgexDriver creates the clients of EdgeX Foundry
+type edgexDriver struct {
+	cfg clients.DriverConfig
+	// conn is shared by the clients created by the driver
+	conn *connection
+}
+
+// propertyCache is shared by the device clients, so that the reconciler can use the property values read by the syncer
+var propertyCache = clients.NewPropertyCache()
+
+func openDriver(cfg clients.DriverConfig) (clients.Driver, error) {
+	conn, err := configure(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &edgexDriver{cfg: cfg, conn: conn}, nil
+}
+
+func (d *edgexDriver) NewDeviceClient() (clients.DeviceInterface, error) {
+	c := newEdgexDeviceClient(d.conn, d.cfg.CoreMetadataAddr, d.cfg.CoreCommandAddr, d.cfg.CoreDataAddr)
+	c.PageSize = d.cfg.ListPageSize
+	c.Cache = propertyCache
+	return c, nil
+}
+
+func (d *edgexDriver) NewDeviceServiceClient() (clients.DeviceServiceInterface, error) {
+	c := newEdgexDeviceServiceClient(d.conn, d.cfg.CoreMetadataAddr)
+	c.PageSize = d.cfg.ListPageSize
+	return c, nil
+}
+
+func (d *edgexDriver) NewDeviceProfileClient() (clients.DeviceProfileInterface, error) {
+	c := newEdgexDeviceProfile(d.conn, d.cfg.CoreMetadataAddr)
+	c.PageSize = d.cfg.ListPageSize
+	return c, nil
+}
+
+func (d *edgexDriver) NewEventClient() (clients.EventInterface, error) {
+	if d.cfg.MessageBusAddr == "" {
+		return nil, fmt.Errorf("the address of the message bus is not set")
+	}
+	return NewEdgexEventClient(d.cfg.MessageBusAddr, d.cfg.MessageBusBaseTopic), nil
+}
+
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+
+// configure applies the retry and circuit breaker settings to the clients and builds the connection to EdgeX
+// with the security settings, it is only called when the driver is opened at startup
+func configure(cfg clients.DriverConfig) (*connection, error) {
+	r := DefaultResilience
+	r.Retries = cfg.RequestRetries
+	r.FailureThreshold = cfg.BreakerThreshold
+	r.OpenTimeout = cfg.BreakerOpenTimeout
+	SetResilience(r)
+
+	s := Security{
+		TLS:                cfg.TLS,
+		CAFile:             cfg.CAFile,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+		TokenFile:          cfg.TokenFile,
+		TokenSecretKey:     cfg.TokenSecretKey,
+		TokenRefreshPeriod: cfg.TokenRefreshPeriod,
+	}
+	if cfg.TokenFile == "" && cfg.TokenSecretName != "" {
+		s.TokenSecretNamespace, s.TokenSecretName = cfg.TokenSecretNamespace, cfg.TokenSecretName
+		s.SecretReader = cfg.SecretReader
+	}
+	return newConnection(s)
+}
@@ -0,0 +1,394 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgextest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/requests"
+)
+
+// the MQTT 3.1.1 control packet types handled by the Broker
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetUnsubscribe = 10
+	packetUnsuback    = 11
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+)
+
+// Broker is a minimal MQTT 3.1.1 broker standing in for the message bus of EdgeX Foundry.
+// It supports the wildcard subscriptions and delivers all the messages with QoS 0,
+// retained messages, wills and persistent sessions are not supported.
+type Broker struct {
+	listener net.Listener
+
+	mu    sync.Mutex
+	conns map[*brokerConn]struct{}
+	wg    sync.WaitGroup
+}
+
+type brokerConn struct {
+	net.Conn
+	writeMu sync.Mutex
+	// the topic filters subscribed by the client, guarded by the mutex of the broker
+	filters map[string]struct{}
+}
+
+// NewBroker starts a Broker on a local port, the caller should call Close when finished
+func NewBroker() (*Broker, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &Broker{listener: l, conns: map[*brokerConn]struct{}{}}
+	b.wg.Add(1)
+	go b.serve()
+	return b, nil
+}
+
+// Addr returns the address of the broker in the form accepted by the MQTT clients
+func (b *Broker) Addr() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+// Close stops the broker and disconnects all the clients
+func (b *Broker) Close() {
+	b.listener.Close()
+	b.mu.Lock()
+	for c := range b.conns {
+		c.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// DropConnections disconnects all the clients like a restart of the broker, the clients may reconnect
+func (b *Broker) DropConnections() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for c := range b.conns {
+		c.Close()
+	}
+}
+
+// Subscribers returns the number of clients subscribed to the topic
+func (b *Broker) Subscribers(topic string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for c := range b.conns {
+		for filter := range c.filters {
+			if topicMatches(filter, topic) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// Publish delivers the payload to the clients subscribed to the topic
+func (b *Broker) Publish(topic string, payload []byte) {
+	var body []byte
+	body = appendString(body, topic)
+	body = append(body, payload...)
+	packet := appendPacket(nil, packetPublish<<4, body)
+
+	b.mu.Lock()
+	var targets []*brokerConn
+	for c := range b.conns {
+		for filter := range c.filters {
+			if topicMatches(filter, topic) {
+				targets = append(targets, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, c := range targets {
+		c.write(packet)
+	}
+}
+
+// PublishSystemEvent publishes a system event of core-metadata, like EdgeX does when a device,
+// deviceService or deviceProfile is added, updated or deleted. The base topic is usually "edgex".
+func (b *Broker) PublishSystemEvent(baseTopic, eventType, action, name string) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"apiVersion": common.ApiVersion,
+		"type":       eventType,
+		"action":     action,
+		"source":     "core-metadata",
+		"owner":      "",
+		"details":    map[string]string{"name": name},
+		"timestamp":  time.Now().UnixNano(),
+	})
+	if err != nil {
+		return err
+	}
+	topic := strings.Join([]string{baseTopic, "system-events", "core-metadata", eventType, action}, "/")
+	return b.publishEnvelope(topic, payload)
+}
+
+// PublishReadings publishes an event with the readings of a device, like a device service does
+func (b *Broker) PublishReadings(baseTopic, profileName, deviceName, sourceName string, readings map[string]string) error {
+	event := dtos.NewEvent(profileName, deviceName, sourceName)
+	for resource, value := range readings {
+		if err := event.AddSimpleReading(resource, "String", value); err != nil {
+			return err
+		}
+	}
+	payload, err := json.Marshal(requests.NewAddEventRequest(event))
+	if err != nil {
+		return err
+	}
+	topic := strings.Join([]string{baseTopic, "events", "device", profileName, deviceName, sourceName}, "/")
+	return b.publishEnvelope(topic, payload)
+}
+
+func (b *Broker) publishEnvelope(topic string, payload []byte) error {
+	envelope, err := json.Marshal(map[string]interface{}{
+		"correlationID": newID(),
+		"payload":       payload,
+		"contentType":   "application/json",
+	})
+	if err != nil {
+		return err
+	}
+	b.Publish(topic, envelope)
+	return nil
+}
+
+func (b *Broker) serve() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &brokerConn{Conn: conn, filters: map[string]struct{}{}}
+		b.mu.Lock()
+		b.conns[c] = struct{}{}
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handle(c)
+			b.mu.Lock()
+			delete(b.conns, c)
+			b.mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+// handle reads the packets of a client until it disconnects
+func (b *Broker) handle(c *brokerConn) {
+	r := bufio.NewReader(c)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch header >> 4 {
+		case packetConnect:
+			// session present: 0, return code: accepted
+			c.write(appendPacket(nil, packetConnack<<4, []byte{0, 0}))
+		case packetPublish:
+			if err := b.handlePublish(c, header, body); err != nil {
+				return
+			}
+		case packetPuback:
+		case packetSubscribe:
+			if err := b.handleSubscribe(c, body); err != nil {
+				return
+			}
+		case packetUnsubscribe:
+			if err := b.handleUnsubscribe(c, body); err != nil {
+				return
+			}
+		case packetPingreq:
+			c.write(appendPacket(nil, packetPingresp<<4, nil))
+		case packetDisconnect:
+			return
+		default:
+			return
+		}
+	}
+}
+
+func (b *Broker) handlePublish(c *brokerConn, header byte, body []byte) error {
+	topic, rest, err := readString(body)
+	if err != nil {
+		return err
+	}
+	if qos := (header >> 1) & 0x3; qos > 0 {
+		if len(rest) < 2 {
+			return errors.New("publish without packet identifier")
+		}
+		// QoS 2 is acknowledged like QoS 1, the messages are delivered at most once anyway
+		c.write(appendPacket(nil, packetPuback<<4, rest[:2]))
+		rest = rest[2:]
+	}
+	b.Publish(topic, rest)
+	return nil
+}
+
+func (b *Broker) handleSubscribe(c *brokerConn, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("subscribe without packet identifier")
+	}
+	ack := append([]byte{}, body[:2]...)
+	rest := body[2:]
+	for len(rest) > 0 {
+		filter, r, err := readString(rest)
+		if err != nil || len(r) < 1 {
+			return fmt.Errorf("malformed subscribe packet")
+		}
+		rest = r[1:]
+		b.mu.Lock()
+		c.filters[filter] = struct{}{}
+		b.mu.Unlock()
+		// granted QoS 0
+		ack = append(ack, 0)
+	}
+	c.write(appendPacket(nil, packetSuback<<4, ack))
+	return nil
+}
+
+func (b *Broker) handleUnsubscribe(c *brokerConn, body []byte) error {
+	if len(body) < 2 {
+		return errors.New("unsubscribe without packet identifier")
+	}
+	rest := body[2:]
+	for len(rest) > 0 {
+		filter, r, err := readString(rest)
+		if err != nil {
+			return err
+		}
+		rest = r
+		b.mu.Lock()
+		delete(c.filters, filter)
+		b.mu.Unlock()
+	}
+	c.write(appendPacket(nil, packetUnsuback<<4, body[:2]))
+	return nil
+}
+
+func (c *brokerConn) write(packet []byte) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.Write(packet)
+}
+
+// readPacket reads the first byte of the fixed header and the rest of a control packet
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
+
+// appendPacket appends a control packet with the encoded remaining length
+func appendPacket(dst []byte, header byte, body []byte) []byte {
+	dst = append(dst, header)
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		dst = append(dst, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(dst, body...)
+}
+
+func appendString(dst []byte, s string) []byte {
+	dst = append(dst, 0, 0)
+	binary.BigEndian.PutUint16(dst[len(dst)-2:], uint16(len(s)))
+	return append(dst, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("malformed string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("malformed string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// topicMatches checks whether the topic matches the filter with the + and # wildcards
+func topicMatches(filter, topic string) bool {
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if f != "+" && f != ts[i] {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgextest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+	dtoCommon "github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/responses"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+// serveCommand serves the command listing of the devices and the GET/PUT of the commands of core-command
+func (s *Server) serveCommand(w http.ResponseWriter, r *http.Request) {
+	segs, ok := routeSegments(r.URL.Path, apiV2Prefix+"/device")
+	if !ok || len(segs) < 2 || len(segs) > 3 || segs[0] != "name" {
+		writeError(w, http.StatusNotFound, "unknown path %s %s", r.Method, r.URL.Path)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, exist := s.devices[segs[1]]
+	if !exist {
+		writeError(w, http.StatusNotFound, "device %s does not exist", segs[1])
+		return
+	}
+	commands := s.coreCommands(d)
+	if len(segs) == 2 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method %s is not allowed", r.Method)
+			return
+		}
+		writeJSON(w, http.StatusOK, responses.NewDeviceCoreCommandResponse("", "", http.StatusOK, dtos.DeviceCoreCommand{
+			DeviceName:   d.Name,
+			ProfileName:  d.ProfileName,
+			CoreCommands: commands,
+		}))
+		return
+	}
+
+	var command *dtos.CoreCommand
+	for i := range commands {
+		if commands[i].Name == segs[2] {
+			command = &commands[i]
+			break
+		}
+	}
+	switch {
+	case command == nil:
+		writeError(w, http.StatusNotFound, "command %s of device %s does not exist", segs[2], d.Name)
+	case d.AdminState == models.Locked || d.OperatingState == models.Down:
+		writeError(w, http.StatusLocked, "device %s is locked or down", d.Name)
+	case r.Method == http.MethodGet && command.Get:
+		event := dtos.NewEvent(d.ProfileName, d.Name, command.Name)
+		for _, p := range command.Parameters {
+			value, ok := s.readings[d.Name][p.ResourceName]
+			if !ok {
+				value = s.resource(d, p.ResourceName).Properties.DefaultValue
+			}
+			event.Readings = append(event.Readings, s.newReading(d, p.ResourceName, value))
+		}
+		writeJSON(w, http.StatusOK, responses.NewEventResponse("", "", http.StatusOK, event))
+	case r.Method == http.MethodPut && command.Set:
+		var values map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to parse the request: %v", err)
+			return
+		}
+		for name := range values {
+			if !hasParameter(command, name) {
+				writeError(w, http.StatusBadRequest, "resource %s is not a parameter of command %s", name, command.Name)
+				return
+			}
+		}
+		for name, value := range values {
+			s.setReading(d.Name, name, value)
+		}
+		writeJSON(w, http.StatusOK, dtoCommon.NewBaseResponse("", "", http.StatusOK))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method %s is not allowed for command %s", r.Method, command.Name)
+	}
+}
+
+// serveData serves the events of the devices of core-data
+func (s *Server) serveData(w http.ResponseWriter, r *http.Request) {
+	segs, ok := routeSegments(r.URL.Path, apiV2Prefix+"/event/device/name")
+	if !ok || len(segs) != 1 || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "unknown path %s %s", r.Method, r.URL.Path)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := s.events[segs[0]]
+	total := len(events)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < -1 {
+			writeError(w, http.StatusBadRequest, "invalid limit %s", v)
+			return
+		}
+		if limit >= 0 && limit < len(events) {
+			events = events[:limit]
+		}
+	} else if defaultLimit < len(events) {
+		events = events[:defaultLimit]
+	}
+	writeJSON(w, http.StatusOK, responses.NewMultiEventsResponse("", "", http.StatusOK, uint32(total), append([]dtos.Event{}, events...)))
+}
+
+// coreCommands derives the commands of the device from its deviceProfile like core-command,
+// every visible deviceResource and deviceCommand is a command
+func (s *Server) coreCommands(d models.Device) []dtos.CoreCommand {
+	dp, exist := s.profiles[d.ProfileName]
+	if !exist {
+		return nil
+	}
+	var commands []dtos.CoreCommand
+	for _, dc := range dp.DeviceCommands {
+		if dc.IsHidden {
+			continue
+		}
+		command := s.newCoreCommand(d, dc.Name, dc.ReadWrite)
+		for _, ro := range dc.ResourceOperations {
+			command.Parameters = append(command.Parameters, dtos.CoreCommandParameter{
+				ResourceName: ro.DeviceResource,
+				ValueType:    s.resource(d, ro.DeviceResource).Properties.ValueType,
+			})
+		}
+		commands = append(commands, command)
+	}
+	for _, dr := range dp.DeviceResources {
+		if dr.IsHidden {
+			continue
+		}
+		command := s.newCoreCommand(d, dr.Name, dr.Properties.ReadWrite)
+		command.Parameters = []dtos.CoreCommandParameter{{ResourceName: dr.Name, ValueType: dr.Properties.ValueType}}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+func (s *Server) newCoreCommand(d models.Device, name, readWrite string) dtos.CoreCommand {
+	return dtos.CoreCommand{
+		Name: name,
+		Get:  strings.Contains(readWrite, common.ReadWrite_R),
+		Set:  strings.Contains(readWrite, common.ReadWrite_W),
+		Url:  s.Command.URL,
+		Path: fmt.Sprintf("%s/device/name/%s/%s", apiV2Prefix, d.Name, name),
+	}
+}
+
+// resource returns the deviceResource of the device by name, an empty one is returned if it does not exist
+func (s *Server) resource(d models.Device, name string) models.DeviceResource {
+	for _, dr := range s.profiles[d.ProfileName].DeviceResources {
+		if dr.Name == name {
+			return dr
+		}
+	}
+	return models.DeviceResource{}
+}
+
+// newReading returns a reading of the resource, the values of the Binary resources are encoded in base64
+// and the values of the Object resources are JSON
+func (s *Server) newReading(d models.Device, resourceName, value string) dtos.BaseReading {
+	properties := s.resource(d, resourceName).Properties
+	valueType := properties.ValueType
+	if valueType == "" {
+		valueType = common.ValueTypeString
+	}
+	reading := dtos.BaseReading{
+		Id:           newID(),
+		Origin:       time.Now().UnixNano(),
+		DeviceName:   d.Name,
+		ResourceName: resourceName,
+		ProfileName:  d.ProfileName,
+		ValueType:    valueType,
+	}
+	switch valueType {
+	case common.ValueTypeBinary:
+		reading.BinaryValue, _ = base64.StdEncoding.DecodeString(value)
+		reading.MediaType = properties.MediaType
+	case common.ValueTypeObject:
+		_ = json.Unmarshal([]byte(value), &reading.ObjectValue)
+	default:
+		reading.Value = value
+	}
+	return reading
+}
+
+func hasParameter(command *dtos.CoreCommand, resourceName string) bool {
+	for _, p := range command.Parameters {
+		if p.ResourceName == resourceName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,402 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgextest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/requests"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/responses"
+)
+
+// defaultLimit is the number of objects returned by the /all endpoints when the limit is not specified
+const defaultLimit = 20
+
+// serveMetadata serves the device, deviceprofile and deviceservice endpoints of core-metadata
+func (s *Server) serveMetadata(w http.ResponseWriter, r *http.Request) {
+	if segs, ok := routeSegments(r.URL.Path, apiV2Prefix+"/deviceprofile"); ok {
+		s.serveDeviceProfile(w, r, segs)
+	} else if segs, ok := routeSegments(r.URL.Path, apiV2Prefix+"/deviceservice"); ok {
+		s.serveDeviceService(w, r, segs)
+	} else if segs, ok := routeSegments(r.URL.Path, apiV2Prefix+"/device"); ok {
+		s.serveDevice(w, r, segs)
+	} else {
+		writeError(w, http.StatusNotFound, "unknown path %s", r.URL.Path)
+	}
+}
+
+func (s *Server) serveDevice(w http.ResponseWriter, r *http.Request, segs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case len(segs) == 0 && r.Method == http.MethodPost:
+		var reqs []requests.AddDeviceRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to parse the request: %v", err)
+			return
+		}
+		var resps []common.BaseWithIdResponse
+		for _, req := range reqs {
+			d := req.Device
+			if _, exist := s.devices[d.Name]; exist {
+				resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "device "+d.Name+" already exists", http.StatusConflict, ""))
+			} else if _, exist := s.services[d.ServiceName]; !exist {
+				resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "device service "+d.ServiceName+" does not exist", http.StatusNotFound, ""))
+			} else if _, exist := s.profiles[d.ProfileName]; !exist {
+				resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "device profile "+d.ProfileName+" does not exist", http.StatusNotFound, ""))
+			} else {
+				d.Id = newID()
+				s.devices[d.Name] = dtos.ToDeviceModel(d)
+				resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "", http.StatusCreated, d.Id))
+			}
+		}
+		writeJSON(w, http.StatusMultiStatus, resps)
+	case len(segs) == 0 && r.Method == http.MethodPatch:
+		var reqs []requests.UpdateDeviceRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to parse the request: %v", err)
+			return
+		}
+		var resps []common.BaseResponse
+		for _, req := range reqs {
+			name := s.deviceNameOf(req.Device.Id, req.Device.Name)
+			d, exist := s.devices[name]
+			if !exist {
+				resps = append(resps, common.NewBaseResponse(req.RequestId, "device does not exist", http.StatusNotFound))
+				continue
+			}
+			if sn := req.Device.ServiceName; sn != nil {
+				if _, exist := s.services[*sn]; !exist {
+					resps = append(resps, common.NewBaseResponse(req.RequestId, "device service "+*sn+" does not exist", http.StatusNotFound))
+					continue
+				}
+			}
+			if pn := req.Device.ProfileName; pn != nil {
+				if _, exist := s.profiles[*pn]; !exist {
+					resps = append(resps, common.NewBaseResponse(req.RequestId, "device profile "+*pn+" does not exist", http.StatusNotFound))
+					continue
+				}
+			}
+			requests.ReplaceDeviceModelFieldsWithDTO(&d, req.Device)
+			s.devices[name] = d
+			resps = append(resps, common.NewBaseResponse(req.RequestId, "", http.StatusOK))
+		}
+		writeJSON(w, http.StatusMultiStatus, resps)
+	case r.Method == http.MethodGet && (len(segs) == 1 && segs[0] == "all" ||
+		len(segs) == 3 && (segs[0] == "service" || segs[0] == "profile") && segs[1] == "name"):
+		labels := queryLabels(r)
+		var names []string
+		for name, d := range s.devices {
+			if len(segs) == 3 && (segs[0] == "service" && d.ServiceName != segs[2] || segs[0] == "profile" && d.ProfileName != segs[2]) {
+				continue
+			}
+			if hasAnyLabel(d.Labels, labels) {
+				names = append(names, name)
+			}
+		}
+		total := len(names)
+		names, ok := page(w, r, names)
+		if !ok {
+			return
+		}
+		devices := []dtos.Device{}
+		for _, name := range names {
+			devices = append(devices, dtos.FromDeviceModelToDTO(s.devices[name]))
+		}
+		writeJSON(w, http.StatusOK, responses.NewMultiDevicesResponse("", "", http.StatusOK, uint32(total), devices))
+	case len(segs) == 2 && segs[0] == "name" && r.Method == http.MethodGet:
+		d, exist := s.devices[segs[1]]
+		if !exist {
+			writeError(w, http.StatusNotFound, "device %s does not exist", segs[1])
+			return
+		}
+		writeJSON(w, http.StatusOK, responses.NewDeviceResponse("", "", http.StatusOK, dtos.FromDeviceModelToDTO(d)))
+	case len(segs) == 2 && segs[0] == "name" && r.Method == http.MethodDelete:
+		if _, exist := s.devices[segs[1]]; !exist {
+			writeError(w, http.StatusNotFound, "device %s does not exist", segs[1])
+			return
+		}
+		delete(s.devices, segs[1])
+		delete(s.readings, segs[1])
+		delete(s.events, segs[1])
+		writeJSON(w, http.StatusOK, common.NewBaseResponse("", "", http.StatusOK))
+	default:
+		writeError(w, http.StatusNotFound, "unknown path %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func (s *Server) serveDeviceProfile(w http.ResponseWriter, r *http.Request, segs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case len(segs) == 0 && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		var reqs []requests.DeviceProfileRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to parse the request: %v", err)
+			return
+		}
+		if r.Method == http.MethodPost {
+			var resps []common.BaseWithIdResponse
+			for _, req := range reqs {
+				dp := req.Profile
+				if _, exist := s.profiles[dp.Name]; exist {
+					resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "device profile "+dp.Name+" already exists", http.StatusConflict, ""))
+					continue
+				}
+				dp.Id = newID()
+				s.profiles[dp.Name] = dtos.ToDeviceProfileModel(dp)
+				resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "", http.StatusCreated, dp.Id))
+			}
+			writeJSON(w, http.StatusMultiStatus, resps)
+			return
+		}
+		var resps []common.BaseResponse
+		for _, req := range reqs {
+			dp := req.Profile
+			old, exist := s.profiles[dp.Name]
+			if !exist {
+				resps = append(resps, common.NewBaseResponse(req.RequestId, "device profile "+dp.Name+" does not exist", http.StatusNotFound))
+				continue
+			}
+			dp.Id = old.Id
+			s.profiles[dp.Name] = dtos.ToDeviceProfileModel(dp)
+			resps = append(resps, common.NewBaseResponse(req.RequestId, "", http.StatusOK))
+		}
+		writeJSON(w, http.StatusMultiStatus, resps)
+	case r.Method == http.MethodGet && (len(segs) == 1 && segs[0] == "all" ||
+		len(segs) == 2 && (segs[0] == "manufacturer" || segs[0] == "model") ||
+		len(segs) == 4 && segs[0] == "manufacturer" && segs[2] == "model"):
+		labels := queryLabels(r)
+		var names []string
+		for name, dp := range s.profiles {
+			if len(segs) >= 2 && segs[0] == "manufacturer" && dp.Manufacturer != segs[1] ||
+				len(segs) == 2 && segs[0] == "model" && dp.Model != segs[1] ||
+				len(segs) == 4 && dp.Model != segs[3] {
+				continue
+			}
+			if hasAnyLabel(dp.Labels, labels) {
+				names = append(names, name)
+			}
+		}
+		total := len(names)
+		names, ok := page(w, r, names)
+		if !ok {
+			return
+		}
+		profiles := []dtos.DeviceProfile{}
+		for _, name := range names {
+			profiles = append(profiles, dtos.FromDeviceProfileModelToDTO(s.profiles[name]))
+		}
+		writeJSON(w, http.StatusOK, responses.NewMultiDeviceProfilesResponse("", "", http.StatusOK, uint32(total), profiles))
+	case len(segs) == 2 && segs[0] == "name" && r.Method == http.MethodGet:
+		dp, exist := s.profiles[segs[1]]
+		if !exist {
+			writeError(w, http.StatusNotFound, "device profile %s does not exist", segs[1])
+			return
+		}
+		writeJSON(w, http.StatusOK, responses.NewDeviceProfileResponse("", "", http.StatusOK, dtos.FromDeviceProfileModelToDTO(dp)))
+	case len(segs) == 2 && segs[0] == "name" && r.Method == http.MethodDelete:
+		if _, exist := s.profiles[segs[1]]; !exist {
+			writeError(w, http.StatusNotFound, "device profile %s does not exist", segs[1])
+			return
+		}
+		for _, d := range s.devices {
+			if d.ProfileName == segs[1] {
+				writeError(w, http.StatusConflict, "device profile %s is still referenced by device %s", segs[1], d.Name)
+				return
+			}
+		}
+		delete(s.profiles, segs[1])
+		writeJSON(w, http.StatusOK, common.NewBaseResponse("", "", http.StatusOK))
+	default:
+		writeError(w, http.StatusNotFound, "unknown path %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func (s *Server) serveDeviceService(w http.ResponseWriter, r *http.Request, segs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case len(segs) == 0 && r.Method == http.MethodPost:
+		var reqs []requests.AddDeviceServiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to parse the request: %v", err)
+			return
+		}
+		var resps []common.BaseWithIdResponse
+		for _, req := range reqs {
+			ds := req.Service
+			if _, exist := s.services[ds.Name]; exist {
+				resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "device service "+ds.Name+" already exists", http.StatusConflict, ""))
+				continue
+			}
+			ds.Id = newID()
+			s.services[ds.Name] = dtos.ToDeviceServiceModel(ds)
+			resps = append(resps, common.NewBaseWithIdResponse(req.RequestId, "", http.StatusCreated, ds.Id))
+		}
+		writeJSON(w, http.StatusMultiStatus, resps)
+	case len(segs) == 0 && r.Method == http.MethodPatch:
+		var reqs []requests.UpdateDeviceServiceRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			writeError(w, http.StatusBadRequest, "failed to parse the request: %v", err)
+			return
+		}
+		var resps []common.BaseResponse
+		for _, req := range reqs {
+			name := s.deviceServiceNameOf(req.Service.Id, req.Service.Name)
+			ds, exist := s.services[name]
+			if !exist {
+				resps = append(resps, common.NewBaseResponse(req.RequestId, "device service does not exist", http.StatusNotFound))
+				continue
+			}
+			requests.ReplaceDeviceServiceModelFieldsWithDTO(&ds, req.Service)
+			s.services[name] = ds
+			resps = append(resps, common.NewBaseResponse(req.RequestId, "", http.StatusOK))
+		}
+		writeJSON(w, http.StatusMultiStatus, resps)
+	case len(segs) == 1 && segs[0] == "all" && r.Method == http.MethodGet:
+		labels := queryLabels(r)
+		var names []string
+		for name, ds := range s.services {
+			if hasAnyLabel(ds.Labels, labels) {
+				names = append(names, name)
+			}
+		}
+		total := len(names)
+		names, ok := page(w, r, names)
+		if !ok {
+			return
+		}
+		services := []dtos.DeviceService{}
+		for _, name := range names {
+			services = append(services, dtos.FromDeviceServiceModelToDTO(s.services[name]))
+		}
+		writeJSON(w, http.StatusOK, responses.NewMultiDeviceServicesResponse("", "", http.StatusOK, uint32(total), services))
+	case len(segs) == 2 && segs[0] == "name" && r.Method == http.MethodGet:
+		ds, exist := s.services[segs[1]]
+		if !exist {
+			writeError(w, http.StatusNotFound, "device service %s does not exist", segs[1])
+			return
+		}
+		writeJSON(w, http.StatusOK, responses.NewDeviceServiceResponse("", "", http.StatusOK, dtos.FromDeviceServiceModelToDTO(ds)))
+	case len(segs) == 2 && segs[0] == "name" && r.Method == http.MethodDelete:
+		if _, exist := s.services[segs[1]]; !exist {
+			writeError(w, http.StatusNotFound, "device service %s does not exist", segs[1])
+			return
+		}
+		for _, d := range s.devices {
+			if d.ServiceName == segs[1] {
+				writeError(w, http.StatusConflict, "device service %s is still referenced by device %s", segs[1], d.Name)
+				return
+			}
+		}
+		delete(s.services, segs[1])
+		writeJSON(w, http.StatusOK, common.NewBaseResponse("", "", http.StatusOK))
+	default:
+		writeError(w, http.StatusNotFound, "unknown path %s %s", r.Method, r.URL.Path)
+	}
+}
+
+// deviceNameOf resolves the name of the device to be updated, the id takes precedence over the name
+func (s *Server) deviceNameOf(id, name *string) string {
+	if id != nil {
+		for n, d := range s.devices {
+			if d.Id == *id {
+				return n
+			}
+		}
+		return ""
+	}
+	if name != nil {
+		return *name
+	}
+	return ""
+}
+
+// deviceServiceNameOf resolves the name of the deviceService to be updated, the id takes precedence over the name
+func (s *Server) deviceServiceNameOf(id, name *string) string {
+	if id != nil {
+		for n, ds := range s.services {
+			if ds.Id == *id {
+				return n
+			}
+		}
+		return ""
+	}
+	if name != nil {
+		return *name
+	}
+	return ""
+}
+
+// page sorts the names and applies the offset and limit query parameters like EdgeX,
+// a limit of -1 returns all the objects
+func page(w http.ResponseWriter, r *http.Request, names []string) ([]string, bool) {
+	sort.Strings(names)
+	offset, limit := 0, defaultLimit
+	var err error
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "invalid offset %s", v)
+			return nil, false
+		}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < -1 {
+			writeError(w, http.StatusBadRequest, "invalid limit %s", v)
+			return nil, false
+		}
+	}
+	if offset > len(names) {
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, "offset %d is larger than the total count %d", offset, len(names))
+		return nil, false
+	}
+	names = names[offset:]
+	if limit >= 0 && limit < len(names) {
+		names = names[:limit]
+	}
+	return names, true
+}
+
+// queryLabels returns the comma separated labels of the query
+func queryLabels(r *http.Request) []string {
+	if v := r.URL.Query().Get("labels"); v != "" {
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+// hasAnyLabel returns true if the object has any of the queried labels, or no label is queried
+func hasAnyLabel(labels, queried []string) bool {
+	if len(queried) == 0 {
+		return true
+	}
+	for _, q := range queried {
+		for _, l := range labels {
+			if l == q {
+				return true
+			}
+		}
+	}
+	return false
+}
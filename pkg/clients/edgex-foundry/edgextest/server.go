@@ -0,0 +1,296 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package edgextest provides a stand-in for the EdgeX Foundry v2 core services used by the edgex_foundry clients.
+// It keeps the devices, deviceProfiles, deviceServices and readings in memory and allows to inject faults.
+package edgextest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/common"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/models"
+)
+
+const apiV2Prefix = "/api/v2"
+
+// Server emulates the core-metadata, core-command and core-data services of EdgeX Foundry,
+// each of them listens on its own address like in a real deployment.
+type Server struct {
+	Metadata *httptest.Server
+	Command  *httptest.Server
+	Data     *httptest.Server
+
+	mu       sync.Mutex
+	devices  map[string]models.Device
+	profiles map[string]models.DeviceProfile
+	services map[string]models.DeviceService
+	// readings holds the latest value of each resource of the devices
+	readings map[string]map[string]string
+	// events holds the events reported by the devices, from newest to oldest
+	events map[string][]dtos.Event
+
+	// fault injection
+	latency      time.Duration
+	failures     int
+	failureCode  int
+	drops        int
+	requestCount int
+}
+
+// NewServer starts a Server, the caller should call Close when finished
+func NewServer() *Server {
+	s := &Server{
+		devices:  map[string]models.Device{},
+		profiles: map[string]models.DeviceProfile{},
+		services: map[string]models.DeviceService{},
+		readings: map[string]map[string]string{},
+		events:   map[string][]dtos.Event{},
+	}
+	s.Metadata = httptest.NewServer(s.withFaults(http.HandlerFunc(s.serveMetadata)))
+	s.Command = httptest.NewServer(s.withFaults(http.HandlerFunc(s.serveCommand)))
+	s.Data = httptest.NewServer(s.withFaults(http.HandlerFunc(s.serveData)))
+	return s
+}
+
+// Close shuts down all the services
+func (s *Server) Close() {
+	s.Metadata.Close()
+	s.Command.Close()
+	s.Data.Close()
+}
+
+// MetadataAddr returns the address of core-metadata in the form of host:port
+func (s *Server) MetadataAddr() string { return s.Metadata.Listener.Addr().String() }
+
+// CommandAddr returns the address of core-command in the form of host:port
+func (s *Server) CommandAddr() string { return s.Command.Listener.Addr().String() }
+
+// DataAddr returns the address of core-data in the form of host:port
+func (s *Server) DataAddr() string { return s.Data.Listener.Addr().String() }
+
+// SetLatency delays every response by d
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailRequests makes the next n requests fail with the given status code, such as 500 or 503.
+// A negative n fails all the requests until Reset is called.
+func (s *Server) FailRequests(n int, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+	s.failureCode = statusCode
+}
+
+// DropConnections makes the services close the connections of the next n requests without responding.
+// A negative n drops all the requests until Reset is called.
+func (s *Server) DropConnections(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops = n
+}
+
+// Reset removes all the injected faults
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = 0
+	s.failures = 0
+	s.failureCode = 0
+	s.drops = 0
+}
+
+// RequestCount returns the number of requests received by all the services
+func (s *Server) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requestCount
+}
+
+// AddDevice puts the device to core-metadata directly, the id is generated if it is empty
+func (s *Server) AddDevice(d dtos.Device) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d.Id == "" {
+		d.Id = newID()
+	}
+	s.devices[d.Name] = dtos.ToDeviceModel(d)
+	return d.Id
+}
+
+// AddDeviceProfile puts the deviceProfile to core-metadata directly, the id is generated if it is empty
+func (s *Server) AddDeviceProfile(dp dtos.DeviceProfile) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dp.Id == "" {
+		dp.Id = newID()
+	}
+	s.profiles[dp.Name] = dtos.ToDeviceProfileModel(dp)
+	return dp.Id
+}
+
+// AddDeviceService puts the deviceService to core-metadata directly, the id is generated if it is empty
+func (s *Server) AddDeviceService(ds dtos.DeviceService) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ds.Id == "" {
+		ds.Id = newID()
+	}
+	s.services[ds.Name] = dtos.ToDeviceServiceModel(ds)
+	return ds.Id
+}
+
+// Device returns the device stored in core-metadata
+func (s *Server) Device(name string) (dtos.Device, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.devices[name]
+	return dtos.FromDeviceModelToDTO(d), ok
+}
+
+// DeviceProfile returns the deviceProfile stored in core-metadata
+func (s *Server) DeviceProfile(name string) (dtos.DeviceProfile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dp, ok := s.profiles[name]
+	return dtos.FromDeviceProfileModelToDTO(dp), ok
+}
+
+// DeviceService returns the deviceService stored in core-metadata
+func (s *Server) DeviceService(name string) (dtos.DeviceService, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ds, ok := s.services[name]
+	return dtos.FromDeviceServiceModelToDTO(ds), ok
+}
+
+// SetReading sets the value of a resource of the device as if it was reported by the device,
+// the reading is also published to core-data as a new event
+func (s *Server) SetReading(deviceName, resourceName, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setReading(deviceName, resourceName, value)
+	d := s.devices[deviceName]
+	event := dtos.NewEvent(d.ProfileName, deviceName, resourceName)
+	event.Readings = append(event.Readings, s.newReading(d, resourceName, value))
+	s.events[deviceName] = append([]dtos.Event{event}, s.events[deviceName]...)
+}
+
+// Reading returns the latest value of a resource of the device
+func (s *Server) Reading(deviceName, resourceName string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.readings[deviceName][resourceName]
+	return v, ok
+}
+
+func (s *Server) setReading(deviceName, resourceName, value string) {
+	if _, ok := s.readings[deviceName]; !ok {
+		s.readings[deviceName] = map[string]string{}
+	}
+	s.readings[deviceName][resourceName] = value
+}
+
+// withFaults applies the injected faults before handing the request to the service
+func (s *Server) withFaults(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requestCount++
+		latency := s.latency
+		drop := takeFault(&s.drops)
+		failureCode := 0
+		if !drop && takeFault(&s.failures) {
+			failureCode = s.failureCode
+		}
+		s.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if drop {
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			panic(http.ErrAbortHandler)
+		}
+		if failureCode != 0 {
+			writeJSON(w, failureCode, common.NewBaseResponse("", http.StatusText(failureCode), failureCode))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// takeFault consumes one fault of the counter, a negative counter never runs out
+func takeFault(counter *int) bool {
+	if *counter == 0 {
+		return false
+	}
+	if *counter > 0 {
+		*counter--
+	}
+	return true
+}
+
+// routeSegments splits the path after the prefix into segments,
+// e.g. /api/v2/device/name/foo/bar with prefix /api/v2/device gives [name foo bar]
+func routeSegments(path, prefix string) ([]string, bool) {
+	if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+		return nil, false
+	}
+	rest := strings.Trim(strings.TrimPrefix(path, prefix), "/")
+	if rest == "" {
+		return []string{}, true
+	}
+	return strings.Split(rest, "/"), true
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, format string, args ...interface{}) {
+	writeJSON(w, statusCode, common.NewBaseResponse("", fmt.Sprintf(format, args...), statusCode))
+}
+
+// newID generates a random UUID like the ids assigned by EdgeX
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
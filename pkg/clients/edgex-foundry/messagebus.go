@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/openyurtio/device-controller/pkg/clients"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/requests"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// SystemEventsTopic is the topic core-metadata publishes the changes of the devices,
+	// deviceServices and deviceProfiles under, it is followed by <type>/<action>/<owner>/<profile>
+	SystemEventsTopic = "system-events/core-metadata"
+	// EventsTopic is the topic the device services and core-data publish the events with readings under
+	EventsTopic = "events"
+
+	mqttConnectTimeout = 10 * time.Second
+	mqttQuiesce        = 250
+)
+
+// messageEnvelope is the message published on the EdgeX message bus, the payload is encoded in base64
+type messageEnvelope struct {
+	CorrelationID string `json:"correlationID"`
+	Payload       []byte `json:"payload"`
+	ContentType   string `json:"contentType"`
+}
+
+// systemEvent is the payload of the system events published by core-metadata,
+// the details are the DTO of the changed object
+type systemEvent struct {
+	Type    string          `json:"type"`
+	Action  string          `json:"action"`
+	Source  string          `json:"source"`
+	Details json.RawMessage `json:"details"`
+}
+
+// the types of the system events
+var systemEventKinds = map[string]clients.EventKind{
+	"device":        clients.DeviceEventKind,
+	"deviceservice": clients.DeviceServiceEventKind,
+	"deviceprofile": clients.DeviceProfileEventKind,
+}
+
+// EdgexEventClient subscribes to the system events and readings on the MQTT message bus of EdgeX
+type EdgexEventClient struct {
+	BrokerAddr string
+	BaseTopic  string
+}
+
+var _ clients.EventInterface = &EdgexEventClient{}
+
+func NewEdgexEventClient(brokerAddr, baseTopic string) *EdgexEventClient {
+	return &EdgexEventClient{
+		BrokerAddr: brokerAddr,
+		BaseTopic:  strings.TrimSuffix(baseTopic, "/"),
+	}
+}
+
+// Subscribe connects to the broker and subscribes to the system events and readings,
+// the subscriptions are restored after reconnecting and followed by a resync event since the session is
+// clean and the messages published while disconnected are lost
+func (ec *EdgexEventClient) Subscribe(ctx context.Context, handler clients.EventHandler) error {
+	topics := map[string]byte{
+		ec.BaseTopic + "/" + SystemEventsTopic + "/#": 0,
+		ec.BaseTopic + "/" + EventsTopic + "/#":       0,
+	}
+	// the messages are delivered to the handler one by one from the goroutine of the paho router
+	onMessage := func(_ mqtt.Client, msg mqtt.Message) {
+		e, ok, err := ec.parseMessage(msg.Topic(), msg.Payload())
+		if err != nil {
+			klog.V(4).ErrorS(err, "fail to parse the message from the message bus", "Topic", msg.Topic())
+			return
+		}
+		if ok {
+			handler(e)
+		}
+	}
+
+	var connects int32
+	opts := mqtt.NewClientOptions().
+		AddBroker(ec.BrokerAddr).
+		SetClientID("yurt-device-controller-" + rand.String(8)).
+		SetCleanSession(true).
+		SetAutoReconnect(true).
+		SetConnectTimeout(mqttConnectTimeout).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			reconnected := atomic.AddInt32(&connects, 1) > 1
+			token := c.SubscribeMultiple(topics, onMessage)
+			go func() {
+				if token.WaitTimeout(mqttConnectTimeout) && token.Error() == nil {
+					klog.V(2).InfoS("subscribed to the message bus", "Broker", ec.BrokerAddr, "Reconnected", reconnected)
+					if reconnected {
+						handler(clients.Event{Action: clients.ResyncAction})
+					}
+					return
+				}
+				klog.V(3).ErrorS(token.Error(), "fail to subscribe to the message bus", "Broker", ec.BrokerAddr)
+			}()
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			klog.V(3).ErrorS(err, "lost the connection to the message bus", "Broker", ec.BrokerAddr)
+		})
+
+	c := mqtt.NewClient(opts)
+	token := c.Connect()
+	if !token.WaitTimeout(mqttConnectTimeout) {
+		c.Disconnect(0)
+		return fmt.Errorf("timed out connecting to the message bus %s", ec.BrokerAddr)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("fail to connect to the message bus %s: %v", ec.BrokerAddr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		c.Disconnect(mqttQuiesce)
+	}()
+	return nil
+}
+
+// parseMessage converts a message of the message bus to an event, ok is false if the message is not interesting
+func (ec *EdgexEventClient) parseMessage(topic string, payload []byte) (e clients.Event, ok bool, err error) {
+	var env messageEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return e, false, err
+	}
+	switch {
+	case strings.HasPrefix(topic, ec.BaseTopic+"/"+SystemEventsTopic+"/"):
+		return parseSystemEvent(env.Payload)
+	case strings.HasPrefix(topic, ec.BaseTopic+"/"+EventsTopic+"/"):
+		return parseReadingEvent(env.Payload)
+	}
+	return e, false, nil
+}
+
+func parseSystemEvent(payload []byte) (e clients.Event, ok bool, err error) {
+	var se systemEvent
+	if err := json.Unmarshal(payload, &se); err != nil {
+		return e, false, err
+	}
+	kind, ok := systemEventKinds[strings.ToLower(se.Type)]
+	if !ok {
+		return e, false, nil
+	}
+	action := clients.EventAction(strings.ToLower(se.Action))
+	switch action {
+	case clients.AddAction, clients.UpdateAction, clients.DeleteAction:
+	default:
+		return e, false, nil
+	}
+	var details struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(se.Details, &details); err != nil {
+		return e, false, err
+	}
+	if details.Name == "" {
+		return e, false, fmt.Errorf("%s %s event without the object name", se.Type, se.Action)
+	}
+	return clients.Event{Kind: kind, Action: action, Name: details.Name}, true, nil
+}
+
+// parseReadingEvent parses the events published by the device services or core-data,
+// both of them publish the AddEventRequest, the plain event is accepted as well
+func parseReadingEvent(payload []byte) (e clients.Event, ok bool, err error) {
+	var req requests.AddEventRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return e, false, err
+	}
+	event := req.Event
+	if event.DeviceName == "" {
+		var plain dtos.Event
+		if err := json.Unmarshal(payload, &plain); err != nil {
+			return e, false, err
+		}
+		event = plain
+	}
+	if event.DeviceName == "" {
+		return e, false, fmt.Errorf("event without the device name")
+	}
+	readings := map[string]string{}
+	for _, r := range event.Readings {
+		readings[r.ResourceName] = getReadingValue(r)
+	}
+	return clients.Event{
+		Kind:     clients.DeviceEventKind,
+		Action:   clients.ReadingAction,
+		Name:     event.DeviceName,
+		Readings: readings,
+	}, true, nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/device-controller/pkg/clients"
+	"github.com/openyurtio/device-controller/pkg/clients/edgex-foundry/edgextest"
+)
+
+func TestEventClientSubscribe(t *testing.T) {
+	b, err := edgextest.NewBroker()
+	if err != nil {
+		t.Fatalf("fail to start the broker: %v", err)
+	}
+	t.Cleanup(b.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	events := make(chan clients.Event, 10)
+	ec := NewEdgexEventClient(b.Addr(), "edgex")
+	if err := ec.Subscribe(ctx, func(e clients.Event) { events <- e }); err != nil {
+		t.Fatalf("fail to subscribe: %v", err)
+	}
+	// the subscriptions are made asynchronously once connected
+	deadline := time.Now().Add(5 * time.Second)
+	for b.Subscribers("edgex/events/device/p/d/s") == 0 || b.Subscribers("edgex/system-events/core-metadata/device/add") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the client didn't subscribe to the message bus")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expect := func(want clients.Event) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected event %+v, got %+v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("event %+v not received within a second", want)
+		}
+	}
+
+	if err := b.PublishSystemEvent("edgex", "device", "add", "device-int-01"); err != nil {
+		t.Fatal(err)
+	}
+	expect(clients.Event{Kind: clients.DeviceEventKind, Action: clients.AddAction, Name: "device-int-01"})
+
+	if err := b.PublishSystemEvent("edgex", "deviceprofile", "delete", "Random-Integer-Device"); err != nil {
+		t.Fatal(err)
+	}
+	expect(clients.Event{Kind: clients.DeviceProfileEventKind, Action: clients.DeleteAction, Name: "Random-Integer-Device"})
+
+	// the events of the other types are ignored
+	if err := b.PublishSystemEvent("edgex", "provisionwatcher", "add", "watcher"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.PublishSystemEvent("edgex", "deviceservice", "update", "device-virtual"); err != nil {
+		t.Fatal(err)
+	}
+	expect(clients.Event{Kind: clients.DeviceServiceEventKind, Action: clients.UpdateAction, Name: "device-virtual"})
+
+	if err := b.PublishReadings("edgex", "Random-Integer-Device", "device-int-01", "Int8", map[string]string{"Int8": "12"}); err != nil {
+		t.Fatal(err)
+	}
+	expect(clients.Event{Kind: clients.DeviceEventKind, Action: clients.ReadingAction, Name: "device-int-01",
+		Readings: map[string]string{"Int8": "12"}})
+
+	// nothing is delivered after the context is done
+	cancel()
+	time.Sleep(500 * time.Millisecond)
+	b.PublishSystemEvent("edgex", "device", "delete", "device-int-01")
+	select {
+	case e := <-events:
+		t.Errorf("unexpected event %+v after unsubscribing", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestEventClientResyncsAfterReconnecting(t *testing.T) {
+	b, err := edgextest.NewBroker()
+	if err != nil {
+		t.Fatalf("fail to start the broker: %v", err)
+	}
+	t.Cleanup(b.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	events := make(chan clients.Event, 10)
+	ec := NewEdgexEventClient(b.Addr(), "edgex")
+	if err := ec.Subscribe(ctx, func(e clients.Event) { events <- e }); err != nil {
+		t.Fatalf("fail to subscribe: %v", err)
+	}
+	waitSubscribed := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for b.Subscribers("edgex/system-events/core-metadata/device/add") == 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("the client didn't subscribe to the message bus")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitSubscribed()
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v after the first connection", e)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// the messages published while disconnected are lost, a resync event follows the restored subscriptions
+	b.DropConnections()
+	select {
+	case e := <-events:
+		if !reflect.DeepEqual(e, clients.Event{Action: clients.ResyncAction}) {
+			t.Errorf("expected a resync event, got %+v", e)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no resync event received after reconnecting")
+	}
+	waitSubscribed()
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openyurtio/device-controller/pkg/metrics"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// instrument makes the resty client report the latency and errors of its requests to the metrics
+func instrument(c *resty.Client) *resty.Client {
+	c.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		metrics.ObserveEdgeRequest(requestEndpoint(resp.Request.RawRequest.URL), resp.Request.Method, resp.StatusCode(), resp.Time())
+		return nil
+	})
+	c.OnError(func(req *resty.Request, err error) {
+		// the requests with a response have been observed after the response
+		if re, ok := err.(*resty.ResponseError); ok && re.Response.RawResponse != nil {
+			return
+		}
+		endpoint := req.URL
+		if u, perr := url.Parse(req.URL); perr == nil {
+			endpoint = requestEndpoint(u)
+		}
+		metrics.ObserveEdgeRequest(endpoint, req.Method, 0, time.Since(req.Time))
+	})
+	return c
+}
+
+// requestEndpoint returns the endpoint of the request URL without the names of the objects,
+// e.g. edgex-core-command:59882/api/v2/device/name/{name}/{command}
+func requestEndpoint(u *url.URL) string {
+	segs := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := range segs {
+		if segs[i] != "name" {
+			continue
+		}
+		for j, placeholder := range []string{"{name}", "{command}"} {
+			if i+1+j < len(segs) {
+				segs[i+1+j] = placeholder
+			}
+		}
+		break
+	}
+	return u.Host + "/" + strings.Join(segs, "/")
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRequestEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"http://edgex-core-metadata:59881/api/v2/device":                         "edgex-core-metadata:59881/api/v2/device",
+		"http://edgex-core-metadata:59881/api/v2/device/all?limit=-1":            "edgex-core-metadata:59881/api/v2/device/all",
+		"http://edgex-core-metadata:59881/api/v2/device/name/thermometer-01":     "edgex-core-metadata:59881/api/v2/device/name/{name}",
+		"http://edgex-core-command:59882/api/v2/device/name/thermometer-01/Temp": "edgex-core-command:59882/api/v2/device/name/{name}/{command}",
+		"http://edgex-core-data:59880/api/v2/event/device/name/thermometer-01":   "edgex-core-data:59880/api/v2/event/device/name/{name}",
+	}
+	for rawURL, expected := range tests {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if endpoint := requestEndpoint(u); endpoint != expected {
+			t.Errorf("expected endpoint %s of %s, got %s", expected, rawURL, endpoint)
+		}
+	}
+}
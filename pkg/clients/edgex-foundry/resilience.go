@@ -0,0 +1,270 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/openyurtio/device-controller/pkg/clients"
+	"github.com/openyurtio/device-controller/pkg/metrics"
+
+	"github.com/go-resty/resty/v2"
+	"k8s.io/klog/v2"
+)
+
+// Resilience configures how the requests to EdgeX are retried and when the circuit breakers of the services open
+type Resilience struct {
+	// Retries is the number of times a request that failed for a transient reason is sent again
+	Retries int
+	// RetryWaitTime is the wait time before the first retry, it doubles with jitter for every retry up to RetryMaxWaitTime
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+	// FailureThreshold is the number of consecutive failures of a service that opens its circuit breaker,
+	// the circuit breakers are disabled if it is 0
+	FailureThreshold int
+	// OpenTimeout is how long a circuit breaker stays open before a probe request is let through
+	OpenTimeout time.Duration
+}
+
+// DefaultResilience is used by the clients unless SetResilience is called
+var DefaultResilience = Resilience{
+	Retries:          3,
+	RetryWaitTime:    100 * time.Millisecond,
+	RetryMaxWaitTime: 2 * time.Second,
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+var (
+	resilienceMu sync.RWMutex
+	resilience   = DefaultResilience
+)
+
+// SetResilience configures the retries and circuit breakers of the clients created afterwards, it is called once
+// when the driver is opened. Every client holds the settings it is created with, so the clients created earlier
+// keep their settings.
+func SetResilience(r Resilience) {
+	resilienceMu.Lock()
+	defer resilienceMu.Unlock()
+	resilience = r
+}
+
+func currentResilience() Resilience {
+	resilienceMu.RLock()
+	defer resilienceMu.RUnlock()
+	return resilience
+}
+
+// withRetries makes the resty client send the requests again with exponential backoff and jitter
+// if they failed for a transient reason
+func withRetries(c *resty.Client, r Resilience) *resty.Client {
+	c.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		if isUnavailableStatus(resp.StatusCode()) {
+			return &clients.RetryableError{Err: newResponseError(resp)}
+		}
+		return nil
+	})
+	return c.SetRetryCount(r.Retries).
+		SetRetryWaitTime(r.RetryWaitTime).
+		SetRetryMaxWaitTime(r.RetryMaxWaitTime).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			if !clients.IsRetryableErr(err) || clients.IsCircuitOpenErr(err) {
+				return false
+			}
+			if resp != nil && resp.Request != nil {
+				endpoint := resp.Request.URL
+				if u, perr := url.Parse(resp.Request.URL); perr == nil {
+					endpoint = requestEndpoint(u)
+				}
+				klog.V(5).InfoS("retrying the request to EdgeX", "endpoint", endpoint, "attempt", resp.Request.Attempt, "err", err)
+				metrics.AddEdgeRequestRetry(endpoint, resp.Request.Method)
+			}
+			return true
+		})
+}
+
+// isUnavailableStatus returns true if the status code tells that the request was not processed because
+// the service was temporarily unable to handle it
+func isUnavailableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// breakerTransport guards the services of EdgeX with circuit breakers, a service whose requests keep failing
+// is not sent any request until its circuit breaker lets a probe through. It also classifies the errors
+// of the requests, a request is retryable if it failed without being sent or if it is idempotent.
+// The circuit breakers are shared by the clients, the threshold and timeout are the ones of the client.
+type breakerTransport struct {
+	next       http.RoundTripper
+	resilience Resilience
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cb := getCircuitBreaker(req.URL.Host)
+	if wait, ok := cb.allow(t.resilience); !ok {
+		return nil, &clients.CircuitOpenError{Endpoint: req.URL.Host, RetryAfter: wait}
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		// the requests aborted by their context tell nothing about the health of the service
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			cb.release()
+			if ctxErr == context.DeadlineExceeded {
+				return nil, &clients.TimeoutError{StatusError: clients.StatusError{Err: err}}
+			}
+			return nil, err
+		}
+		cb.done(t.resilience, false)
+		retryable := isDialErr(err) || isIdempotent(req.Method)
+		err = newTransportError(err)
+		if retryable {
+			err = &clients.RetryableError{Err: err}
+		}
+		return nil, err
+	}
+	cb.done(t.resilience, !isUnavailableStatus(resp.StatusCode))
+	return resp, nil
+}
+
+// newTransportError returns the typed error of a request that got no response
+func newTransportError(err error) error {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return &clients.TimeoutError{StatusError: clients.StatusError{Err: err}}
+	}
+	return &clients.UnavailableError{StatusError: clients.StatusError{Err: err}}
+}
+
+func isDialErr(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+// circuitBreaker counts the consecutive failures of a service. It opens once the failures reach the threshold,
+// and after the open timeout it is half-open and lets a single probe through, the probe closes it if it succeeds
+// or opens it again otherwise.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	endpoint string
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var (
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   = map[string]*circuitBreaker{}
+)
+
+// getCircuitBreaker returns the circuit breaker of the service, it is shared by all the clients
+func getCircuitBreaker(endpoint string) *circuitBreaker {
+	circuitBreakersMu.Lock()
+	defer circuitBreakersMu.Unlock()
+	cb, ok := circuitBreakers[endpoint]
+	if !ok {
+		cb = &circuitBreaker{endpoint: endpoint}
+		circuitBreakers[endpoint] = cb
+		metrics.SetCircuitBreakerState(endpoint, int(circuitClosed))
+	}
+	return cb
+}
+
+// allow returns whether a request may be sent, and the time left before it may be sent if not
+func (cb *circuitBreaker) allow(r Resilience) (time.Duration, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if r.FailureThreshold <= 0 {
+		return 0, true
+	}
+	switch cb.state {
+	case circuitOpen:
+		if wait := r.OpenTimeout - time.Since(cb.openedAt); wait > 0 {
+			return wait, false
+		}
+		cb.setState(circuitHalfOpen)
+		fallthrough
+	case circuitHalfOpen:
+		if cb.probing {
+			return r.OpenTimeout, false
+		}
+		cb.probing = true
+	}
+	return 0, true
+}
+
+// done records the result of a request allowed by the circuit breaker
+func (cb *circuitBreaker) done(r Resilience, success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+	if success {
+		cb.failures = 0
+		cb.setState(circuitClosed)
+		return
+	}
+	cb.failures++
+	if r.FailureThreshold > 0 && (cb.state == circuitHalfOpen || cb.failures >= r.FailureThreshold) {
+		cb.openedAt = time.Now()
+		cb.setState(circuitOpen)
+	}
+}
+
+// release gives up a request allowed by the circuit breaker without recording its result
+func (cb *circuitBreaker) release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
+
+func (cb *circuitBreaker) setState(state circuitState) {
+	if cb.state == state {
+		return
+	}
+	if state == circuitOpen {
+		klog.V(2).InfoS("the circuit breaker of EdgeX service is open", "endpoint", cb.endpoint, "failures", cb.failures)
+	} else if state == circuitClosed {
+		klog.V(2).InfoS("the circuit breaker of EdgeX service is closed", "endpoint", cb.endpoint)
+	}
+	cb.state = state
+	metrics.SetCircuitBreakerState(cb.endpoint, int(state))
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/device-controller/pkg/clients"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+)
+
+// setTestResilience configures the clients created by the test, the default is restored once the test completes
+func setTestResilience(t *testing.T, r Resilience) {
+	SetResilience(r)
+	t.Cleanup(func() { SetResilience(DefaultResilience) })
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	setTestResilience(t, Resilience{Retries: 3, RetryWaitTime: time.Millisecond, RetryMaxWaitTime: 10 * time.Millisecond})
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	s.AddDevice(dtos.Device{Name: "random-integer-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device"})
+
+	for _, code := range []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusTooManyRequests} {
+		s.FailRequests(2, code)
+		before := s.RequestCount()
+		if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err != nil {
+			t.Errorf("expected the request to succeed after the %d responses are retried: %v", code, err)
+		}
+		if n := s.RequestCount() - before; n != 3 {
+			t.Errorf("expected 3 attempts for %d, got %d", code, n)
+		}
+	}
+
+	// the client errors are not retried
+	before := s.RequestCount()
+	if _, err := cli.Get(context.TODO(), "random-float-device", clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if n := s.RequestCount() - before; n != 1 {
+		t.Errorf("expected a single attempt for a not found device, got %d", n)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	setTestResilience(t, Resilience{FailureThreshold: 3, OpenTimeout: 200 * time.Millisecond})
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	s.AddDevice(dtos.Device{Name: "random-integer-device", ServiceName: "device-virtual", ProfileName: "Random-Integer-Device"})
+
+	s.FailRequests(-1, http.StatusServiceUnavailable)
+	for i := 0; i < 3; i++ {
+		if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err == nil || clients.IsCircuitOpenErr(err) {
+			t.Fatalf("expected request %d to be sent and fail, got %v", i, err)
+		}
+	}
+	// the circuit breaker is open, the requests are refused without being sent
+	before := s.RequestCount()
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); !clients.IsCircuitOpenErr(err) {
+		t.Fatalf("expected the circuit breaker to be open, got %v", err)
+	}
+	if s.RequestCount() != before {
+		t.Errorf("expected no request to be sent while the circuit breaker is open")
+	}
+
+	// the probe fails and opens the circuit breaker again
+	time.Sleep(250 * time.Millisecond)
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err == nil || clients.IsCircuitOpenErr(err) {
+		t.Fatalf("expected the probe to be sent and fail, got %v", err)
+	}
+	if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); !clients.IsCircuitOpenErr(err) {
+		t.Fatalf("expected the circuit breaker to be open again, got %v", err)
+	}
+
+	// the probe succeeds and closes the circuit breaker
+	s.Reset()
+	time.Sleep(250 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err != nil {
+			t.Errorf("expected the circuit breaker to be closed: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	setTestResilience(t, Resilience{})
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+
+	s.FailRequests(-1, http.StatusServiceUnavailable)
+	for i := 0; i < 10; i++ {
+		if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); clients.IsCircuitOpenErr(err) {
+			t.Fatalf("expected the circuit breaker to be disabled, got %v", err)
+		}
+	}
+}
+
+func TestClientKeepsResilience(t *testing.T) {
+	setTestResilience(t, Resilience{})
+	s := newTestServer(t)
+	cli := newTestDeviceClient(s)
+	// the settings changed after the client is created are not applied to it
+	SetResilience(Resilience{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	s.FailRequests(-1, http.StatusServiceUnavailable)
+	for i := 0; i < 3; i++ {
+		if _, err := cli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); err == nil || clients.IsCircuitOpenErr(err) {
+			t.Fatalf("expected request %d to be sent without retries and fail, got %v", i, err)
+		}
+	}
+	if n := s.RequestCount(); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Security configures how the clients connect and authenticate to EdgeX running in secure mode behind its API gateway
+type Security struct {
+	// TLS makes the clients use HTTPS, the certificates of the gateway are verified with the CA bundle in CAFile,
+	// or with the system roots if it is empty
+	TLS    bool
+	CAFile string
+	// CertFile and KeyFile are the optional client certificate and key presented to the gateway
+	CertFile string
+	KeyFile  string
+	// TokenFile is a file holding the bearer token sent to the gateway, e.g. a mounted secret
+	TokenFile string
+	// TokenSecretNamespace, TokenSecretName and TokenSecretKey locate the bearer token in a Secret,
+	// which is read with SecretReader. They are ignored if TokenFile is set.
+	TokenSecretNamespace string
+	TokenSecretName      string
+	TokenSecretKey       string
+	SecretReader         client.Reader
+	// TokenRefreshPeriod is how long a token is used before it is loaded again, so that the rotated tokens are picked up,
+	// the token is only loaded again when the gateway rejects it if it is 0
+	TokenRefreshPeriod time.Duration
+}
+
+// connection is how the clients connect and authenticate to EdgeX, it is built once when the driver is opened
+// and shared by the clients the driver creates
+type connection struct {
+	scheme    string
+	transport *http.Transport
+	// tokens is nil if no token is sent
+	tokens *tokenSource
+}
+
+// plainConnection connects to EdgeX with plain HTTP and no token, it is used by the clients created with the New functions
+var plainConnection = &connection{scheme: "http", transport: sharedTransport}
+
+// newConnection builds the connection configured by s, the token is only sent over TLS, so it is refused without TLS.
+// A connection over TLS has a transport of its own, the shared transport is only used with plain HTTP.
+func newConnection(s Security) (*connection, error) {
+	if !s.TLS && (s.TokenFile != "" || s.TokenSecretName != "") {
+		return nil, fmt.Errorf("the token of EdgeX is only sent over TLS, TLS should be enabled")
+	}
+	if !s.TLS {
+		return plainConnection, nil
+	}
+	tlsConfig, err := newTLSConfig(s)
+	if err != nil {
+		return nil, err
+	}
+	conn := &connection{scheme: "https", transport: newTransport()}
+	conn.transport.TLSClientConfig = tlsConfig
+	switch {
+	case s.TokenFile != "":
+		conn.tokens = newTokenSource(fileToken(s.TokenFile), s.TokenRefreshPeriod)
+	case s.TokenSecretName != "":
+		if s.SecretReader == nil {
+			return nil, fmt.Errorf("a kubernetes client is required to read the token from secret %s/%s", s.TokenSecretNamespace, s.TokenSecretName)
+		}
+		conn.tokens = newTokenSource(secretToken(s.SecretReader, s.TokenSecretNamespace, s.TokenSecretName, s.TokenSecretKey), s.TokenRefreshPeriod)
+	}
+	return conn, nil
+}
+
+func newTLSConfig(s Security) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.CAFile != "" {
+		ca, err := ioutil.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA bundle: %v", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate is found in the CA bundle %s", s.CAFile)
+		}
+	}
+	if s.CertFile != "" || s.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// withAuth makes the resty client send the bearer token of ts with every request sent over TLS, including the retries
+func withAuth(c *resty.Client, ts *tokenSource) *resty.Client {
+	if ts == nil {
+		return c
+	}
+	c.SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+		if req.URL.Scheme != "https" {
+			// the token is never sent in cleartext
+			return nil
+		}
+		token, err := ts.Token(req.Context())
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+	c.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		// the token may have been rotated, load it again for the next request
+		if resp.StatusCode() == http.StatusUnauthorized {
+			ts.Invalidate()
+		}
+		return nil
+	})
+	return c
+}
+
+// tokenLoader loads the current bearer token
+type tokenLoader func(ctx context.Context) (string, error)
+
+// tokenSource caches the bearer token, the token is loaded again once it is older than the refresh period
+// or rejected by the gateway, it is only loaded again when rejected if the refresh period is 0.
+// The cached token keeps being used if it cannot be loaded again.
+type tokenSource struct {
+	load          tokenLoader
+	refreshPeriod time.Duration
+
+	mu       sync.Mutex
+	token    string
+	loadedAt time.Time
+}
+
+func newTokenSource(load tokenLoader, refreshPeriod time.Duration) *tokenSource {
+	return &tokenSource{load: load, refreshPeriod: refreshPeriod}
+}
+
+// Token returns the cached token, or loads it if it is stale
+func (ts *tokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token != "" && !ts.loadedAt.IsZero() && (ts.refreshPeriod <= 0 || time.Since(ts.loadedAt) < ts.refreshPeriod) {
+		return ts.token, nil
+	}
+	token, err := ts.load(ctx)
+	if err != nil {
+		if ts.token == "" {
+			return "", fmt.Errorf("failed to load the token of EdgeX: %v", err)
+		}
+		klog.V(3).ErrorS(err, "failed to reload the token of EdgeX, using the cached one")
+		return ts.token, nil
+	}
+	if ts.token != "" && token != ts.token {
+		klog.V(2).Info("the token of EdgeX has been rotated")
+	}
+	ts.token, ts.loadedAt = token, time.Now()
+	return token, nil
+}
+
+// Invalidate makes the token loaded again on the next request
+func (ts *tokenSource) Invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.loadedAt = time.Time{}
+}
+
+// fileToken loads the token from a file, e.g. a Secret mounted into the pod which kubelet updates when it rotates
+func fileToken(path string) tokenLoader {
+	return func(_ context.Context) (string, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("token file %s is empty", path)
+		}
+		return token, nil
+	}
+}
+
+// secretToken loads the token from the key of a Secret
+func secretToken(reader client.Reader, namespace, name, key string) tokenLoader {
+	return func(ctx context.Context) (string, error) {
+		var secret corev1.Secret
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &secret); err != nil {
+			return "", err
+		}
+		token := strings.TrimSpace(string(secret.Data[key]))
+		if token == "" {
+			return "", fmt.Errorf("key %s of secret %s/%s is empty", key, namespace, name)
+		}
+		return token, nil
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openyurtio/device-controller/pkg/clients"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestConnection builds the connection configured by s for the clients created by the test
+func newTestConnection(t *testing.T, s Security) *connection {
+	conn, err := newConnection(s)
+	if err != nil {
+		t.Fatalf("failed to build the connection: %v", err)
+	}
+	return conn
+}
+
+// newGatewayServer starts a server which only accepts the requests with the token returned by validToken,
+// the requests it accepts are answered with 404
+func newGatewayServer(t *testing.T, tls bool, validToken func() string) *httptest.Server {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+validToken() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	s := httptest.NewUnstartedServer(h)
+	if tls {
+		s.StartTLS()
+	} else {
+		s.Start()
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// writeTestCABundle writes the certificate of the TLS server to a CA bundle in dir
+func writeTestCABundle(t *testing.T, s *httptest.Server, dir string) string {
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile
+}
+
+func TestTokenFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("token-a\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	validToken := "token-a"
+	s := newGatewayServer(t, true, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return validToken
+	})
+	setTestResilience(t, Resilience{})
+	conn := newTestConnection(t, Security{TLS: true, CAFile: writeTestCABundle(t, s, dir), TokenFile: tokenFile, TokenRefreshPeriod: time.Hour})
+	cli := newEdgexDeviceClient(conn, s.Listener.Addr().String(), "", "")
+
+	if _, err := cli.Get(context.TODO(), "random-device", clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Fatalf("expected the request to be authenticated, got %v", err)
+	}
+
+	// the token is rotated, the request with the cached token is rejected and the next one uses the new token
+	if err := ioutil.WriteFile(tokenFile, []byte("token-b\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	validToken = "token-b"
+	mu.Unlock()
+	if _, err := cli.Get(context.TODO(), "random-device", clients.GetOptions{}); err == nil || clients.IsNotFoundErr(err) {
+		t.Errorf("expected the request with the rotated token to be rejected, got %v", err)
+	}
+	if _, err := cli.Get(context.TODO(), "random-device", clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Errorf("expected the request to be authenticated with the new token, got %v", err)
+	}
+}
+
+func TestSecretToken(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "edgex-token"},
+		Data:       map[string][]byte{"token": []byte("token-a")},
+	}
+	kubeClient := ctrlfake.NewClientBuilder().WithObjects(secret).Build()
+	ts := newTokenSource(secretToken(kubeClient, "default", "edgex-token", "token"), 0)
+
+	if token, err := ts.Token(context.TODO()); err != nil || token != "token-a" {
+		t.Fatalf("expected token-a, got %q, %v", token, err)
+	}
+	secret.Data["token"] = []byte("token-b")
+	if err := kubeClient.Update(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	// without a refresh period the token is only loaded again once it is rejected
+	if token, err := ts.Token(context.TODO()); err != nil || token != "token-a" {
+		t.Errorf("expected the cached token-a, got %q, %v", token, err)
+	}
+	ts.Invalidate()
+	if token, err := ts.Token(context.TODO()); err != nil || token != "token-b" {
+		t.Errorf("expected the rotated token-b, got %q, %v", token, err)
+	}
+	ts.Invalidate()
+	// the cached token is used if the secret cannot be read
+	if err := kubeClient.Delete(context.TODO(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := ts.Token(context.TODO()); err != nil || token != "token-b" {
+		t.Errorf("expected the cached token-b, got %q, %v", token, err)
+	}
+}
+
+func TestTLSWithCABundle(t *testing.T) {
+	s := newGatewayServer(t, true, func() string { return "token-a" })
+	dir := t.TempDir()
+	caFile := writeTestCABundle(t, s, dir)
+	tokenFile := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("token-a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setTestResilience(t, Resilience{})
+
+	// the certificate of the server is not trusted without the CA bundle
+	cli := newEdgexDeviceClient(newTestConnection(t, Security{TLS: true, TokenFile: tokenFile}), s.Listener.Addr().String(), "", "")
+	if _, err := cli.Get(context.TODO(), "random-device", clients.GetOptions{}); err == nil || clients.IsNotFoundErr(err) {
+		t.Errorf("expected the certificate of the server to be rejected, got %v", err)
+	}
+
+	cli = newEdgexDeviceClient(newTestConnection(t, Security{TLS: true, CAFile: caFile, TokenFile: tokenFile}), s.Listener.Addr().String(), "", "")
+	if _, err := cli.Get(context.TODO(), "random-device", clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Errorf("expected the request to be sent over TLS, got %v", err)
+	}
+
+	if _, err := newConnection(Security{TLS: true, CAFile: tokenFile}); err == nil {
+		t.Errorf("expected a CA bundle without certificates to be rejected")
+	}
+	// the token is never sent in cleartext
+	if _, err := newConnection(Security{TokenFile: tokenFile}); err == nil {
+		t.Errorf("expected a token without TLS to be rejected")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgex_foundry
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"k8s.io/klog/v2"
+)
+
+// DefaultRequestTimeout is the timeout of the requests to EdgeX whose context has no deadline
+const DefaultRequestTimeout = 10 * time.Second
+
+// sharedTransport is used by all the EdgeX clients using plain HTTP, so that the connections to the core services
+// are reused across the requests and the clients instead of being dialed for every request
+var sharedTransport = newTransport()
+
+// newTransport creates a transport keeping the connections to the core services alive
+func newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newRestyClient creates an instrumented resty client on top of the transport of conn guarded by the circuit breakers,
+// the client has no timeout of its own, the requests and their retries are bounded by their context.
+// The retries and circuit breakers of the client use the settings at the time it is created.
+func newRestyClient(conn *connection) *resty.Client {
+	r := currentResilience()
+	c := instrument(resty.NewWithClient(&http.Client{Transport: &breakerTransport{next: conn.transport, resilience: r}}))
+	c.SetLogger(restyLogger{})
+	return withRetries(withAuth(c, conn.tokens), r)
+}
+
+// newRequest creates a request of the client bound to ctx, the request times out after DefaultRequestTimeout
+// if ctx has no deadline. The returned cancel function should be called once the response is read.
+func newRequest(ctx context.Context, c *resty.Client) (*resty.Request, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	cancel := context.CancelFunc(func() {})
+	if _, ok := ctx.Deadline(); !ok {
+		ctx, cancel = context.WithTimeout(ctx, DefaultRequestTimeout)
+	}
+	return c.R().SetContext(ctx), cancel
+}
+
+// restyLogger sends the logs of resty, e.g. the failed attempts of the requests, to klog
+type restyLogger struct{}
+
+func (restyLogger) Errorf(format string, v ...interface{}) { klog.V(4).Infof(format, v...) }
+func (restyLogger) Warnf(format string, v ...interface{})  { klog.V(4).Infof(format, v...) }
+func (restyLogger) Debugf(format string, v ...interface{}) { klog.V(5).Infof(format, v...) }
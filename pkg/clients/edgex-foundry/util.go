@@ -17,11 +17,17 @@ limitations under the License.
 package edgex_foundry
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/common"
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+
+	"github.com/go-resty/resty/v2"
 
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
 	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos/requests"
@@ -35,10 +41,61 @@ const (
 	DeviceProfilePath   = "/api/v2/deviceprofile"
 	DevicePath          = "/api/v2/device"
 	CommandResponsePath = "/api/v2/device"
+	EventPath           = "/api/v2/event"
 
 	APIVersionV2 = "v2"
 )
 
+// newResponseError returns the typed error of a failed request, the message and the error code are taken
+// from the EdgeX response in the body if there is one
+func newResponseError(resp *resty.Response) error {
+	var br common.BaseResponse
+	if err := json.Unmarshal(resp.Body(), &br); err == nil && br.Message != "" {
+		return clients.NewStatusError(resp.StatusCode(), br.StatusCode, br.Message)
+	}
+	return clients.NewStatusError(resp.StatusCode(), 0, strings.TrimSpace(string(resp.Body())))
+}
+
+// newItemError returns the typed error of an item of a multi-status response
+func newItemError(resp *resty.Response, item common.BaseResponse) error {
+	return clients.NewStatusError(resp.StatusCode(), item.StatusCode, item.Message)
+}
+
+// DefaultListPageSize is the number of objects fetched per request by the List calls
+const DefaultListPageSize = 500
+
+// listQuery returns the query of a page of a List call to EdgeX, the labels of the selector are only accepted
+// by the /all endpoints. EdgeX may return more objects than selected, the caller matches them again.
+func listQuery(path string, opts clients.ListOptions, offset, limit int) string {
+	q := url.Values{}
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("limit", strconv.Itoa(limit))
+	if labels := clients.SelectorLabels(opts.LabelSelector); path == "/all" && len(labels) != 0 {
+		q.Set("labels", strings.Join(labels, ","))
+	}
+	return q.Encode()
+}
+
+// listPages pages through a List call to EdgeX with offset and limit, getPage fetches the page at the offset
+// and returns the number of objects in it and the total count of the objects.
+// The objects added or deleted during the iteration may be missed or visited twice, an object missing from the
+// pages is only known to be deleted after a Get of it returns NotFound.
+func listPages(pageSize int, getPage func(offset, limit int) (count, total int, err error)) error {
+	if pageSize <= 0 {
+		pageSize = DefaultListPageSize
+	}
+	for offset := 0; ; {
+		count, total, err := getPage(offset, pageSize)
+		if err != nil {
+			return err
+		}
+		offset += count
+		if count < pageSize || offset >= total {
+			return nil
+		}
+	}
+}
+
 type ClientURL struct {
 	Host string
 	Port int
@@ -54,10 +111,24 @@ func getEdgeDeviceName(d *devicev1alpha1.Device) string {
 	return actualDeviceName
 }
 
+func getEdgeDeviceProfileName(dp *devicev1alpha1.DeviceProfile) string {
+	if actualName, ok := dp.ObjectMeta.Labels[EdgeXObjectName]; ok {
+		return actualName
+	}
+	return dp.GetName()
+}
+
+func getEdgeDeviceServiceName(ds *devicev1alpha1.DeviceService) string {
+	if actualName, ok := ds.ObjectMeta.Labels[EdgeXObjectName]; ok {
+		return actualName
+	}
+	return ds.GetName()
+}
+
 func toEdgexDeviceService(ds *devicev1alpha1.DeviceService) dtos.DeviceService {
 	return dtos.DeviceService{
 		Description:   ds.Spec.Description,
-		Name:          ds.GetName(),
+		Name:          getEdgeDeviceServiceName(ds),
 		LastConnected: ds.Status.LastConnected,
 		LastReported:  ds.Status.LastReported,
 		Labels:        ds.Spec.Labels,
@@ -66,6 +137,33 @@ func toEdgexDeviceService(ds *devicev1alpha1.DeviceService) dtos.DeviceService {
 	}
 }
 
+// toEdgeXUpdateDeviceService converts the fields of the deviceService to an EdgeX update, only the given fields
+// are set so that the others are kept unchanged on EdgeX, all of them are set if no field is given
+func toEdgeXUpdateDeviceService(ds *devicev1alpha1.DeviceService, fields []string) dtos.UpdateDeviceService {
+	name := getEdgeDeviceServiceName(ds)
+	uds := dtos.UpdateDeviceService{Name: &name}
+	if hasField(fields, "description") {
+		description := ds.Spec.Description
+		uds.Description = &description
+	}
+	if hasField(fields, "labels") {
+		uds.Labels = ds.Spec.Labels
+		if uds.Labels == nil {
+			// an empty slice clears the labels on EdgeX, nil would keep them unchanged
+			uds.Labels = []string{}
+		}
+	}
+	if hasField(fields, "baseAddress") && ds.Spec.BaseAddress != "" {
+		baseAddress := ds.Spec.BaseAddress
+		uds.BaseAddress = &baseAddress
+	}
+	if hasField(fields, "adminState") && ds.Spec.AdminState != "" {
+		adminState := string(ds.Spec.AdminState)
+		uds.AdminState = &adminState
+	}
+	return uds
+}
+
 func toEdgeXDeviceResourceSlice(drs []devicev1alpha1.DeviceResource) []dtos.DeviceResource {
 	var ret []dtos.DeviceResource
 	for _, dr := range drs {
@@ -84,6 +182,7 @@ func toEdgeXDeviceResource(dr devicev1alpha1.DeviceResource) dtos.DeviceResource
 		Description: dr.Description,
 		Name:        dr.Name,
 		Tag:         dr.Tag,
+		IsHidden:    dr.IsHidden,
 		Properties:  toEdgeXProfileProperty(dr.Properties),
 		Attributes:  genericAttrs,
 	}
@@ -144,6 +243,7 @@ func toEdgeXDevice(d *devicev1alpha1.Device) dtos.Device {
 		Location:       d.Spec.Location,
 		ServiceName:    d.Spec.Service,
 		ProfileName:    d.Spec.Profile,
+		AutoEvents:     toEdgeXAutoEvents(d.Spec.AutoEvents),
 	}
 	if d.Status.EdgeId != "" {
 		md.Id = d.Status.EdgeId
@@ -151,6 +251,82 @@ func toEdgeXDevice(d *devicev1alpha1.Device) dtos.Device {
 	return md
 }
 
+// toEdgeXUpdateDevice converts the given fields of the device to the DTO used to patch the device on EdgeX,
+// all the fields are converted if none is given. Fields that are left nil will not be changed by EdgeX.
+func toEdgeXUpdateDevice(d *devicev1alpha1.Device, fields []string) dtos.UpdateDevice {
+	name := getEdgeDeviceName(d)
+	ud := dtos.UpdateDevice{Name: &name}
+	if hasField(fields, "description") {
+		description := d.Spec.Description
+		ud.Description = &description
+	}
+	if hasField(fields, "notify") {
+		notify := d.Spec.Notify
+		ud.Notify = &notify
+	}
+	if hasField(fields, "labels") {
+		ud.Labels = d.Spec.Labels
+		if ud.Labels == nil {
+			// an empty slice clears the labels on EdgeX, nil would keep them unchanged
+			ud.Labels = []string{}
+		}
+	}
+	if hasField(fields, "location") {
+		ud.Location = d.Spec.Location
+	}
+	if hasField(fields, "autoEvents") {
+		ud.AutoEvents = toEdgeXAutoEvents(d.Spec.AutoEvents)
+		if ud.AutoEvents == nil {
+			ud.AutoEvents = []dtos.AutoEvent{}
+		}
+	}
+	if hasField(fields, "adminState") && d.Spec.AdminState != "" {
+		adminState := string(toEdgeXAdminState(d.Spec.AdminState))
+		ud.AdminState = &adminState
+	}
+	if hasField(fields, "operatingState") && d.Spec.OperatingState != "" {
+		operatingState := string(toEdgeXOperatingState(d.Spec.OperatingState))
+		ud.OperatingState = &operatingState
+	}
+	if hasField(fields, "serviceName") && d.Spec.Service != "" {
+		service := d.Spec.Service
+		ud.ServiceName = &service
+	}
+	if hasField(fields, "profileName") && d.Spec.Profile != "" {
+		profile := d.Spec.Profile
+		ud.ProfileName = &profile
+	}
+	if hasField(fields, "protocols") && len(d.Spec.Protocols) != 0 {
+		ud.Protocols = toEdgeXProtocols(d.Spec.Protocols)
+	}
+	return ud
+}
+
+// hasField reports whether the field is one of the fields to update, every field is if none is given
+func hasField(fields []string, field string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func toEdgeXAutoEvents(aes []devicev1alpha1.AutoEvent) []dtos.AutoEvent {
+	var ret []dtos.AutoEvent
+	for _, ae := range aes {
+		ret = append(ret, dtos.AutoEvent{
+			Interval:   ae.Interval,
+			OnChange:   ae.OnChange,
+			SourceName: ae.SourceName,
+		})
+	}
+	return ret
+}
+
 func toEdgeXProtocols(
 	pps map[string]devicev1alpha1.ProtocolProperties) map[string]dtos.ProtocolProperties {
 	ret := map[string]dtos.ProtocolProperties{}
@@ -199,6 +375,7 @@ func toKubeDevice(ed dtos.Device) devicev1alpha1.Device {
 			Location:       loc,
 			Service:        ed.ServiceName,
 			Profile:        ed.ProfileName,
+			AutoEvents:     toKubeAutoEvents(ed.AutoEvents),
 			// TODO: Notify
 		},
 		Status: devicev1alpha1.DeviceStatus{
@@ -208,10 +385,24 @@ func toKubeDevice(ed dtos.Device) devicev1alpha1.Device {
 			EdgeId:         ed.Id,
 			AdminState:     devicev1alpha1.AdminState(ed.AdminState),
 			OperatingState: devicev1alpha1.OperatingState(ed.OperatingState),
+			AutoEvents:     toKubeAutoEvents(ed.AutoEvents),
 		},
 	}
 }
 
+// toKubeAutoEvents serialize the EdgeX AutoEvents to the corresponding Kubernetes AutoEvents
+func toKubeAutoEvents(eaes []dtos.AutoEvent) []devicev1alpha1.AutoEvent {
+	var ret []devicev1alpha1.AutoEvent
+	for _, eae := range eaes {
+		ret = append(ret, devicev1alpha1.AutoEvent{
+			Interval:   eae.Interval,
+			OnChange:   eae.OnChange,
+			SourceName: eae.SourceName,
+		})
+	}
+	return ret
+}
+
 // toKubeProtocols serialize the EdgeX ProtocolProperties to the corresponding
 // Kubernetes OperatingState
 func toKubeProtocols(
@@ -248,10 +439,10 @@ func toKubeDeviceProfile(dp *dtos.DeviceProfile) devicev1alpha1.DeviceProfile {
 	}
 }
 
-func toKubeDeviceCommand(dcs []dtos.DeviceCommand) []devicev1alpha1.DeviceCommand {
-	var ret []devicev1alpha1.DeviceCommand
+func toKubeDeviceCommand(dcs []dtos.DeviceCommand) []devicev1alpha1.DeviceProfileCommand {
+	var ret []devicev1alpha1.DeviceProfileCommand
 	for _, dc := range dcs {
-		ret = append(ret, devicev1alpha1.DeviceCommand{
+		ret = append(ret, devicev1alpha1.DeviceProfileCommand{
 			Name:               dc.Name,
 			ReadWrite:          dc.ReadWrite,
 			IsHidden:           dc.IsHidden,
@@ -261,7 +452,7 @@ func toKubeDeviceCommand(dcs []dtos.DeviceCommand) []devicev1alpha1.DeviceComman
 	return ret
 }
 
-func toEdgeXDeviceCommand(dcs []devicev1alpha1.DeviceCommand) []dtos.DeviceCommand {
+func toEdgeXDeviceCommand(dcs []devicev1alpha1.DeviceProfileCommand) []dtos.DeviceCommand {
 	var ret []dtos.DeviceCommand
 	for _, dc := range dcs {
 		ret = append(ret, dtos.DeviceCommand{
@@ -309,19 +500,22 @@ func toKubeDeviceResources(drs []dtos.DeviceResource) []devicev1alpha1.DeviceRes
 func toKubeDeviceResource(dr dtos.DeviceResource) devicev1alpha1.DeviceResource {
 	concreteAttrs := make(map[string]string)
 	for k, v := range dr.Attributes {
-		switch v.(type) {
+		switch v := v.(type) {
 		case string:
-			concreteAttrs[k] = v.(string)
-			continue
+			concreteAttrs[k] = v
 		case int:
-			concreteAttrs[k] = fmt.Sprintf("%d", v.(int))
-			continue
+			concreteAttrs[k] = strconv.Itoa(v)
 		case float64:
-			concreteAttrs[k] = fmt.Sprintf("%f", v.(float64))
-			continue
+			concreteAttrs[k] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			concreteAttrs[k] = strconv.FormatBool(v)
 		case fmt.Stringer:
-			concreteAttrs[k] = v.(fmt.Stringer).String()
-			continue
+			concreteAttrs[k] = v.String()
+		default:
+			// e.g. the objects and arrays are kept as JSON
+			if data, err := json.Marshal(v); err == nil {
+				concreteAttrs[k] = string(data)
+			}
 		}
 	}
 
@@ -356,8 +550,9 @@ func toKubeProfileProperty(rp dtos.ResourceProperties) devicev1alpha1.ResourcePr
 // toEdgeXDeviceProfile create DeviceProfile in edge according to devicProfile in cloud
 func toEdgeXDeviceProfile(dp *devicev1alpha1.DeviceProfile) dtos.DeviceProfile {
 	return dtos.DeviceProfile{
+		Id:              dp.Status.EdgeId,
 		Description:     dp.Spec.Description,
-		Name:            dp.GetName(),
+		Name:            getEdgeDeviceProfileName(dp),
 		Manufacturer:    dp.Spec.Manufacturer,
 		Model:           dp.Spec.Model,
 		Labels:          dp.Spec.Labels,
@@ -366,16 +561,45 @@ func toEdgeXDeviceProfile(dp *devicev1alpha1.DeviceProfile) dtos.DeviceProfile {
 	}
 }
 
+// patchEdgeXDeviceProfile sets the given fields of the EdgeX deviceProfile from the deviceProfile,
+// the other fields are kept as they are, including the ones not modelled by the deviceProfile
+func patchEdgeXDeviceProfile(edp *dtos.DeviceProfile, dp *devicev1alpha1.DeviceProfile, fields []string) {
+	for _, field := range fields {
+		switch field {
+		case "description":
+			edp.Description = dp.Spec.Description
+		case "manufacturer":
+			edp.Manufacturer = dp.Spec.Manufacturer
+		case "model":
+			edp.Model = dp.Spec.Model
+		case "labels":
+			edp.Labels = dp.Spec.Labels
+		case "deviceResources":
+			edp.DeviceResources = toEdgeXDeviceResourceSlice(dp.Spec.DeviceResources)
+		case "deviceCommands":
+			edp.DeviceCommands = toEdgeXDeviceCommand(dp.Spec.DeviceCommands)
+		}
+	}
+}
+
 func makeEdgeXDeviceProfilesRequest(dps []*devicev1alpha1.DeviceProfile) []*requests.DeviceProfileRequest {
-	var req []*requests.DeviceProfileRequest
+	var edps []dtos.DeviceProfile
 	for _, dp := range dps {
+		edps = append(edps, toEdgeXDeviceProfile(dp))
+	}
+	return makeEdgeXDeviceProfileDTOsRequest(edps)
+}
+
+func makeEdgeXDeviceProfileDTOsRequest(edps []dtos.DeviceProfile) []*requests.DeviceProfileRequest {
+	var req []*requests.DeviceProfileRequest
+	for _, edp := range edps {
 		req = append(req, &requests.DeviceProfileRequest{
 			BaseRequest: common.BaseRequest{
 				Versionable: common.Versionable{
 					ApiVersion: APIVersionV2,
 				},
 			},
-			Profile: toEdgeXDeviceProfile(dp),
+			Profile: edp,
 		})
 	}
 	return req
@@ -396,6 +620,36 @@ func makeEdgeXDeviceRequest(devs []*devicev1alpha1.Device) []*requests.AddDevice
 	return req
 }
 
+func makeEdgeXUpdateDeviceRequest(devs []*devicev1alpha1.Device, fields []string) []*requests.UpdateDeviceRequest {
+	var req []*requests.UpdateDeviceRequest
+	for _, dev := range devs {
+		req = append(req, &requests.UpdateDeviceRequest{
+			BaseRequest: common.BaseRequest{
+				Versionable: common.Versionable{
+					ApiVersion: APIVersionV2,
+				},
+			},
+			Device: toEdgeXUpdateDevice(dev, fields),
+		})
+	}
+	return req
+}
+
+func makeEdgeXUpdateDeviceServiceRequest(dss []*devicev1alpha1.DeviceService, fields []string) []*requests.UpdateDeviceServiceRequest {
+	var req []*requests.UpdateDeviceServiceRequest
+	for _, ds := range dss {
+		req = append(req, &requests.UpdateDeviceServiceRequest{
+			BaseRequest: common.BaseRequest{
+				Versionable: common.Versionable{
+					ApiVersion: APIVersionV2,
+				},
+			},
+			Service: toEdgeXUpdateDeviceService(ds, fields),
+		})
+	}
+	return req
+}
+
 func makeEdgeXDeviceService(dss []*devicev1alpha1.DeviceService) []*requests.AddDeviceServiceRequest {
 	var req []*requests.AddDeviceServiceRequest
 	for _, ds := range dss {
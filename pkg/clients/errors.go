@@ -16,15 +16,186 @@ limitations under the License.
 
 package clients
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
 
-type NotFoundError struct{}
+// StatusError is an error reported by the edge platform for a request, it carries the HTTP status code of the
+// response and the error code the edge platform reported in the response body
+type StatusError struct {
+	// StatusCode is the HTTP status code of the response, 0 if the request got no response
+	StatusCode int
+	// Code is the error code reported by the edge platform, it differs from StatusCode for the items of a
+	// multi-status response
+	Code int
+	// Message is the error message reported by the edge platform
+	Message string
+	// Err is the cause of the error if the request got no response
+	Err error
+}
+
+func (e *StatusError) Error() string { return e.format("request to the edge platform failed") }
+
+func (e *StatusError) Unwrap() error { return e.Err }
+
+func (e *StatusError) statusError() *StatusError { return e }
+
+// statusError is implemented by StatusError and the typed errors embedding it
+type statusError interface {
+	statusError() *StatusError
+}
+
+func (e *StatusError) format(kind string) string {
+	msg := kind
+	if e.Message != "" {
+		msg += ": " + e.Message
+	} else if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.StatusCode != 0 {
+		if e.Code != 0 && e.Code != e.StatusCode {
+			return fmt.Sprintf("%s (status %d, code %d)", msg, e.StatusCode, e.Code)
+		}
+		return fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	return msg
+}
+
+// NotFoundError indicates that the object, or an object it refers to, does not exist on the edge platform
+type NotFoundError struct{ StatusError }
+
+func (e *NotFoundError) Error() string { return e.format("not found") }
+
+// ConflictError indicates that the request conflicts with the current state of the object, e.g. the object
+// already exists or is still in use by other objects
+type ConflictError struct{ StatusError }
 
-func (e *NotFoundError) Error() string { return "Item not found" }
+func (e *ConflictError) Error() string { return e.format("conflict") }
+
+// LockedError indicates that the device is locked (AdminState) or down (OperatingState)
+type LockedError struct{ StatusError }
+
+func (e *LockedError) Error() string { return e.format("locked") }
+
+// InvalidRequestError indicates that the edge platform rejected the content of the request, e.g. a malformed
+// object or a value out of range
+type InvalidRequestError struct{ StatusError }
+
+func (e *InvalidRequestError) Error() string { return e.format("invalid request") }
+
+// UnavailableError indicates that the edge platform could not be reached or was overloaded
+type UnavailableError struct{ StatusError }
+
+func (e *UnavailableError) Error() string { return e.format("the edge platform is unavailable") }
+
+// TimeoutError indicates that the edge platform, or the device behind it, did not respond in time
+type TimeoutError struct{ StatusError }
+
+func (e *TimeoutError) Error() string { return e.format("timed out") }
+
+// NewStatusError returns the typed error for the code reported by the edge platform, the HTTP status code of
+// the response is used if the edge platform reported no code
+func NewStatusError(statusCode, code int, message string) error {
+	se := StatusError{StatusCode: statusCode, Code: code, Message: message}
+	if code == 0 {
+		code = statusCode
+	}
+	switch code {
+	case http.StatusNotFound:
+		return &NotFoundError{se}
+	case http.StatusConflict:
+		return &ConflictError{se}
+	case http.StatusLocked:
+		return &LockedError{se}
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge:
+		return &InvalidRequestError{se}
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable:
+		return &UnavailableError{se}
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return &TimeoutError{se}
+	}
+	return &se
+}
 
+// IsNotFoundErr returns true if the object, or an object it refers to, does not exist on the edge platform
 func IsNotFoundErr(err error) bool {
-	if err == nil {
+	var e *NotFoundError
+	return errors.As(err, &e)
+}
+
+// IsConflictErr returns true if the request conflicts with the current state of the object
+func IsConflictErr(err error) bool {
+	var e *ConflictError
+	return errors.As(err, &e)
+}
+
+// IsLockedErr returns true if the device is locked or down
+func IsLockedErr(err error) bool {
+	var e *LockedError
+	return errors.As(err, &e)
+}
+
+// IsInvalidRequestErr returns true if the edge platform rejected the content of the request
+func IsInvalidRequestErr(err error) bool {
+	var e *InvalidRequestError
+	return errors.As(err, &e)
+}
+
+// IsUnavailableErr returns true if the edge platform could not be reached, was overloaded, or is guarded by an
+// open circuit breaker
+func IsUnavailableErr(err error) bool {
+	var e *UnavailableError
+	return errors.As(err, &e) || IsCircuitOpenErr(err)
+}
+
+// IsTimeoutErr returns true if the edge platform did not respond in time
+func IsTimeoutErr(err error) bool {
+	var e *TimeoutError
+	return errors.As(err, &e)
+}
+
+// IsResponseErr returns true if the edge platform responded to the request with an error, e.g. the object was
+// not found or the request was invalid, rather than the request failed to reach it or timed out
+func IsResponseErr(err error) bool {
+	var se statusError
+	if !errors.As(err, &se) || IsTimeoutErr(err) || IsUnavailableErr(err) {
 		return false
 	}
-	return strings.Contains(err.Error(), "not found") || strings.HasPrefix(err.Error(), "no item found")
+	return se.statusError().StatusCode != 0 || se.statusError().Err == nil
+}
+
+// RetryableError indicates that a request to the edge platform failed for a transient reason, e.g. the platform
+// was unreachable or overloaded, the same request may succeed if it is sent again later
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// CircuitOpenError is returned without sending the request while the circuit breaker of the endpoint is open
+type CircuitOpenError struct {
+	Endpoint string
+	// RetryAfter is the time left before the circuit breaker lets a request through again
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("the circuit breaker of %s is open, retry after %v", e.Endpoint, e.RetryAfter)
+}
+
+// IsRetryableErr returns true if the request failed for a transient reason or was refused by an open circuit breaker
+func IsRetryableErr(err error) bool {
+	var re *RetryableError
+	return errors.As(err, &re) || IsCircuitOpenErr(err)
+}
+
+// IsCircuitOpenErr returns true if the request was refused by an open circuit breaker
+func IsCircuitOpenErr(err error) bool {
+	var ce *CircuitOpenError
+	return errors.As(err, &ce)
 }
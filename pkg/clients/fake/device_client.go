@@ -0,0 +1,325 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+)
+
+// FakeDeviceClient implements clients.DeviceInterface on top of a Store
+type FakeDeviceClient struct {
+	*Store
+}
+
+var _ clients.DeviceInterface = &FakeDeviceClient{}
+
+// NewFakeDeviceClient creates a FakeDeviceClient sharing the objects of the given store
+func NewFakeDeviceClient(store *Store) *FakeDeviceClient {
+	return &FakeDeviceClient{Store: store}
+}
+
+func (c *FakeDeviceClient) Create(ctx context.Context, device *devicev1alpha1.Device, options clients.CreateOptions) (*devicev1alpha1.Device, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(CreateVerb, DeviceKind, name); err != nil {
+		return nil, err
+	}
+	if _, exist := c.devices[name]; exist {
+		return nil, statusErr(http.StatusConflict, "device %s already exists", name)
+	}
+	ed := c.toEdgeDevice(name, device)
+	c.devices[name] = ed
+	c.publishChange(clients.DeviceEventKind, false, name)
+	createdDevice := device.DeepCopy()
+	createdDevice.Status.EdgeId = ed.Status.EdgeId
+	createdDevice.Status.Synced = true
+	return createdDevice, nil
+}
+
+func (c *FakeDeviceClient) Delete(ctx context.Context, name string, options clients.DeleteOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injectedError(DeleteVerb, DeviceKind, name); err != nil {
+		return err
+	}
+	if _, exist := c.devices[name]; !exist {
+		return statusErr(http.StatusNotFound, "device %s not found", name)
+	}
+	delete(c.devices, name)
+	delete(c.properties, name)
+	c.Cache.Invalidate(name)
+	c.publish(clients.Event{Kind: clients.DeviceEventKind, Action: clients.DeleteAction, Name: name})
+	return nil
+}
+
+// Update replaces all the edge-side fields of the device, the admin and operating state are only updated when they are set
+func (c *FakeDeviceClient) Update(ctx context.Context, device *devicev1alpha1.Device, options clients.UpdateOptions) (*devicev1alpha1.Device, error) {
+	if device == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(UpdateVerb, DeviceKind, name); err != nil {
+		return nil, err
+	}
+	old, exist := c.devices[name]
+	if !exist {
+		return nil, statusErr(http.StatusNotFound, "device %s not found", name)
+	}
+	if len(options.Fields) != 0 {
+		patched := old.DeepCopy()
+		patchDeviceSpec(&patched.Spec, &device.Spec, options.Fields)
+		device = patched
+	}
+	ed := c.toEdgeDevice(name, device)
+	ed.Status.EdgeId = old.Status.EdgeId
+	if ed.Spec.AdminState == "" {
+		ed.Spec.AdminState = old.Spec.AdminState
+		ed.Status.AdminState = old.Status.AdminState
+	}
+	if ed.Spec.OperatingState == "" {
+		ed.Spec.OperatingState = old.Spec.OperatingState
+		ed.Status.OperatingState = old.Status.OperatingState
+	}
+	c.devices[name] = ed
+	c.publishChange(clients.DeviceEventKind, true, name)
+	return device, nil
+}
+
+func (c *FakeDeviceClient) Get(ctx context.Context, name string, options clients.GetOptions) (*devicev1alpha1.Device, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if err := c.injectedError(GetVerb, DeviceKind, name); err != nil {
+		return nil, err
+	}
+	ed, exist := c.devices[name]
+	if !exist {
+		return nil, statusErr(http.StatusNotFound, "Device %s not found", name)
+	}
+	return ed.DeepCopy(), nil
+}
+
+func (c *FakeDeviceClient) List(ctx context.Context, options clients.ListOptions) ([]devicev1alpha1.Device, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if err := c.injectedError(ListVerb, DeviceKind, ""); err != nil {
+		return nil, err
+	}
+	if err := clients.ValidateFieldSelector("devices", options.FieldSelector, clients.ServiceNameField, clients.ProfileNameField); err != nil {
+		return nil, err
+	}
+	var res []devicev1alpha1.Device
+	for _, ed := range c.devices {
+		if options.Matches(ed.Spec.Labels, clients.DeviceFields(ed)) {
+			res = append(res, *ed.DeepCopy())
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func (c *FakeDeviceClient) ListPages(ctx context.Context, options clients.ListOptions, fn func(devices []devicev1alpha1.Device) error) error {
+	devices, err := c.List(ctx, options)
+	if err != nil {
+		return err
+	}
+	start := 0
+	for _, end := range c.pageBounds(len(devices)) {
+		if err := fn(devices[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+func (c *FakeDeviceClient) GetPropertyState(ctx context.Context, propertyName string, device *devicev1alpha1.Device, options clients.GetOptions) (*devicev1alpha1.ActualPropertyState, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(GetPropertyVerb, DeviceKind, name); err != nil {
+		return nil, err
+	}
+	if aps, ok := c.Cache.Get(name, propertyName, clients.PropertyMaxStaleness(device, propertyName)); ok {
+		return aps, nil
+	}
+	if err := c.checkDeviceAccessible(name); err != nil {
+		return nil, err
+	}
+	// like a command operating several resources, the parameters are read from the properties of the same names
+	if parameters := device.Spec.DeviceProperties[propertyName].DesiredParameters; len(parameters) != 0 {
+		aps := devicev1alpha1.ActualPropertyState{Name: propertyName, ActualParameters: map[string]string{}}
+		for parameterName := range parameters {
+			value, err := c.readProperty(name, parameterName)
+			if err != nil {
+				return nil, err
+			}
+			aps.ActualParameters[parameterName] = value
+		}
+		c.Cache.Set(name, aps)
+		return &aps, nil
+	}
+	value, err := c.readProperty(name, propertyName)
+	if err != nil {
+		return nil, err
+	}
+	aps := c.propertyState(name, propertyName, value)
+	c.Cache.Set(name, aps)
+	return &aps, nil
+}
+
+func (c *FakeDeviceClient) UpdatePropertyState(ctx context.Context, propertyName string, device *devicev1alpha1.Device, options clients.UpdateOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(SetPropertyVerb, DeviceKind, name); err != nil {
+		return err
+	}
+	if err := c.checkDeviceAccessible(name); err != nil {
+		return err
+	}
+	dps, exist := device.Spec.DeviceProperties[propertyName]
+	if !exist {
+		return &clients.NotFoundError{}
+	}
+	c.Cache.Invalidate(name, propertyName)
+	if len(dps.DesiredParameters) != 0 {
+		return c.writeParameters(name, dps.DesiredParameters)
+	}
+	if c.writeFunc != nil {
+		return c.writeFunc(name, dps.Name, dps.DesiredValue)
+	}
+	c.setProperty(name, dps.Name, dps.DesiredValue)
+	return nil
+}
+
+func (c *FakeDeviceClient) ListPropertiesState(ctx context.Context, device *devicev1alpha1.Device, options clients.ListOptions) (map[string]devicev1alpha1.DesiredPropertyState, map[string]devicev1alpha1.ActualPropertyState, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	dpsm := map[string]devicev1alpha1.DesiredPropertyState{}
+	apsm := map[string]devicev1alpha1.ActualPropertyState{}
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(ListPropertyVerb, DeviceKind, name); err != nil {
+		return dpsm, apsm, err
+	}
+	if err := c.checkDeviceAccessible(name); err != nil {
+		return dpsm, apsm, err
+	}
+	for propertyName := range c.properties[name] {
+		if aps, ok := c.Cache.Get(name, propertyName, clients.PropertyRefreshInterval(device, propertyName)); ok {
+			apsm[propertyName] = *aps
+			continue
+		}
+		// like EdgeX, the properties failed to read are listed without value
+		value, err := c.readProperty(name, propertyName)
+		apsm[propertyName] = c.propertyState(name, propertyName, value)
+		if err == nil {
+			c.Cache.Set(name, apsm[propertyName])
+		}
+	}
+	return dpsm, apsm, nil
+}
+
+// ListLatestPropertiesState returns the stored property values, the programmed read function is not called
+// since the device is not visited
+func (c *FakeDeviceClient) ListLatestPropertiesState(ctx context.Context, device *devicev1alpha1.Device, options clients.ListOptions) (map[string]devicev1alpha1.ActualPropertyState, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(LatestPropertyVerb, DeviceKind, name); err != nil {
+		return nil, err
+	}
+	if _, exist := c.devices[name]; !exist {
+		return nil, statusErr(http.StatusNotFound, "events of device %s not found", name)
+	}
+	apsm := map[string]devicev1alpha1.ActualPropertyState{}
+	for propertyName, value := range c.properties[name] {
+		apsm[propertyName] = c.propertyState(name, propertyName, value)
+	}
+	return apsm, nil
+}
+
+// GetCommand reads the property named after the command, like the command EdgeX derives from every deviceResource
+func (c *FakeDeviceClient) GetCommand(ctx context.Context, commandName string, device *devicev1alpha1.Device, options clients.GetOptions) (map[string]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(GetCommandVerb, DeviceKind, name); err != nil {
+		return nil, err
+	}
+	if err := c.checkDeviceAccessible(name); err != nil {
+		return nil, err
+	}
+	value, err := c.readProperty(name, commandName)
+	if err != nil {
+		return nil, err
+	}
+	// like the readings of EdgeX, a binary value is represented by the hash of its payload
+	if aps := c.propertyState(name, commandName, value); aps.Binary != nil {
+		value = aps.Binary.Hash
+	}
+	return map[string]string{commandName: value}, nil
+}
+
+// SetCommand writes every parameter to the property of the same name
+func (c *FakeDeviceClient) SetCommand(ctx context.Context, commandName string, device *devicev1alpha1.Device, parameters map[string]string, options clients.UpdateOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&device.ObjectMeta)
+	if err := c.injectedError(SetCommandVerb, DeviceKind, name); err != nil {
+		return err
+	}
+	if err := c.checkDeviceAccessible(name); err != nil {
+		return err
+	}
+	c.Cache.Invalidate(name)
+	return c.writeParameters(name, parameters)
+}
+
+// writeParameters writes every parameter to the property of the same name, the caller must hold the lock.
+func (c *FakeDeviceClient) writeParameters(deviceName string, parameters map[string]string) error {
+	for propertyName, value := range parameters {
+		c.Cache.Invalidate(deviceName, propertyName)
+		if c.writeFunc != nil {
+			if err := c.writeFunc(deviceName, propertyName, value); err != nil {
+				return err
+			}
+			continue
+		}
+		c.setProperty(deviceName, propertyName, value)
+	}
+	return nil
+}
+
+// checkDeviceAccessible returns an error if the device can not receive commands, the caller must hold the lock
+func (c *FakeDeviceClient) checkDeviceAccessible(name string) error {
+	ed, exist := c.devices[name]
+	if !exist {
+		return &clients.NotFoundError{}
+	}
+	if ed.Spec.AdminState == devicev1alpha1.Locked || ed.Spec.OperatingState == devicev1alpha1.Down {
+		return ErrLocked
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+)
+
+// FakeDeviceProfileClient implements clients.DeviceProfileInterface on top of a Store
+type FakeDeviceProfileClient struct {
+	*Store
+}
+
+var _ clients.DeviceProfileInterface = &FakeDeviceProfileClient{}
+
+// NewFakeDeviceProfileClient creates a FakeDeviceProfileClient sharing the objects of the given store
+func NewFakeDeviceProfileClient(store *Store) *FakeDeviceProfileClient {
+	return &FakeDeviceProfileClient{Store: store}
+}
+
+func (c *FakeDeviceProfileClient) Create(ctx context.Context, deviceProfile *devicev1alpha1.DeviceProfile, options clients.CreateOptions) (*devicev1alpha1.DeviceProfile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&deviceProfile.ObjectMeta)
+	if err := c.injectedError(CreateVerb, DeviceProfileKind, name); err != nil {
+		return nil, err
+	}
+	if _, exist := c.profiles[name]; exist {
+		return nil, statusErr(http.StatusConflict, "deviceprofile %s already exists", name)
+	}
+	edp := c.toEdgeDeviceProfile(name, deviceProfile)
+	c.profiles[name] = edp
+	c.publishChange(clients.DeviceProfileEventKind, false, name)
+	createdDp := deviceProfile.DeepCopy()
+	createdDp.Status.EdgeId = edp.Status.EdgeId
+	createdDp.Status.Synced = true
+	return createdDp, nil
+}
+
+func (c *FakeDeviceProfileClient) Delete(ctx context.Context, name string, options clients.DeleteOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injectedError(DeleteVerb, DeviceProfileKind, name); err != nil {
+		return err
+	}
+	if _, exist := c.profiles[name]; !exist {
+		return statusErr(http.StatusNotFound, "deviceprofile %s not found", name)
+	}
+	delete(c.profiles, name)
+	c.publish(clients.Event{Kind: clients.DeviceProfileEventKind, Action: clients.DeleteAction, Name: name})
+	return nil
+}
+
+// Update replaces the deviceProfile with the given one, or only its options.Fields if they are given
+func (c *FakeDeviceProfileClient) Update(ctx context.Context, deviceProfile *devicev1alpha1.DeviceProfile, options clients.UpdateOptions) (*devicev1alpha1.DeviceProfile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&deviceProfile.ObjectMeta)
+	if err := c.injectedError(UpdateVerb, DeviceProfileKind, name); err != nil {
+		return nil, err
+	}
+	old, exist := c.profiles[name]
+	if !exist {
+		return nil, statusErr(http.StatusNotFound, "deviceprofile %s not found", name)
+	}
+	updated := deviceProfile
+	if len(options.Fields) != 0 {
+		updated = old.DeepCopy()
+		patchDeviceProfileSpec(&updated.Spec, &deviceProfile.Spec, options.Fields)
+	}
+	edp := c.toEdgeDeviceProfile(name, updated)
+	edp.Status.EdgeId = old.Status.EdgeId
+	c.profiles[name] = edp
+	c.publishChange(clients.DeviceProfileEventKind, true, name)
+	return deviceProfile.DeepCopy(), nil
+}
+
+func (c *FakeDeviceProfileClient) Get(ctx context.Context, name string, options clients.GetOptions) (*devicev1alpha1.DeviceProfile, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if err := c.injectedError(GetVerb, DeviceProfileKind, name); err != nil {
+		return nil, err
+	}
+	edp, exist := c.profiles[name]
+	if !exist {
+		return nil, statusErr(http.StatusNotFound, "DeviceProfile %s not found", name)
+	}
+	return edp.DeepCopy(), nil
+}
+
+func (c *FakeDeviceProfileClient) List(ctx context.Context, options clients.ListOptions) ([]devicev1alpha1.DeviceProfile, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if err := c.injectedError(ListVerb, DeviceProfileKind, ""); err != nil {
+		return nil, err
+	}
+	if err := clients.ValidateFieldSelector("deviceProfiles", options.FieldSelector, clients.ManufacturerField, clients.ModelField); err != nil {
+		return nil, err
+	}
+	var res []devicev1alpha1.DeviceProfile
+	for _, edp := range c.profiles {
+		if options.Matches(edp.Spec.Labels, clients.DeviceProfileFields(edp)) {
+			res = append(res, *edp.DeepCopy())
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func (c *FakeDeviceProfileClient) ListPages(ctx context.Context, options clients.ListOptions, fn func(deviceProfiles []devicev1alpha1.DeviceProfile) error) error {
+	deviceProfiles, err := c.List(ctx, options)
+	if err != nil {
+		return err
+	}
+	start := 0
+	for _, end := range c.pageBounds(len(deviceProfiles)) {
+		if err := fn(deviceProfiles[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
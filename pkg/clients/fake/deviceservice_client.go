@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+)
+
+// FakeDeviceServiceClient implements clients.DeviceServiceInterface on top of a Store
+type FakeDeviceServiceClient struct {
+	*Store
+}
+
+var _ clients.DeviceServiceInterface = &FakeDeviceServiceClient{}
+
+// NewFakeDeviceServiceClient creates a FakeDeviceServiceClient sharing the objects of the given store
+func NewFakeDeviceServiceClient(store *Store) *FakeDeviceServiceClient {
+	return &FakeDeviceServiceClient{Store: store}
+}
+
+func (c *FakeDeviceServiceClient) Create(ctx context.Context, deviceService *devicev1alpha1.DeviceService, options clients.CreateOptions) (*devicev1alpha1.DeviceService, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&deviceService.ObjectMeta)
+	if err := c.injectedError(CreateVerb, DeviceServiceKind, name); err != nil {
+		return nil, err
+	}
+	if _, exist := c.services[name]; exist {
+		return nil, statusErr(http.StatusConflict, "deviceservice %s already exists", name)
+	}
+	eds := c.toEdgeDeviceService(name, deviceService)
+	c.services[name] = eds
+	c.publishChange(clients.DeviceServiceEventKind, false, name)
+	createdDs := deviceService.DeepCopy()
+	createdDs.Status.EdgeId = eds.Status.EdgeId
+	createdDs.Status.Synced = true
+	return createdDs, nil
+}
+
+func (c *FakeDeviceServiceClient) Delete(ctx context.Context, name string, options clients.DeleteOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.injectedError(DeleteVerb, DeviceServiceKind, name); err != nil {
+		return err
+	}
+	if _, exist := c.services[name]; !exist {
+		return statusErr(http.StatusNotFound, "deviceservice %s not found", name)
+	}
+	delete(c.services, name)
+	c.publish(clients.Event{Kind: clients.DeviceServiceEventKind, Action: clients.DeleteAction, Name: name})
+	return nil
+}
+
+// Update replaces the deviceService which has the same edge id as the given one
+func (c *FakeDeviceServiceClient) Update(ctx context.Context, deviceService *devicev1alpha1.DeviceService, options clients.UpdateOptions) (*devicev1alpha1.DeviceService, error) {
+	if deviceService == nil {
+		return nil, nil
+	}
+	if deviceService.Status.EdgeId == "" {
+		return nil, fmt.Errorf("failed to update deviceservice %s with empty edge id", deviceService.Name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	name := edgeName(&deviceService.ObjectMeta)
+	if err := c.injectedError(UpdateVerb, DeviceServiceKind, name); err != nil {
+		return nil, err
+	}
+	for n, eds := range c.services {
+		if eds.Status.EdgeId == deviceService.Status.EdgeId {
+			updated := deviceService
+			if len(options.Fields) != 0 {
+				updated = eds.DeepCopy()
+				patchDeviceServiceSpec(&updated.Spec, &deviceService.Spec, options.Fields)
+			}
+			c.services[n] = c.toEdgeDeviceService(n, updated)
+			c.publishChange(clients.DeviceServiceEventKind, true, n)
+			return deviceService, nil
+		}
+	}
+	return nil, statusErr(http.StatusNotFound, "deviceservice %s not found", name)
+}
+
+func (c *FakeDeviceServiceClient) Get(ctx context.Context, name string, options clients.GetOptions) (*devicev1alpha1.DeviceService, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if err := c.injectedError(GetVerb, DeviceServiceKind, name); err != nil {
+		return nil, err
+	}
+	eds, exist := c.services[name]
+	if !exist {
+		return nil, statusErr(http.StatusNotFound, "deviceservice %s not found", name)
+	}
+	return eds.DeepCopy(), nil
+}
+
+func (c *FakeDeviceServiceClient) List(ctx context.Context, options clients.ListOptions) ([]devicev1alpha1.DeviceService, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if err := c.injectedError(ListVerb, DeviceServiceKind, ""); err != nil {
+		return nil, err
+	}
+	if err := clients.ValidateFieldSelector("deviceServices", options.FieldSelector); err != nil {
+		return nil, err
+	}
+	var res []devicev1alpha1.DeviceService
+	for _, eds := range c.services {
+		if options.Matches(eds.Spec.Labels, nil) {
+			res = append(res, *eds.DeepCopy())
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+func (c *FakeDeviceServiceClient) ListPages(ctx context.Context, options clients.ListOptions, fn func(deviceServices []devicev1alpha1.DeviceService) error) error {
+	deviceServices, err := c.List(ctx, options)
+	if err != nil {
+		return err
+	}
+	start := 0
+	for _, end := range c.pageBounds(len(deviceServices)) {
+		if err := fn(deviceServices[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
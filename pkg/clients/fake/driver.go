@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"github.com/openyurtio/device-controller/pkg/clients"
+)
+
+// DriverName is the name by which the fake driver is registered,
+// it allows to run the controller without an edge platform
+const DriverName = "fake"
+
+// DefaultStore is the store shared by all the clients created by the fake driver
+var DefaultStore = NewStore()
+
+func init() {
+	clients.RegisterDriver(DriverName, func(cfg clients.DriverConfig) (clients.Driver, error) {
+		return fakeDriver{}, nil
+	})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) NewDeviceClient() (clients.DeviceInterface, error) {
+	return NewFakeDeviceClient(DefaultStore), nil
+}
+
+func (fakeDriver) NewDeviceServiceClient() (clients.DeviceServiceInterface, error) {
+	return NewFakeDeviceServiceClient(DefaultStore), nil
+}
+
+func (fakeDriver) NewDeviceProfileClient() (clients.DeviceProfileInterface, error) {
+	return NewFakeDeviceProfileClient(DefaultStore), nil
+}
+
+func (fakeDriver) NewEventClient() (clients.EventInterface, error) {
+	return NewFakeEventClient(DefaultStore), nil
+}
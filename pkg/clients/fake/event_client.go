@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+
+	"github.com/openyurtio/device-controller/pkg/clients"
+)
+
+// eventBufferSize is the number of events buffered for a subscriber,
+// the events published to a full buffer are dropped like on a congested message bus
+const eventBufferSize = 100
+
+// FakeEventClient implements clients.EventInterface on top of a Store,
+// the subscribers receive the changes made to the store
+type FakeEventClient struct {
+	*Store
+}
+
+var _ clients.EventInterface = &FakeEventClient{}
+
+// NewFakeEventClient creates a FakeEventClient subscribing to the given store
+func NewFakeEventClient(store *Store) *FakeEventClient {
+	return &FakeEventClient{Store: store}
+}
+
+func (c *FakeEventClient) Subscribe(ctx context.Context, handler clients.EventHandler) error {
+	events := make(chan clients.Event, eventBufferSize)
+	c.mu.Lock()
+	c.subscribers = append(c.subscribers, events)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.unsubscribe(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-events:
+				handler(e)
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *FakeEventClient) unsubscribe(events chan clients.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.subscribers {
+		if c.subscribers[i] == events {
+			c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+			return
+		}
+	}
+}
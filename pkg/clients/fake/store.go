@@ -0,0 +1,447 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EdgeObjectName is the label holding the name of the object on the edge platform,
+// it has to be the same as the label used by the controllers
+const EdgeObjectName = "device-controller/edgex-object.name"
+
+// Verb is the kind of operation done on the fake edge platform
+type Verb string
+
+const (
+	CreateVerb         Verb = "create"
+	DeleteVerb         Verb = "delete"
+	UpdateVerb         Verb = "update"
+	GetVerb            Verb = "get"
+	ListVerb           Verb = "list"
+	GetPropertyVerb    Verb = "getproperty"
+	SetPropertyVerb    Verb = "setproperty"
+	ListPropertyVerb   Verb = "listproperty"
+	LatestPropertyVerb Verb = "latestproperty"
+	GetCommandVerb     Verb = "getcommand"
+	SetCommandVerb     Verb = "setcommand"
+)
+
+// Kind is the kind of object on the fake edge platform
+type Kind string
+
+const (
+	DeviceKind        Kind = "Device"
+	DeviceServiceKind Kind = "DeviceService"
+	DeviceProfileKind Kind = "DeviceProfile"
+)
+
+var (
+	// ErrLocked is returned when the device is locked (AdminState) or down (OperatingState), like a 423 of EdgeX
+	ErrLocked = statusErr(http.StatusLocked, "the device is locked (AdminState) or down (OperatingState)")
+	// ErrTimeout is returned when the edge platform does not respond in time
+	ErrTimeout = statusErr(http.StatusGatewayTimeout, "request to the edge platform timed out")
+)
+
+// statusErr returns the typed error the edge platform would report with the status code
+func statusErr(statusCode int, format string, a ...interface{}) error {
+	return clients.NewStatusError(statusCode, 0, fmt.Sprintf(format, a...))
+}
+
+// PropertyReadFunc returns the actual value of the property of a device
+type PropertyReadFunc func(deviceName, propertyName string) (string, error)
+
+// PropertyWriteFunc sets the property of a device to the given value
+type PropertyWriteFunc func(deviceName, propertyName, value string) error
+
+type injectedError struct {
+	verb Verb
+	kind Kind
+	name string
+	err  error
+}
+
+// Store keeps the objects and the device property values of a fake edge platform in memory.
+// All the clients created from the same store share the objects.
+type Store struct {
+	mu         sync.RWMutex
+	nextID     int
+	devices    map[string]*devicev1alpha1.Device
+	services   map[string]*devicev1alpha1.DeviceService
+	profiles   map[string]*devicev1alpha1.DeviceProfile
+	properties map[string]map[string]string
+	// the media types of the binary properties, whose values are stored in base64
+	mediaTypes map[string]map[string]string
+	errs       []injectedError
+	readFunc   PropertyReadFunc
+	writeFunc  PropertyWriteFunc
+	pageSize   int
+	// the event channels of the subscribers
+	subscribers []chan clients.Event
+	// Cache keeps the property values read by the device clients like the cache of the EdgeX device client,
+	// the values set on the store directly are only seen once the cached ones are stale
+	Cache *clients.PropertyCache
+}
+
+// NewStore creates an empty Store
+func NewStore() *Store {
+	return &Store{
+		devices:    map[string]*devicev1alpha1.Device{},
+		services:   map[string]*devicev1alpha1.DeviceService{},
+		profiles:   map[string]*devicev1alpha1.DeviceProfile{},
+		properties: map[string]map[string]string{},
+		mediaTypes: map[string]map[string]string{},
+		Cache:      clients.NewPropertyCache(),
+	}
+}
+
+// SetPageSize sets the number of objects in a page of ListPages, all the objects are in a single page if it is 0
+func (s *Store) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// pageBounds returns the end of the pages of count objects for ListPages
+func (s *Store) pageBounds(count int) []int {
+	s.mu.RLock()
+	size := s.pageSize
+	s.mu.RUnlock()
+	if size <= 0 {
+		size = count
+	}
+	var ends []int
+	for end := size; end < count; end += size {
+		ends = append(ends, end)
+	}
+	if count > 0 {
+		ends = append(ends, count)
+	}
+	return ends
+}
+
+// InjectError makes the matched operations fail with err until ClearErrors is called.
+// An empty verb, kind or name matches everything, the errors injected first take precedence.
+func (s *Store) InjectError(verb Verb, kind Kind, name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, injectedError{verb: verb, kind: kind, name: name, err: err})
+}
+
+// ClearErrors removes all the injected errors
+func (s *Store) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = nil
+}
+
+// SetPropertyReadFunc replaces the default behaviour of reading the stored property values,
+// set it to nil to restore the default. The function is called with the store locked, so it must not use the store.
+func (s *Store) SetPropertyReadFunc(f PropertyReadFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readFunc = f
+}
+
+// SetPropertyWriteFunc replaces the default behaviour of storing the written property values,
+// set it to nil to restore the default. The function is called with the store locked, so it must not use the store.
+func (s *Store) SetPropertyWriteFunc(f PropertyWriteFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeFunc = f
+}
+
+// SetProperty sets the actual value of the property of a device as if it was reported by the device,
+// the subscribers receive it as a reading
+func (s *Store) SetProperty(deviceName, propertyName, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setProperty(deviceName, propertyName, value)
+	s.publish(clients.Event{Kind: clients.DeviceEventKind, Action: clients.ReadingAction, Name: deviceName,
+		Readings: map[string]string{propertyName: value}})
+}
+
+// SetBinaryProperty sets the actual value of a binary property of a device as if it was reported by the device,
+// the subscribers receive the hash of the payload as a reading
+func (s *Store) SetBinaryProperty(deviceName, propertyName, mediaType string, payload []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setProperty(deviceName, propertyName, base64.StdEncoding.EncodeToString(payload))
+	if _, ok := s.mediaTypes[deviceName]; !ok {
+		s.mediaTypes[deviceName] = map[string]string{}
+	}
+	s.mediaTypes[deviceName][propertyName] = mediaType
+	s.publish(clients.Event{Kind: clients.DeviceEventKind, Action: clients.ReadingAction, Name: deviceName,
+		Readings: map[string]string{propertyName: clients.PayloadHash(payload)}})
+}
+
+// GetProperty returns the stored value of the property of a device
+func (s *Store) GetProperty(deviceName, propertyName string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.properties[deviceName][propertyName]
+	return v, ok
+}
+
+// AddDevice puts the device to the store as if it was created on the edge platform directly
+func (s *Store) AddDevice(d *devicev1alpha1.Device) *devicev1alpha1.Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := edgeName(&d.ObjectMeta)
+	_, exist := s.devices[name]
+	s.devices[name] = s.toEdgeDevice(name, d)
+	s.publishChange(clients.DeviceEventKind, exist, name)
+	return s.devices[name].DeepCopy()
+}
+
+// AddDeviceService puts the deviceService to the store as if it was created on the edge platform directly
+func (s *Store) AddDeviceService(ds *devicev1alpha1.DeviceService) *devicev1alpha1.DeviceService {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := edgeName(&ds.ObjectMeta)
+	_, exist := s.services[name]
+	s.services[name] = s.toEdgeDeviceService(name, ds)
+	s.publishChange(clients.DeviceServiceEventKind, exist, name)
+	return s.services[name].DeepCopy()
+}
+
+// AddDeviceProfile puts the deviceProfile to the store as if it was created on the edge platform directly
+func (s *Store) AddDeviceProfile(dp *devicev1alpha1.DeviceProfile) *devicev1alpha1.DeviceProfile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := edgeName(&dp.ObjectMeta)
+	_, exist := s.profiles[name]
+	s.profiles[name] = s.toEdgeDeviceProfile(name, dp)
+	s.publishChange(clients.DeviceProfileEventKind, exist, name)
+	return s.profiles[name].DeepCopy()
+}
+
+func (s *Store) injectedError(verb Verb, kind Kind, name string) error {
+	for _, ie := range s.errs {
+		if (ie.verb == "" || ie.verb == verb) && (ie.kind == "" || ie.kind == kind) && (ie.name == "" || ie.name == name) {
+			return ie.err
+		}
+	}
+	return nil
+}
+
+// publish sends the event to all the subscribers without blocking, the caller must hold the lock
+func (s *Store) publish(e clients.Event) {
+	for _, events := range s.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// publishChange publishes that the object was added or updated, the caller must hold the lock
+func (s *Store) publishChange(kind clients.EventKind, updated bool, name string) {
+	action := clients.AddAction
+	if updated {
+		action = clients.UpdateAction
+	}
+	s.publish(clients.Event{Kind: kind, Action: action, Name: name})
+}
+
+func (s *Store) setProperty(deviceName, propertyName, value string) {
+	if _, ok := s.properties[deviceName]; !ok {
+		s.properties[deviceName] = map[string]string{}
+	}
+	s.properties[deviceName][propertyName] = value
+	delete(s.mediaTypes[deviceName], propertyName)
+}
+
+// propertyState returns the state of a property with the value read from the device,
+// the caller must hold the lock.
+func (s *Store) propertyState(deviceName, propertyName, value string) devicev1alpha1.ActualPropertyState {
+	if mediaType, ok := s.mediaTypes[deviceName][propertyName]; ok {
+		if payload, err := base64.StdEncoding.DecodeString(value); err == nil {
+			return clients.NewBinaryPropertyState(propertyName, mediaType, payload)
+		}
+	}
+	return devicev1alpha1.ActualPropertyState{Name: propertyName, ActualValue: value}
+}
+
+// readProperty reads a property with the programmed function or from the stored values,
+// the caller must hold the lock.
+func (s *Store) readProperty(deviceName, propertyName string) (string, error) {
+	if s.readFunc != nil {
+		return s.readFunc(deviceName, propertyName)
+	}
+	v, ok := s.properties[deviceName][propertyName]
+	if !ok {
+		return "", &clients.NotFoundError{}
+	}
+	return v, nil
+}
+
+func (s *Store) newID() string {
+	s.nextID++
+	return fmt.Sprintf("fake-%08d", s.nextID)
+}
+
+// edgeName returns the name of the object on the edge platform
+func edgeName(meta *metav1.ObjectMeta) string {
+	if name, ok := meta.Labels[EdgeObjectName]; ok {
+		return name
+	}
+	return meta.Name
+}
+
+// edgeObjectMeta returns the metadata of an object listed from the edge platform
+func edgeObjectMeta(name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      strings.ToLower(name),
+		Namespace: "default",
+		Labels: map[string]string{
+			EdgeObjectName: name,
+		},
+	}
+}
+
+func (s *Store) toEdgeDevice(name string, d *devicev1alpha1.Device) *devicev1alpha1.Device {
+	ed := &devicev1alpha1.Device{
+		ObjectMeta: edgeObjectMeta(name),
+		Spec:       *d.Spec.DeepCopy(),
+	}
+	// the properties and the deletion policy are not kept by the edge platform
+	ed.Spec.DeviceProperties = nil
+	ed.Spec.PropertySource = ""
+	ed.Spec.DeletionPolicy = ""
+	ed.Status = devicev1alpha1.DeviceStatus{
+		EdgeId:         d.Status.EdgeId,
+		Synced:         true,
+		AdminState:     ed.Spec.AdminState,
+		OperatingState: ed.Spec.OperatingState,
+		AutoEvents:     ed.Spec.AutoEvents,
+	}
+	if ed.Status.EdgeId == "" {
+		ed.Status.EdgeId = s.newID()
+	}
+	return ed
+}
+
+// patchDeviceSpec copies the given fields of the spec from src to dst, as the edge platform patches a device
+func patchDeviceSpec(dst, src *devicev1alpha1.DeviceSpec, fields []string) {
+	for _, field := range fields {
+		switch field {
+		case "description":
+			dst.Description = src.Description
+		case "adminState":
+			dst.AdminState = src.AdminState
+		case "operatingState":
+			dst.OperatingState = src.OperatingState
+		case "labels":
+			dst.Labels = src.Labels
+		case "protocols":
+			dst.Protocols = src.Protocols
+		case "location":
+			dst.Location = src.Location
+		case "autoEvents":
+			dst.AutoEvents = src.AutoEvents
+		case "serviceName":
+			dst.Service = src.Service
+		case "profileName":
+			dst.Profile = src.Profile
+		case "notify":
+			dst.Notify = src.Notify
+		}
+	}
+}
+
+// patchDeviceServiceSpec copies the given fields of the spec from src to dst, as the edge platform patches a deviceService
+func patchDeviceServiceSpec(dst, src *devicev1alpha1.DeviceServiceSpec, fields []string) {
+	for _, field := range fields {
+		switch field {
+		case "description":
+			dst.Description = src.Description
+		case "adminState":
+			dst.AdminState = src.AdminState
+		case "labels":
+			dst.Labels = src.Labels
+		case "baseAddress":
+			dst.BaseAddress = src.BaseAddress
+		}
+	}
+}
+
+// patchDeviceProfileSpec copies the given fields of the spec from src to dst, as the edge platform updates a deviceProfile
+func patchDeviceProfileSpec(dst, src *devicev1alpha1.DeviceProfileSpec, fields []string) {
+	for _, field := range fields {
+		switch field {
+		case "description":
+			dst.Description = src.Description
+		case "manufacturer":
+			dst.Manufacturer = src.Manufacturer
+		case "model":
+			dst.Model = src.Model
+		case "labels":
+			dst.Labels = src.Labels
+		case "deviceResources":
+			dst.DeviceResources = src.DeviceResources
+		case "deviceCommands":
+			dst.DeviceCommands = src.DeviceCommands
+		}
+	}
+}
+
+func (s *Store) toEdgeDeviceService(name string, ds *devicev1alpha1.DeviceService) *devicev1alpha1.DeviceService {
+	eds := &devicev1alpha1.DeviceService{
+		ObjectMeta: edgeObjectMeta(name),
+		Spec:       *ds.Spec.DeepCopy(),
+	}
+	// the deletion policy is not kept by the edge platform
+	eds.Spec.DeletionPolicy = ""
+	eds.Status = devicev1alpha1.DeviceServiceStatus{
+		EdgeId:        ds.Status.EdgeId,
+		Synced:        true,
+		LastConnected: ds.Status.LastConnected,
+		LastReported:  ds.Status.LastReported,
+		AdminState:    eds.Spec.AdminState,
+	}
+	if eds.Status.EdgeId == "" {
+		eds.Status.EdgeId = s.newID()
+	}
+	return eds
+}
+
+func (s *Store) toEdgeDeviceProfile(name string, dp *devicev1alpha1.DeviceProfile) *devicev1alpha1.DeviceProfile {
+	edp := &devicev1alpha1.DeviceProfile{
+		ObjectMeta: edgeObjectMeta(name),
+		Spec:       *dp.Spec.DeepCopy(),
+	}
+	// the deletion policy is not kept by the edge platform
+	edp.Spec.DeletionPolicy = ""
+	edp.Status = devicev1alpha1.DeviceProfileStatus{
+		EdgeId: dp.Status.EdgeId,
+		Synced: true,
+	}
+	if edp.Status.EdgeId == "" {
+		edp.Status.EdgeId = s.newID()
+	}
+	return edp
+}
@@ -32,7 +32,11 @@ type DeleteOptions struct{}
 
 // UpdateOptions defines additional options when updating an object
 // Additional general field definitions can be added
-type UpdateOptions struct{}
+type UpdateOptions struct {
+	// Fields restricts the update of a device to the given fields of its spec, named after their JSON keys,
+	// e.g. `labels`, the other fields are left unchanged on the edge platform. All the fields are updated if it is empty.
+	Fields []string
+}
 
 // GetOptions defines additional options when getting an object
 // Additional general field definitions can be added
@@ -40,11 +44,14 @@ type GetOptions struct{}
 
 // ListOptions defines additional options when listing an object
 type ListOptions struct {
-	// A selector to restrict the list of returned objects by their labels.
+	// A selector to restrict the list of returned objects by their labels,
+	// the objects must have all the labels given by SelectorLabels.
 	// Defaults to everything.
 	// +optional
 	LabelSelector map[string]string
-	// A selector to restrict the list of returned objects by their fields.
+	// A selector to restrict the list of returned objects by their fields,
+	// e.g. ServiceNameField and ProfileNameField of devices. A field that is not supported
+	// by the kind of the objects is rejected with an InvalidRequestError.
 	// Defaults to everything.
 	// +optional
 	FieldSelector map[string]string
@@ -53,11 +60,15 @@ type ListOptions struct {
 // DeviceInterface defines the interfaces which used to create, delete, update, get and list Device objects on edge-side platform
 type DeviceInterface interface {
 	DevicePropertyInterface
+	DeviceCommandInterface
 	Create(ctx context.Context, device *devicev1alpha1.Device, options CreateOptions) (*devicev1alpha1.Device, error)
 	Delete(ctx context.Context, name string, options DeleteOptions) error
 	Update(ctx context.Context, device *devicev1alpha1.Device, options UpdateOptions) (*devicev1alpha1.Device, error)
 	Get(ctx context.Context, name string, options GetOptions) (*devicev1alpha1.Device, error)
 	List(ctx context.Context, options ListOptions) ([]devicev1alpha1.Device, error)
+	// ListPages iterates over the devices selected by the options page by page, fn is called with every page
+	// and the iteration stops at the first error returned by the edge platform or fn
+	ListPages(ctx context.Context, options ListOptions, fn func(devices []devicev1alpha1.Device) error) error
 }
 
 // DevicePropertyInterface defines the interfaces which used to get, list and set the actual status value of the device properties
@@ -65,6 +76,16 @@ type DevicePropertyInterface interface {
 	GetPropertyState(ctx context.Context, propertyName string, device *devicev1alpha1.Device, options GetOptions) (*devicev1alpha1.ActualPropertyState, error)
 	UpdatePropertyState(ctx context.Context, propertyName string, device *devicev1alpha1.Device, options UpdateOptions) error
 	ListPropertiesState(ctx context.Context, device *devicev1alpha1.Device, options ListOptions) (map[string]devicev1alpha1.DesiredPropertyState, map[string]devicev1alpha1.ActualPropertyState, error)
+	// ListLatestPropertiesState gets the latest property values reported by the device without sending any command to it
+	ListLatestPropertiesState(ctx context.Context, device *devicev1alpha1.Device, options ListOptions) (map[string]devicev1alpha1.ActualPropertyState, error)
+}
+
+// DeviceCommandInterface defines the interfaces which used to run the commands of the device profile on the device
+type DeviceCommandInterface interface {
+	// GetCommand reads the command from the device and returns the readings, keyed by the deviceResource names
+	GetCommand(ctx context.Context, commandName string, device *devicev1alpha1.Device, options GetOptions) (map[string]string, error)
+	// SetCommand writes the parameters, keyed by the deviceResource names, to the device by the command
+	SetCommand(ctx context.Context, commandName string, device *devicev1alpha1.Device, parameters map[string]string, options UpdateOptions) error
 }
 
 // DeviceServiceInterface defines the interfaces which used to create, delete, update, get and list DeviceService objects on edge-side platform
@@ -74,6 +95,9 @@ type DeviceServiceInterface interface {
 	Update(ctx context.Context, deviceService *devicev1alpha1.DeviceService, options UpdateOptions) (*devicev1alpha1.DeviceService, error)
 	Get(ctx context.Context, name string, options GetOptions) (*devicev1alpha1.DeviceService, error)
 	List(ctx context.Context, options ListOptions) ([]devicev1alpha1.DeviceService, error)
+	// ListPages iterates over the deviceServices selected by the options page by page, fn is called with every page
+	// and the iteration stops at the first error returned by the edge platform or fn
+	ListPages(ctx context.Context, options ListOptions, fn func(deviceServices []devicev1alpha1.DeviceService) error) error
 }
 
 // DeviceProfileInterface defines the interfaces which used to create, delete, update, get and list DeviceProfile objects on edge-side platform
@@ -83,4 +107,51 @@ type DeviceProfileInterface interface {
 	Update(ctx context.Context, deviceProfile *devicev1alpha1.DeviceProfile, options UpdateOptions) (*devicev1alpha1.DeviceProfile, error)
 	Get(ctx context.Context, name string, options GetOptions) (*devicev1alpha1.DeviceProfile, error)
 	List(ctx context.Context, options ListOptions) ([]devicev1alpha1.DeviceProfile, error)
+	// ListPages iterates over the deviceProfiles selected by the options page by page, fn is called with every page
+	// and the iteration stops at the first error returned by the edge platform or fn
+	ListPages(ctx context.Context, options ListOptions, fn func(deviceProfiles []devicev1alpha1.DeviceProfile) error) error
+}
+
+// EventKind is the kind of edge-side object an Event is about
+type EventKind string
+
+const (
+	DeviceEventKind        EventKind = "Device"
+	DeviceServiceEventKind EventKind = "DeviceService"
+	DeviceProfileEventKind EventKind = "DeviceProfile"
+)
+
+// EventAction is the change an Event reports
+type EventAction string
+
+const (
+	AddAction    EventAction = "add"
+	UpdateAction EventAction = "update"
+	DeleteAction EventAction = "delete"
+	// ReadingAction reports new readings of the device properties
+	ReadingAction EventAction = "reading"
+	// ResyncAction reports that events may have been lost, e.g. the subscription was restored after
+	// reconnecting, so all the objects should be synchronized. It has no kind nor name.
+	ResyncAction EventAction = "resync"
+)
+
+// Event reports a change of an object on the edge-side platform
+type Event struct {
+	Kind   EventKind
+	Action EventAction
+	// Name is the name of the object on the edge-side platform
+	Name string
+	// Readings are the reported property values of a reading event, keyed by the property name
+	Readings map[string]string
+}
+
+// EventHandler handles the events received from the edge-side platform, it should return quickly
+// and be safe to call concurrently since the resync events may be delivered from another goroutine
+type EventHandler func(event Event)
+
+// EventInterface defines the interface used to subscribe to the changes of the objects on edge-side platform
+type EventInterface interface {
+	// Subscribe delivers the events to the handler until the context is done,
+	// it returns an error if the subscription can't be set up
+	Subscribe(ctx context.Context, handler EventHandler) error
 }
@@ -0,0 +1,106 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"sync"
+	"time"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+)
+
+// PropertyCache keeps the property values last read from the devices, keyed by the device name and the property name,
+// so that the device clients don't read a property again before it is stale. A nil PropertyCache caches nothing.
+type PropertyCache struct {
+	mu       sync.RWMutex
+	readings map[string]map[string]cachedProperty
+	// Now returns the current time, it can be replaced by tests
+	Now func() time.Time
+}
+
+type cachedProperty struct {
+	state  devicev1alpha1.ActualPropertyState
+	readAt time.Time
+}
+
+// NewPropertyCache creates an empty PropertyCache
+func NewPropertyCache() *PropertyCache {
+	return &PropertyCache{
+		readings: map[string]map[string]cachedProperty{},
+		Now:      time.Now,
+	}
+}
+
+// Get returns the property of the device if it was read within maxAge, nothing is fresh if maxAge is not positive
+func (c *PropertyCache) Get(deviceName, propertyName string, maxAge time.Duration) (*devicev1alpha1.ActualPropertyState, bool) {
+	if c == nil || maxAge <= 0 {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cp, ok := c.readings[deviceName][propertyName]
+	if !ok || c.Now().Sub(cp.readAt) >= maxAge {
+		return nil, false
+	}
+	return cp.state.DeepCopy(), true
+}
+
+// Set records the property just read from the device
+func (c *PropertyCache) Set(deviceName string, state devicev1alpha1.ActualPropertyState) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.readings[deviceName]; !ok {
+		c.readings[deviceName] = map[string]cachedProperty{}
+	}
+	c.readings[deviceName][state.Name] = cachedProperty{state: *state.DeepCopy(), readAt: c.Now()}
+}
+
+// Invalidate forgets the given properties of the device, or all of them if no property is given,
+// e.g. once they have been written or the device has been deleted
+func (c *PropertyCache) Invalidate(deviceName string, propertyNames ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(propertyNames) == 0 {
+		delete(c.readings, deviceName)
+		return
+	}
+	for _, name := range propertyNames {
+		delete(c.readings[deviceName], name)
+	}
+}
+
+// PropertyRefreshInterval returns the refresh interval of the property declared by the device, 0 if it is not declared
+func PropertyRefreshInterval(device *devicev1alpha1.Device, propertyName string) time.Duration {
+	if dps, ok := device.Spec.DeviceProperties[propertyName]; ok && dps.RefreshInterval != nil {
+		return dps.RefreshInterval.Duration
+	}
+	return 0
+}
+
+// PropertyMaxStaleness returns the max staleness of the property declared by the device, 0 if it is not declared
+func PropertyMaxStaleness(device *devicev1alpha1.Device, propertyName string) time.Duration {
+	if dps, ok := device.Spec.DeviceProperties[propertyName]; ok && dps.MaxStaleness != nil {
+		return dps.MaxStaleness.Duration
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DriverConfig describes the edge platform the clients of a driver talk to,
+// the controller fills it in from its command line options
+type DriverConfig struct {
+	// the addresses of the core services of the edge platform
+	CoreDataAddr     string
+	CoreMetadataAddr string
+	CoreCommandAddr  string
+	// ListPageSize is the number of objects fetched per request when listing the objects on the edge platform
+	ListPageSize int
+	// MessageBusAddr and MessageBusBaseTopic locate the message bus the edge platform publishes its changes on,
+	// the event client can't be created if the address is empty
+	MessageBusAddr      string
+	MessageBusBaseTopic string
+	// RequestRetries is the number of times a request that failed for a transient reason is sent again
+	RequestRetries int
+	// BreakerThreshold is the number of consecutive failures of a service that opens its circuit breaker,
+	// and BreakerOpenTimeout is how long the open circuit breaker refuses the requests
+	BreakerThreshold   int
+	BreakerOpenTimeout time.Duration
+	// TLS makes the clients use HTTPS, the certificates of the edge platform are verified with the CA bundle
+	// in CAFile, and the optional client certificate in CertFile and KeyFile is presented to it
+	TLS      bool
+	CAFile   string
+	CertFile string
+	KeyFile  string
+	// TokenFile, or the TokenSecretKey of the secret TokenSecretNamespace/TokenSecretName read with SecretReader,
+	// holds the token sent to the edge platform, it is loaded again every TokenRefreshPeriod, or only once it is
+	// rejected if TokenRefreshPeriod is 0.
+	// The token is only sent over TLS.
+	TokenFile            string
+	TokenSecretNamespace string
+	TokenSecretName      string
+	TokenSecretKey       string
+	TokenRefreshPeriod   time.Duration
+	SecretReader         client.Reader
+}
+
+// Driver creates the clients used to manage the objects on a kind of edge platform,
+// it is opened once at startup and shared by all the reconcilers and syncers
+type Driver interface {
+	NewDeviceClient() (DeviceInterface, error)
+	NewDeviceServiceClient() (DeviceServiceInterface, error)
+	NewDeviceProfileClient() (DeviceProfileInterface, error)
+	// NewEventClient creates the client subscribing to the changes on the edge platform,
+	// it is only used when the message bus is configured
+	NewEventClient() (EventInterface, error)
+}
+
+// OpenFunc opens a driver for the edge platform described by the config
+type OpenFunc func(cfg DriverConfig) (Driver, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]OpenFunc{}
+)
+
+// RegisterDriver makes an edge platform driver available by the provided name.
+// Drivers are usually registered in the init function of their package,
+// it panics if the same name is registered twice or the open function is nil.
+func RegisterDriver(name string, open OpenFunc) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if open == nil {
+		panic("clients: RegisterDriver open function is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("clients: RegisterDriver called twice for driver " + name)
+	}
+	drivers[name] = open
+}
+
+// OpenDriver opens the edge platform driver registered by the provided name with the config
+func OpenDriver(name string, cfg DriverConfig) (Driver, error) {
+	driversMu.RLock()
+	open, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown edge platform %q, the supported edge platforms are %v", name, Drivers())
+	}
+	return open(cfg)
+}
+
+// Drivers returns the sorted names of the registered edge platform drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	return driverNames()
+}
+
+func driverNames() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+)
+
+// The fields supported by the FieldSelector of ListOptions
+const (
+	// ServiceNameField selects the devices of a deviceService
+	ServiceNameField = "serviceName"
+	// ProfileNameField selects the devices of a deviceProfile
+	ProfileNameField = "profileName"
+	// ManufacturerField selects the deviceProfiles of a manufacturer
+	ManufacturerField = "manufacturer"
+	// ModelField selects the deviceProfiles of a model
+	ModelField = "model"
+)
+
+// ValidateFieldSelector returns an InvalidRequestError if the field selector has a field
+// other than the supported ones of the kind
+func ValidateFieldSelector(kind string, selector map[string]string, supported ...string) error {
+	for field := range selector {
+		if !containsString(supported, field) {
+			msg := fmt.Sprintf("field selector %q is not supported for %s", field, kind)
+			if len(supported) != 0 {
+				msg += fmt.Sprintf(", supported fields: %s", strings.Join(supported, ", "))
+			}
+			return &InvalidRequestError{StatusError: StatusError{Message: msg}}
+		}
+	}
+	return nil
+}
+
+// SelectorLabels converts the label selector to the labels of the edge platform, which are plain strings.
+// An entry with an empty value selects the objects labeled with the key, otherwise the ones labeled with key=value.
+func SelectorLabels(selector map[string]string) []string {
+	var labels []string
+	for k, v := range selector {
+		if v == "" {
+			labels = append(labels, k)
+		} else {
+			labels = append(labels, k+"="+v)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Matches returns true if an object with the labels and the fields is selected by the options,
+// the object must have all the labels and all the fields of the selectors
+func (o ListOptions) Matches(labels []string, fields map[string]string) bool {
+	for _, l := range SelectorLabels(o.LabelSelector) {
+		if !containsString(labels, l) {
+			return false
+		}
+	}
+	for k, v := range o.FieldSelector {
+		if fields[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// DeviceFields returns the fields of the device that can be selected
+func DeviceFields(d *devicev1alpha1.Device) map[string]string {
+	return map[string]string{ServiceNameField: d.Spec.Service, ProfileNameField: d.Spec.Profile}
+}
+
+// DeviceProfileFields returns the fields of the deviceProfile that can be selected
+func DeviceProfileFields(dp *devicev1alpha1.DeviceProfile) map[string]string {
+	return map[string]string{ManufacturerField: dp.Spec.Manufacturer, ModelField: dp.Spec.Model}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clients
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// NewBinaryPropertyState returns the state of a property read as a binary payload. The device clients return the payload
+// encoded in base64 as the actual value, it is replaced by the hash of the payload once the payload is stored.
+func NewBinaryPropertyState(name, mediaType string, payload []byte) devicev1alpha1.ActualPropertyState {
+	return devicev1alpha1.ActualPropertyState{
+		Name:        name,
+		ActualValue: base64.StdEncoding.EncodeToString(payload),
+		Binary: &devicev1alpha1.BinaryValue{
+			MediaType: mediaType,
+			Size:      int64(len(payload)),
+			Hash:      PayloadHash(payload),
+		},
+	}
+}
+
+// NewObjectPropertyState returns the state of a property read as an object, the object is kept as structured JSON
+// if it is a JSON object, and serialized as the actual value
+func NewObjectPropertyState(name string, object interface{}) devicev1alpha1.ActualPropertyState {
+	raw, _ := json.Marshal(object)
+	aps := devicev1alpha1.ActualPropertyState{Name: name, ActualValue: string(raw)}
+	if _, ok := object.(map[string]interface{}); ok {
+		aps.ObjectValue = &runtime.RawExtension{Raw: raw}
+	}
+	return aps
+}
+
+// PayloadHash returns the sha256 digest of a binary payload in the form of sha256:<hex>
+func PayloadHash(payload []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(payload))
+}
+
+// BinaryPayload returns the payload carried by the actual value of a binary property,
+// false if it is not a binary property or the payload has been replaced by its hash
+func BinaryPayload(aps *devicev1alpha1.ActualPropertyState) ([]byte, bool) {
+	if aps.Binary == nil || aps.ActualValue == aps.Binary.Hash {
+		return nil, false
+	}
+	payload, err := base64.StdEncoding.DecodeString(aps.ActualValue)
+	if err != nil {
+		return nil, false
+	}
+	return payload, true
+}
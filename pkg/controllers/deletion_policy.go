@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+// effectiveDeletionPolicy returns the deletion policy of the object, or the default policy of the controller if it is not set
+func effectiveDeletionPolicy(policy, defaultPolicy devicev1alpha1.DeletionPolicy) devicev1alpha1.DeletionPolicy {
+	if policy != "" {
+		return policy
+	}
+	if defaultPolicy != "" {
+		return defaultPolicy
+	}
+	return devicev1alpha1.DeletionPolicyDelete
+}
+
+// isOrphaned reports whether the labels of an object on the edge platform mark it as orphaned
+func isOrphaned(labels []string) bool {
+	for _, l := range labels {
+		if l == EdgeOrphanedLabel {
+			return true
+		}
+	}
+	return false
+}
+
+// withOrphanedLabel returns a copy of the labels with the orphaned label added
+func withOrphanedLabel(labels []string) []string {
+	return append(withoutOrphanedLabel(labels), EdgeOrphanedLabel)
+}
+
+// withoutOrphanedLabel returns a copy of the labels with the orphaned label removed
+func withoutOrphanedLabel(labels []string) []string {
+	ret := make([]string, 0, len(labels)+1)
+	for _, l := range labels {
+		if l != EdgeOrphanedLabel {
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
@@ -20,17 +20,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
 	"github.com/openyurtio/device-controller/pkg/clients"
-	edgexCli "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
 	"github.com/openyurtio/device-controller/pkg/controllers/util"
+	"github.com/openyurtio/device-controller/pkg/metrics"
 
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/util/conditions"
@@ -42,16 +47,24 @@ import (
 type DeviceReconciler struct {
 	client.Client
 	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
 	deviceCli clients.DeviceInterface
 	// which nodePool deviceController is deployed in
 	NodePool string
+	// the size limit of the binary payloads stored in the ConfigMaps of the devices
+	payloadMaxSize int64
+	// the deletion policy of the devices which do not set their own
+	defaultDeletionPolicy devicev1alpha1.DeletionPolicy
+	// the source of the actual property values of the devices which do not set their own
+	propertySource devicev1alpha1.PropertySource
 }
 
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=devices,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=devices/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=devices/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
-func (r *DeviceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *DeviceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	var d devicev1alpha1.Device
 	if err := r.Get(ctx, req.NamespacedName, &d); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -65,16 +78,17 @@ func (r *DeviceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	klog.V(3).Infof("Reconciling the Device: %s", d.GetName())
 	// Update the conditions for device
 	defer func() {
+		result, err = handleEdgeError(&d, result, err)
 		if d.Spec.Managed != true {
 			conditions.MarkFalse(&d, devicev1alpha1.DeviceManagingCondition, "this device is not managed by openyurt", clusterv1.ConditionSeverityInfo, "")
 		}
 		conditions.SetSummary(&d,
-			conditions.WithConditions(devicev1alpha1.DeviceSyncedCondition, devicev1alpha1.DeviceManagingCondition),
+			conditions.WithConditions(devicev1alpha1.DeviceSyncedCondition, devicev1alpha1.DeviceManagingCondition, devicev1alpha1.EdgeAvailableCondition),
 		)
-		err := r.Status().Update(ctx, &d)
-		if client.IgnoreNotFound(err) != nil {
-			if !apierrors.IsConflict(err) {
-				klog.V(4).ErrorS(err, "update device conditions failed", "DeviceName", d.GetName())
+		updateErr := r.Status().Update(ctx, &d)
+		if client.IgnoreNotFound(updateErr) != nil {
+			if !apierrors.IsConflict(updateErr) {
+				klog.V(4).ErrorS(updateErr, "update device conditions failed", "DeviceName", d.GetName())
 			}
 		}
 	}()
@@ -109,9 +123,15 @@ func (r *DeviceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 }
 
 // SetupWithManager sets up the controller with the Manager.
-func (r *DeviceReconciler) SetupWithManager(mgr ctrl.Manager, opts *options.YurtDeviceControllerOptions) error {
-	r.deviceCli = edgexCli.NewEdgexDeviceClient(opts.CoreMetadataAddr, opts.CoreCommandAddr)
+func (r *DeviceReconciler) SetupWithManager(mgr ctrl.Manager, driver clients.Driver, opts *options.YurtDeviceControllerOptions) error {
+	var err error
+	if r.deviceCli, err = driver.NewDeviceClient(); err != nil {
+		return err
+	}
 	r.NodePool = opts.Nodepool
+	r.payloadMaxSize = int64(opts.PayloadMaxSize)
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicy(opts.DeletionPolicy)
+	r.propertySource = devicev1alpha1.PropertySource(opts.PropertySource)
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&devicev1alpha1.Device{}).
@@ -133,54 +153,95 @@ func (r *DeviceReconciler) reconcileDeleteDevice(ctx context.Context, d *devicev
 				return err
 			}
 		}
+	} else if effectiveDeletionPolicy(d.Spec.DeletionPolicy, r.defaultDeletionPolicy) == devicev1alpha1.DeletionPolicyOrphan {
+		// leave the device on the edge platform and label it, so that the syncer does not import it again
+		err := r.orphanEdgeDevice(ctx, edgeDeviceName)
+		if err != nil && !clients.IsNotFoundErr(err) {
+			r.Recorder.Eventf(d, corev1.EventTypeWarning, EventReasonOrphanOnEdgeFailed, "Failed to label device %s as orphaned on the edge platform: %v", edgeDeviceName, err)
+			return err
+		}
+		r.Recorder.Eventf(d, corev1.EventTypeNormal, EventReasonOrphanedOnEdge, "Left device %s on the edge platform as orphaned", edgeDeviceName)
+		return r.removeDeviceFinalizers(ctx, d)
 	} else {
 		// delete the device object on the edge platform
-		err := r.deviceCli.Delete(nil, edgeDeviceName, clients.DeleteOptions{})
+		err := r.deviceCli.Delete(ctx, edgeDeviceName, clients.DeleteOptions{})
 		if err != nil && !clients.IsNotFoundErr(err) {
+			r.Recorder.Eventf(d, corev1.EventTypeWarning, EventReasonDeleteOnEdgeFailed, "Failed to delete device %s from the edge platform: %v", edgeDeviceName, err)
 			return err
 		}
+		r.Recorder.Eventf(d, corev1.EventTypeNormal, EventReasonDeletedOnEdge, "Deleted device %s from the edge platform", edgeDeviceName)
+		return r.removeDeviceFinalizers(ctx, d)
+	}
+	return nil
+}
 
-		// delete the device in OpenYurt
-		patchData, _ := json.Marshal(map[string]interface{}{
-			"metadata": map[string]interface{}{
-				"finalizers": []string{},
-			},
-		})
-		if err = r.Patch(ctx, d, client.RawPatch(types.MergePatchType, patchData)); err != nil {
-			return err
-		}
+// removeDeviceFinalizers removes the finalizers of the device, so that it is deleted in OpenYurt
+func (r *DeviceReconciler) removeDeviceFinalizers(ctx context.Context, d *devicev1alpha1.Device) error {
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers": []string{},
+		},
+	})
+	if err := r.Patch(ctx, d, client.RawPatch(types.MergePatchType, patchData)); err != nil {
+		return err
 	}
+	metrics.DeleteDevice(d.Namespace, d.Name)
 	return nil
 }
 
+// orphanEdgeDevice adds the orphaned label to the device on the edge platform
+func (r *DeviceReconciler) orphanEdgeDevice(ctx context.Context, edgeDeviceName string) error {
+	edgeDevice, err := r.deviceCli.Get(ctx, edgeDeviceName, clients.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if isOrphaned(edgeDevice.Spec.Labels) {
+		return nil
+	}
+	// only the labels are sent, so that the other fields of the edge device are kept as they are
+	edgeDevice.Spec.Labels = withOrphanedLabel(edgeDevice.Spec.Labels)
+	_, err = r.deviceCli.Update(ctx, edgeDevice, clients.UpdateOptions{Fields: []string{"labels"}})
+	return err
+}
+
 func (r *DeviceReconciler) reconcileCreateDevice(ctx context.Context, d *devicev1alpha1.Device) error {
 	// get the actual name of the device on the Edge platform from the Label of the device
 	edgeDeviceName := util.GetEdgeDeviceName(d, EdgeXObjectName)
 	newDeviceStatus := d.Status.DeepCopy()
 	klog.V(4).Infof("Checking if device already exist on the edge platform: %s", d.GetName())
 	// Checking if device already exist on the edge platform
-	edgeDevice, err := r.deviceCli.Get(nil, edgeDeviceName, clients.GetOptions{})
+	edgeDevice, err := r.deviceCli.Get(ctx, edgeDeviceName, clients.GetOptions{})
 	if err == nil {
 		// a. If object exists, the status of the device on OpenYurt is updated
 		klog.V(4).Infof("Device already exists on edge platform: %s", d.GetName())
+		if isOrphaned(edgeDevice.Spec.Labels) {
+			// the device left on the edge platform by a deleted device is adopted, so it is no longer orphaned
+			edgeDevice.Spec.Labels = withoutOrphanedLabel(edgeDevice.Spec.Labels)
+			if _, err := r.deviceCli.Update(ctx, edgeDevice, clients.UpdateOptions{Fields: []string{"labels"}}); err != nil {
+				conditions.MarkFalse(d, devicev1alpha1.DeviceSyncedCondition, edgeErrorReason("failed to adopt the orphaned device on edge platform", err), clusterv1.ConditionSeverityWarning, err.Error())
+				return fmt.Errorf("fail to adopt the orphaned Device on edge platform: %w", err)
+			}
+		}
 		newDeviceStatus.EdgeId = edgeDevice.Status.EdgeId
 		newDeviceStatus.Synced = true
 	} else if clients.IsNotFoundErr(err) {
 		// b. If the object does not exist, a request is sent to the edge platform to create a new device
 		klog.V(4).Infof("Adding device to the edge platform: %s", d.GetName())
-		createdEdgeObj, err := r.deviceCli.Create(nil, d, clients.CreateOptions{})
+		createdEdgeObj, err := r.deviceCli.Create(ctx, d, clients.CreateOptions{})
 		if err != nil {
-			conditions.MarkFalse(d, devicev1alpha1.DeviceSyncedCondition, "failed to create device on edge platform", clusterv1.ConditionSeverityWarning, err.Error())
-			return fmt.Errorf("fail to add Device to edge platform: %v", err)
+			conditions.MarkFalse(d, devicev1alpha1.DeviceSyncedCondition, edgeErrorReason("failed to create device on edge platform", err), clusterv1.ConditionSeverityWarning, err.Error())
+			r.Recorder.Eventf(d, corev1.EventTypeWarning, EventReasonCreateOnEdgeFailed, "Failed to add device %s to the edge platform: %v", edgeDeviceName, err)
+			return fmt.Errorf("fail to add Device to edge platform: %w", err)
 		} else {
 			klog.V(4).Infof("Successfully add Device to edge platform, Name: %s, EdgeId: %s", edgeDeviceName, createdEdgeObj.Status.EdgeId)
+			r.Recorder.Eventf(d, corev1.EventTypeNormal, EventReasonCreatedOnEdge, "Added device %s to the edge platform, EdgeId: %s", edgeDeviceName, createdEdgeObj.Status.EdgeId)
 			newDeviceStatus.EdgeId = createdEdgeObj.Status.EdgeId
 			newDeviceStatus.Synced = true
 		}
 	} else {
 		klog.V(4).ErrorS(err, "failed to visit the edge platform")
-		conditions.MarkFalse(d, devicev1alpha1.DeviceSyncedCondition, "failed to visit the EdgeX core-metadata-service", clusterv1.ConditionSeverityWarning, "")
-		return nil
+		conditions.MarkFalse(d, devicev1alpha1.DeviceSyncedCondition, edgeErrorReason("failed to visit the EdgeX core-metadata-service", err), clusterv1.ConditionSeverityWarning, err.Error())
+		return err
 	}
 	d.Status = *newDeviceStatus
 	conditions.MarkTrue(d, devicev1alpha1.DeviceSyncedCondition)
@@ -193,31 +254,48 @@ func (r *DeviceReconciler) reconcileUpdateDevice(ctx context.Context, d *devicev
 	// This list is used to hold the names of properties that failed to reconcile
 	var failedPropertyNames []string
 
-	// 1. reconciling the AdminState and OperatingState field of device
-	klog.V(3).Infof("DeviceName: %s, reconciling the AdminState and OperatingState field of device", d.GetName())
-	updateDevice := d.DeepCopy()
-	if d.Spec.AdminState != "" && d.Spec.AdminState != d.Status.AdminState {
-		newDeviceStatus.AdminState = d.Spec.AdminState
-	} else {
-		updateDevice.Spec.AdminState = ""
-	}
-
-	if d.Spec.OperatingState != "" && d.Spec.OperatingState != d.Status.OperatingState {
-		newDeviceStatus.OperatingState = d.Spec.OperatingState
-	} else {
-		updateDevice.Spec.OperatingState = ""
-	}
-	_, err := r.deviceCli.Update(nil, updateDevice, clients.UpdateOptions{})
+	// 1. reconciling the fields of device which are stored on the edge platform
+	klog.V(3).Infof("DeviceName: %s, reconciling the fields of device", d.GetName())
+	edgeDeviceName := util.GetEdgeDeviceName(d, EdgeXObjectName)
+	edgeDevice, err := r.deviceCli.Get(ctx, edgeDeviceName, clients.GetOptions{})
 	if err != nil {
-		conditions.MarkFalse(d, devicev1alpha1.DeviceManagingCondition, "failed to update AdminState or OperatingState of device on edge platform", clusterv1.ConditionSeverityWarning, err.Error())
+		if clients.IsNotFoundErr(err) {
+			// the syncer will clean up the device which has been deleted on the edge platform
+			conditions.MarkFalse(d, devicev1alpha1.DeviceManagingCondition, "device is not found on edge platform", clusterv1.ConditionSeverityWarning, err.Error())
+			return nil
+		}
+		conditions.MarkFalse(d, devicev1alpha1.DeviceManagingCondition, edgeErrorReason("failed to visit the EdgeX core-metadata-service", err), clusterv1.ConditionSeverityWarning, err.Error())
 		return err
 	}
+	newDeviceStatus.AdminState = edgeDevice.Status.AdminState
+	newDeviceStatus.OperatingState = edgeDevice.Status.OperatingState
+	if changedFields := findDeviceDiff(d, edgeDevice); len(changedFields) != 0 {
+		klog.V(4).Infof("DeviceName: %s, fields %v have changed, updating the device on edge platform", d.GetName(), changedFields)
+		// only the changed fields are sent, so that the values only set on the edge platform are kept
+		if _, err := r.deviceCli.Update(ctx, d, clients.UpdateOptions{Fields: changedFields}); err != nil {
+			// e.g. EdgeX refuses to move the device to a deviceService or deviceProfile that does not exist
+			conditions.MarkFalse(d, devicev1alpha1.DeviceManagingCondition,
+				edgeErrorReason(fmt.Sprintf("EdgeX cannot apply the changes of fields %v in place", changedFields), err), clusterv1.ConditionSeverityWarning, err.Error())
+			r.Recorder.Eventf(d, corev1.EventTypeWarning, EventReasonUpdateOnEdgeFailed, "Failed to update fields %v of device %s on the edge platform: %v", changedFields, edgeDeviceName, err)
+			return err
+		}
+		r.Recorder.Eventf(d, corev1.EventTypeNormal, EventReasonUpdatedOnEdge, "Updated fields %v of device %s on the edge platform", changedFields, edgeDeviceName)
+		if d.Spec.AdminState != "" {
+			newDeviceStatus.AdminState = d.Spec.AdminState
+		}
+		if d.Spec.OperatingState != "" {
+			newDeviceStatus.OperatingState = d.Spec.OperatingState
+		}
+	}
+
+	recordAdminStateChange(r.Recorder, d, d.Status.AdminState, newDeviceStatus.AdminState)
 
 	// 2. reconciling the device properties' value
 	klog.V(3).Infof("DeviceName: %s, reconciling the device properties", d.GetName())
 	// property updates are made only when the device is up and unlocked
 	if newDeviceStatus.OperatingState == devicev1alpha1.Up && newDeviceStatus.AdminState == devicev1alpha1.UnLocked {
-		newDeviceStatus, failedPropertyNames = r.reconcileDeviceProperties(d, newDeviceStatus)
+		newDeviceStatus, failedPropertyNames = r.reconcileDeviceProperties(ctx, d, newDeviceStatus)
+		metrics.AddPropertyFailures(d.Namespace, d.Name, len(failedPropertyNames))
 	}
 
 	d.Status = *newDeviceStatus
@@ -238,27 +316,52 @@ func (r *DeviceReconciler) reconcileUpdateDevice(ctx context.Context, d *devicev
 
 // Update the actual property value of the device on edge platform,
 // return the latest status and the names of the property that failed to update
-func (r *DeviceReconciler) reconcileDeviceProperties(d *devicev1alpha1.Device, deviceStatus *devicev1alpha1.DeviceStatus) (*devicev1alpha1.DeviceStatus, []string) {
+func (r *DeviceReconciler) reconcileDeviceProperties(ctx context.Context, d *devicev1alpha1.Device, deviceStatus *devicev1alpha1.DeviceStatus) (*devicev1alpha1.DeviceStatus, []string) {
 	newDeviceStatus := deviceStatus.DeepCopy()
 	// This list is used to hold the names of properties that failed to reconcile
 	var failedPropertyNames []string
 	// 2. reconciling the device properties' value
 	klog.V(3).Infof("DeviceName: %s, reconciling the value of device properties", d.GetName())
+	// the values are compared by the value types of the deviceResources, or as they are without the deviceProfile
+	dp, err := findDeviceProfile(ctx, r.Client, d)
+	if err != nil {
+		klog.V(3).ErrorS(err, "failed to get the deviceProfile of device", "DeviceName", d.GetName())
+	}
+	// with the CoreData source the actual values are the latest readings reported by the device, it is not read actively
+	fromCoreData := effectivePropertySource(d.Spec.PropertySource, r.propertySource) == devicev1alpha1.CoreDataSource
+	var latest map[string]devicev1alpha1.ActualPropertyState
+	var latestErr error
+	if fromCoreData {
+		latest, latestErr = r.deviceCli.ListLatestPropertiesState(ctx, d, clients.ListOptions{})
+	}
 	for _, desiredProperty := range d.Spec.DeviceProperties {
-		if desiredProperty.DesiredValue == "" {
+		if desiredProperty.DesiredValue == "" && len(desiredProperty.DesiredParameters) == 0 {
 			continue
 		}
 		propertyName := desiredProperty.Name
+		dr := util.PropertyResource(dp, propertyName)
+		desiredValue := formatDesiredValue(desiredProperty)
 		// 1.1. gets the actual property value of the current device from edge platform
 		klog.V(4).Infof("DeviceName: %s, getting the actual value of property: %s", d.GetName(), propertyName)
-		actualProperty, err := r.deviceCli.GetPropertyState(nil, propertyName, d, clients.GetOptions{})
+		var actualProperty *devicev1alpha1.ActualPropertyState
+		if !fromCoreData {
+			actualProperty, err = r.deviceCli.GetPropertyState(ctx, propertyName, d, clients.GetOptions{})
+		} else if err = latestErr; err == nil {
+			actualProperty, err = latestPropertyState(d, propertyName, latest)
+		}
 		if err != nil {
 			if !clients.IsNotFoundErr(err) {
 				klog.Errorf("DeviceName: %s, failed to get actual property value of %s, err:%v", d.GetName(), propertyName, err)
+				r.Recorder.Eventf(d, corev1.EventTypeWarning, EventReasonPropertySetFailed, "Failed to read property %s: %v", propertyName, err)
 				failedPropertyNames = append(failedPropertyNames, propertyName)
 				continue
 			}
-			klog.Errorf("DeviceName: %s, property read command not found", d.GetName())
+			if fromCoreData {
+				klog.V(4).Infof("DeviceName: %s, no reading of property %s reported yet", d.GetName(), propertyName)
+			} else {
+				klog.Errorf("DeviceName: %s, property read command not found, err:%v", d.GetName(), err)
+			}
+			actualProperty = &devicev1alpha1.ActualPropertyState{Name: propertyName}
 		}
 		klog.V(4).Infof("DeviceName: %s, got the actual property state, {Name: %s, GetURL: %s, ActualValue: %s}",
 			d.GetName(), propertyName, actualProperty.GetURL, actualProperty.ActualValue)
@@ -266,26 +369,133 @@ func (r *DeviceReconciler) reconcileDeviceProperties(d *devicev1alpha1.Device, d
 		if newDeviceStatus.DeviceProperties == nil {
 			newDeviceStatus.DeviceProperties = map[string]devicev1alpha1.ActualPropertyState{}
 		}
+		if dr != nil {
+			actualProperty.Units = dr.Properties.Units
+		}
 		newDeviceStatus.DeviceProperties[propertyName] = *actualProperty
 
 		// 1.2. set the device attribute in the edge platform to the expected value
-		if actualProperty == nil || desiredProperty.DesiredValue != actualProperty.ActualValue {
+		if !desiredPropertyReached(dp, dr, desiredProperty, actualProperty) {
 			klog.V(4).Infof("DeviceName: %s, the desired value and the actual value are different, desired: %s, actual: %s",
-				d.GetName(), desiredProperty.DesiredValue, actualProperty.ActualValue)
-			if err := r.deviceCli.UpdatePropertyState(nil, propertyName, d, clients.UpdateOptions{}); err != nil {
+				d.GetName(), desiredValue, actualProperty.ActualValue)
+			if err := r.deviceCli.UpdatePropertyState(ctx, propertyName, d, clients.UpdateOptions{}); err != nil {
 				klog.ErrorS(err, "failed to update property", "DeviceName", d.GetName(), "propertyName", propertyName)
+				r.Recorder.Eventf(d, corev1.EventTypeWarning, EventReasonPropertySetFailed, "Failed to set property %s to %s: %v", propertyName, desiredValue, err)
 				failedPropertyNames = append(failedPropertyNames, propertyName)
 				continue
 			}
 
 			klog.V(4).Infof("DeviceName: %s, successfully set the property %s to desired value", d.GetName(), propertyName)
+			r.Recorder.Eventf(d, corev1.EventTypeNormal, EventReasonPropertySet, "Set property %s to %s", propertyName, desiredValue)
 			newActualProperty := devicev1alpha1.ActualPropertyState{
 				Name:        propertyName,
 				GetURL:      actualProperty.GetURL,
 				ActualValue: desiredProperty.DesiredValue,
+				Units:       actualProperty.Units,
+			}
+			if len(desiredProperty.DesiredParameters) != 0 {
+				newActualProperty.ActualParameters = desiredProperty.DeepCopy().DesiredParameters
 			}
 			newDeviceStatus.DeviceProperties[propertyName] = newActualProperty
 		}
 	}
+	if err := storeBinaryPayloads(ctx, r.Client, d, newDeviceStatus.DeviceProperties, r.payloadMaxSize); err != nil {
+		klog.V(3).ErrorS(err, "failed to store the binary payloads of device", "DeviceName", d.GetName())
+	}
 	return newDeviceStatus, failedPropertyNames
 }
+
+// latestPropertyState returns the state of the property among the latest readings of the device,
+// NotFoundError is returned if the device has not reported it yet
+func latestPropertyState(d *devicev1alpha1.Device, propertyName string, latest map[string]devicev1alpha1.ActualPropertyState) (*devicev1alpha1.ActualPropertyState, error) {
+	aps, ok := latest[propertyName]
+	if !ok {
+		return nil, &clients.NotFoundError{StatusError: clients.StatusError{
+			Message: fmt.Sprintf("no reading of property %s reported by device %s yet", propertyName, d.GetName())}}
+	}
+	// readings don't carry the read command of a property, keep the one we already know
+	aps.GetURL = d.Status.DeviceProperties[propertyName].GetURL
+	return &aps, nil
+}
+
+// effectivePropertySource returns the property source of the device, or the default source of the controller if it is not set
+func effectivePropertySource(source, defaultSource devicev1alpha1.PropertySource) devicev1alpha1.PropertySource {
+	if source != "" {
+		return source
+	}
+	if defaultSource != "" {
+		return defaultSource
+	}
+	return devicev1alpha1.CoreCommandSource
+}
+
+// findDeviceProfile returns the deviceProfile the device refers to, nil if it doesn't exist
+func findDeviceProfile(ctx context.Context, c client.Reader, d *devicev1alpha1.Device) (*devicev1alpha1.DeviceProfile, error) {
+	return util.FindDeviceProfile(ctx, c, d.Namespace, d.Spec.NodePool, d.Spec.Profile, EdgeXObjectName)
+}
+
+// desiredPropertyReached returns whether the actual state of the property equals the desired one,
+// by the values of all the parameters if the property has desired parameters
+func desiredPropertyReached(dp *devicev1alpha1.DeviceProfile, dr *devicev1alpha1.DeviceResource, desired devicev1alpha1.DesiredPropertyState, actual *devicev1alpha1.ActualPropertyState) bool {
+	if len(desired.DesiredParameters) != 0 {
+		return util.ParametersEqual(dp, desired.DesiredParameters, actual.ActualParameters)
+	}
+	return util.PropertyValuesEqual(dr, desired.DesiredValue, actual.ActualValue)
+}
+
+// formatDesiredValue returns the desired value of the property for the logs and events,
+// the desired parameters are listed as name=value sorted by name
+func formatDesiredValue(desired devicev1alpha1.DesiredPropertyState) string {
+	if len(desired.DesiredParameters) == 0 {
+		return desired.DesiredValue
+	}
+	parameters := make([]string, 0, len(desired.DesiredParameters))
+	for name, value := range desired.DesiredParameters {
+		parameters = append(parameters, name+"="+value)
+	}
+	sort.Strings(parameters)
+	return strings.Join(parameters, ", ")
+}
+
+// setPropertyUnits sets the units of the actual property values to those of their deviceResources
+func setPropertyUnits(aps map[string]devicev1alpha1.ActualPropertyState, dp *devicev1alpha1.DeviceProfile) {
+	for name, ap := range aps {
+		if dr := util.PropertyResource(dp, name); dr != nil && ap.Units != dr.Properties.Units {
+			ap.Units = dr.Properties.Units
+			aps[name] = ap
+		}
+	}
+}
+
+// findDeviceDiff returns the fields of the spec that differ between the device on OpenYurt and on the edge platform
+func findDeviceDiff(kubeDevice, edgeDevice *devicev1alpha1.Device) []string {
+	var fields []string
+	if kubeDevice.Spec.Description != "" && kubeDevice.Spec.Description != edgeDevice.Spec.Description {
+		fields = append(fields, "description")
+	}
+	if kubeDevice.Spec.AdminState != "" && kubeDevice.Spec.AdminState != edgeDevice.Spec.AdminState {
+		fields = append(fields, "adminState")
+	}
+	if kubeDevice.Spec.OperatingState != "" && kubeDevice.Spec.OperatingState != edgeDevice.Spec.OperatingState {
+		fields = append(fields, "operatingState")
+	}
+	if !apiequality.Semantic.DeepEqual(kubeDevice.Spec.Labels, edgeDevice.Spec.Labels) {
+		fields = append(fields, "labels")
+	}
+	if len(kubeDevice.Spec.Protocols) != 0 && !apiequality.Semantic.DeepEqual(kubeDevice.Spec.Protocols, edgeDevice.Spec.Protocols) {
+		fields = append(fields, "protocols")
+	}
+	if kubeDevice.Spec.Location != "" && kubeDevice.Spec.Location != edgeDevice.Spec.Location {
+		fields = append(fields, "location")
+	}
+	if !apiequality.Semantic.DeepEqual(kubeDevice.Spec.AutoEvents, edgeDevice.Spec.AutoEvents) {
+		fields = append(fields, "autoEvents")
+	}
+	if kubeDevice.Spec.Service != "" && kubeDevice.Spec.Service != edgeDevice.Spec.Service {
+		fields = append(fields, "serviceName")
+	}
+	if kubeDevice.Spec.Profile != "" && kubeDevice.Spec.Profile != edgeDevice.Spec.Profile {
+		fields = append(fields, "profileName")
+	}
+	return fields
+}
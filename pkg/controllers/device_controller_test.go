@@ -0,0 +1,452 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+	"github.com/openyurtio/device-controller/pkg/clients/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNodePool = "hangzhou"
+
+func newTestDevice(name string) *devicev1alpha1.Device {
+	return &devicev1alpha1.Device{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  "default",
+			Finalizers: []string{devicev1alpha1.DeviceFinalizer},
+		},
+		Spec: devicev1alpha1.DeviceSpec{
+			NodePool:       testNodePool,
+			Managed:        true,
+			AdminState:     devicev1alpha1.UnLocked,
+			OperatingState: devicev1alpha1.Up,
+			Service:        "device-virtual",
+			Profile:        "Random-Integer-Device",
+		},
+	}
+}
+
+// newTestClient returns a fake kubernetes client holding the objects
+func newTestClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+	if err := devicev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+	return fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func newTestDeviceReconciler(t *testing.T, store *fake.Store, objs ...client.Object) *DeviceReconciler {
+	c := newTestClient(t, objs...)
+	return &DeviceReconciler{
+		Client:    c,
+		Scheme:    c.Scheme(),
+		Recorder:  record.NewFakeRecorder(10),
+		deviceCli: fake.NewFakeDeviceClient(store),
+		NodePool:  testNodePool,
+	}
+}
+
+// expectEvent checks that an event of the type and reason has been recorded by the recorder
+func expectEvent(t *testing.T, recorder record.EventRecorder, eventType, reason string) {
+	events := recorder.(*record.FakeRecorder).Events
+	for {
+		select {
+		case e := <-events:
+			if strings.HasPrefix(e, eventType+" "+reason+" ") {
+				return
+			}
+		default:
+			t.Errorf("expected a %s event with reason %s", eventType, reason)
+			return
+		}
+	}
+}
+
+func reconcileTestDevice(t *testing.T, r *DeviceReconciler, name string) *devicev1alpha1.Device {
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile device %s: %v", name, err)
+	}
+	var d devicev1alpha1.Device
+	if err := r.Get(context.TODO(), key, &d); err != nil {
+		t.Fatalf("failed to get device %s: %v", name, err)
+	}
+	return &d
+}
+
+func TestDeviceReconcilerCreatesEdgeDevice(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceReconciler(t, store, newTestDevice("random-device"))
+
+	d := reconcileTestDevice(t, r, "random-device")
+	if !d.Status.Synced || d.Status.EdgeId == "" {
+		t.Errorf("expected the device to be synced with an edge id, got status %+v", d.Status)
+	}
+	if !conditions.IsTrue(d, devicev1alpha1.DeviceSyncedCondition) {
+		t.Errorf("expected condition %s to be true", devicev1alpha1.DeviceSyncedCondition)
+	}
+	ed, err := fake.NewFakeDeviceClient(store).Get(context.TODO(), "random-device", clients.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the device to be created on the edge platform: %v", err)
+	}
+	if ed.Status.EdgeId != d.Status.EdgeId {
+		t.Errorf("expected edge id %s, got %s", ed.Status.EdgeId, d.Status.EdgeId)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonCreatedOnEdge)
+}
+
+func TestDeviceReconcilerReportsRejectedDevice(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceReconciler(t, store, newTestDevice("random-device"))
+	key := types.NamespacedName{Namespace: "default", Name: "random-device"}
+
+	store.InjectError(fake.CreateVerb, fake.DeviceKind, "random-device",
+		clients.NewStatusError(http.StatusMultiStatus, http.StatusBadRequest, "device profile is not set"))
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); !clients.IsInvalidRequestErr(err) {
+		t.Errorf("expected an invalid request error, got %v", err)
+	}
+	var d devicev1alpha1.Device
+	if err := r.Get(context.TODO(), key, &d); err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+	if reason := conditions.GetReason(&d, devicev1alpha1.DeviceSyncedCondition); reason != "failed to create device on edge platform (invalid request)" {
+		t.Errorf("unexpected reason of condition %s: %q", devicev1alpha1.DeviceSyncedCondition, reason)
+	}
+	// the edge platform is available even though it rejected the device
+	if !conditions.IsTrue(&d, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected condition %s to be true", devicev1alpha1.EdgeAvailableCondition)
+	}
+}
+
+func TestDeviceReconcilerRequeuesWhenEdgeUnavailable(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceReconciler(t, store, newTestDevice("random-device"))
+	key := types.NamespacedName{Namespace: "default", Name: "random-device"}
+
+	// refused by an open circuit breaker, requeued once the breaker lets requests through again
+	store.InjectError(fake.GetVerb, fake.DeviceKind, "", &clients.CircuitOpenError{Endpoint: "edgex-core-metadata:59881", RetryAfter: 10 * time.Second})
+	result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+	if err != nil || result.RequeueAfter != 10*time.Second {
+		t.Errorf("expected the device to be requeued after 10s, got %+v, %v", result, err)
+	}
+	var d devicev1alpha1.Device
+	if err := r.Get(context.TODO(), key, &d); err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+	if !conditions.IsFalse(&d, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected condition %s to be false", devicev1alpha1.EdgeAvailableCondition)
+	}
+
+	// a transient failure is returned so that the device is requeued with backoff
+	store.ClearErrors()
+	store.InjectError(fake.GetVerb, fake.DeviceKind, "", &clients.RetryableError{Err: fake.ErrTimeout})
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); !clients.IsRetryableErr(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+
+	store.ClearErrors()
+	synced := reconcileTestDevice(t, r, "random-device")
+	if !synced.Status.Synced || !conditions.IsTrue(synced, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected the device to be synced once the edge platform is available, got status %+v", synced.Status)
+	}
+}
+
+func TestFindDeviceDiffIgnoresUnsetFields(t *testing.T) {
+	kd := newTestDevice("random-device")
+	ed := kd.DeepCopy()
+	ed.Spec.Description = "set on the edge platform"
+	ed.Spec.Location = "hangzhou"
+	if fields := findDeviceDiff(kd, ed); len(fields) != 0 {
+		t.Errorf("expected the fields unset on OpenYurt to be left as they are, got %v", fields)
+	}
+	kd.Spec.Description = "set on OpenYurt"
+	kd.Spec.Location = "beijing"
+	if fields := findDeviceDiff(kd, ed); !reflect.DeepEqual(fields, []string{"description", "location"}) {
+		t.Errorf("expected description and location to differ, got %v", fields)
+	}
+}
+
+func TestHandleEdgeErrorKeepsConditionOnOtherErrors(t *testing.T) {
+	d := newTestDevice("random-device")
+	handleEdgeError(d, ctrl.Result{}, clients.NewStatusError(http.StatusServiceUnavailable, 0, "overloaded"))
+	if !conditions.IsFalse(d, devicev1alpha1.EdgeAvailableCondition) {
+		t.Fatalf("expected condition %s to be false", devicev1alpha1.EdgeAvailableCondition)
+	}
+	// an error of OpenYurt tells nothing about the edge platform
+	conflict := apierrors.NewConflict(devicev1alpha1.GroupVersion.WithResource("devices").GroupResource(), d.Name, errors.New("modified"))
+	if _, err := handleEdgeError(d, ctrl.Result{}, conflict); err != conflict {
+		t.Errorf("expected the error to be returned as it is, got %v", err)
+	}
+	if !conditions.IsFalse(d, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected condition %s to be left false", devicev1alpha1.EdgeAvailableCondition)
+	}
+	handleEdgeError(d, ctrl.Result{}, clients.NewStatusError(http.StatusNotFound, 0, "device not found"))
+	if !conditions.IsTrue(d, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected condition %s to be true once the edge platform responds", devicev1alpha1.EdgeAvailableCondition)
+	}
+}
+
+func TestDeviceReconcilerSetsDesiredProperty(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("random-device")
+	device.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Int8": {Name: "Int8", DesiredValue: "42"},
+	}
+	device.Status = *store.AddDevice(device).Status.DeepCopy()
+	store.SetProperty("random-device", "Int8", "1")
+	r := newTestDeviceReconciler(t, store, device)
+
+	d := reconcileTestDevice(t, r, "random-device")
+	if v, _ := store.GetProperty("random-device", "Int8"); v != "42" {
+		t.Errorf("expected property Int8 to be set to 42 on the edge platform, got %q", v)
+	}
+	if v := d.Status.DeviceProperties["Int8"].ActualValue; v != "42" {
+		t.Errorf("expected the actual value of Int8 to be 42, got %q", v)
+	}
+	if !conditions.IsTrue(d, devicev1alpha1.DeviceManagingCondition) {
+		t.Errorf("expected condition %s to be true", devicev1alpha1.DeviceManagingCondition)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonPropertySet)
+}
+
+func TestDeviceReconcilerReadsCoreData(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("random-device")
+	device.Spec.PropertySource = devicev1alpha1.CoreDataSource
+	device.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Int8": {Name: "Int8", DesiredValue: "42"},
+	}
+	device.Status = *store.AddDevice(device).Status.DeepCopy()
+	store.SetProperty("random-device", "Int8", "42")
+	// the device is not read actively, the latest reading already reaches the desired value
+	store.InjectError(fake.GetPropertyVerb, fake.DeviceKind, "random-device", errors.New("the device was read"))
+	r := newTestDeviceReconciler(t, store, device)
+
+	d := reconcileTestDevice(t, r, "random-device")
+	if v := d.Status.DeviceProperties["Int8"].ActualValue; v != "42" {
+		t.Errorf("expected the actual value of Int8 to be 42, got %q", v)
+	}
+	if !conditions.IsTrue(d, devicev1alpha1.DeviceManagingCondition) {
+		t.Errorf("expected condition %s to be true", devicev1alpha1.DeviceManagingCondition)
+	}
+	select {
+	case e := <-r.Recorder.(*record.FakeRecorder).Events:
+		t.Errorf("expected the property not to be set, got event %q", e)
+	default:
+	}
+}
+
+func TestDeviceReconcilerSetsDesiredParameters(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("color-light")
+	color := map[string]string{"R": "255", "G": "128", "B": "0"}
+	device.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Color": {Name: "Color", DesiredParameters: color},
+	}
+	device.Status = *store.AddDevice(device).Status.DeepCopy()
+	for name := range color {
+		store.SetProperty("color-light", name, "0")
+	}
+	r := newTestDeviceReconciler(t, store, device)
+
+	d := reconcileTestDevice(t, r, "color-light")
+	for name, value := range color {
+		if v, _ := store.GetProperty("color-light", name); v != value {
+			t.Errorf("expected parameter %s to be set to %s on the edge platform, got %q", name, value, v)
+		}
+	}
+	if ap := d.Status.DeviceProperties["Color"]; !reflect.DeepEqual(ap.ActualParameters, color) {
+		t.Errorf("expected the actual parameters %v, got %+v", color, ap)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonPropertySet)
+
+	// the parameters are not sent again once they are reached
+	reconcileTestDevice(t, r, "color-light")
+	select {
+	case e := <-r.Recorder.(*record.FakeRecorder).Events:
+		t.Errorf("expected the parameters not to be set again, got event %q", e)
+	default:
+	}
+}
+
+func TestDeviceReconcilerComparesTypedValues(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("random-device")
+	device.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Float32": {Name: "Float32", DesiredValue: "1"},
+		"Bool":    {Name: "Bool", DesiredValue: "true"},
+	}
+	device.Status = *store.AddDevice(device).Status.DeepCopy()
+	store.SetProperty("random-device", "Float32", "1.0")
+	store.SetProperty("random-device", "Bool", "TRUE")
+	profile := &devicev1alpha1.DeviceProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "random-integer-device",
+			Namespace: "default",
+			Labels:    map[string]string{EdgeXObjectName: "Random-Integer-Device"},
+		},
+		Spec: devicev1alpha1.DeviceProfileSpec{
+			NodePool: testNodePool,
+			DeviceResources: []devicev1alpha1.DeviceResource{
+				{Name: "Float32", Properties: devicev1alpha1.ResourceProperties{ValueType: "Float32", ReadWrite: "RW", Units: "C"}},
+				{Name: "Bool", Properties: devicev1alpha1.ResourceProperties{ValueType: "Bool", ReadWrite: "RW"}},
+			},
+		},
+	}
+	r := newTestDeviceReconciler(t, store, device, profile)
+
+	d := reconcileTestDevice(t, r, "random-device")
+	// the values equal to the desired ones by their value types are not written again
+	if v, _ := store.GetProperty("random-device", "Float32"); v != "1.0" {
+		t.Errorf("expected property Float32 to stay 1.0 on the edge platform, got %q", v)
+	}
+	if v, _ := store.GetProperty("random-device", "Bool"); v != "TRUE" {
+		t.Errorf("expected property Bool to stay TRUE on the edge platform, got %q", v)
+	}
+	if ap := d.Status.DeviceProperties["Float32"]; ap.ActualValue != "1.0" || ap.Units != "C" {
+		t.Errorf("expected the actual value of Float32 to be 1.0 C, got %+v", ap)
+	}
+	select {
+	case e := <-r.Recorder.(*record.FakeRecorder).Events:
+		t.Errorf("expected no property to be set, got event %q", e)
+	default:
+	}
+}
+
+func TestDeviceReconcilerReportsFailedProperty(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("random-device")
+	device.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"Int8": {Name: "Int8", DesiredValue: "42"},
+	}
+	device.Status = *store.AddDevice(device).Status.DeepCopy()
+	store.SetProperty("random-device", "Int8", "1")
+	store.InjectError(fake.SetPropertyVerb, fake.DeviceKind, "random-device", fake.ErrLocked)
+	r := newTestDeviceReconciler(t, store, device)
+
+	d := reconcileTestDevice(t, r, "random-device")
+	if v, _ := store.GetProperty("random-device", "Int8"); v != "1" {
+		t.Errorf("expected property Int8 to stay 1 on the edge platform, got %q", v)
+	}
+	if !conditions.IsFalse(d, devicev1alpha1.DeviceManagingCondition) {
+		t.Errorf("expected condition %s to be false", devicev1alpha1.DeviceManagingCondition)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeWarning, EventReasonPropertySetFailed)
+}
+
+func TestDeviceSyncerFindDiffDevice(t *testing.T) {
+	store := fake.NewStore()
+	store.SetProperty("Random-Float-Device", "Float32", "3.14")
+	synced := newTestDevice("random-float-device")
+	synced.Labels = map[string]string{EdgeXObjectName: "Random-Float-Device"}
+	store.AddDevice(synced)
+	store.AddDevice(&devicev1alpha1.Device{ObjectMeta: metav1.ObjectMeta{Name: "Random-Integer-Device"}})
+
+	ds := DeviceSyncer{Client: newTestClient(t), deviceCli: fake.NewFakeDeviceClient(store), NodePool: testNodePool, Namespace: "default"}
+	eDevs, err := ds.deviceCli.List(context.TODO(), clients.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the edge devices: %v", err)
+	}
+	edgeDevices := map[string]devicev1alpha1.Device{}
+	for _, ed := range eDevs {
+		edgeDevices[ed.Labels[EdgeXObjectName]] = ed
+	}
+	kubeDevices := map[string]devicev1alpha1.Device{"Random-Float-Device": *synced}
+
+	redundantEdge, redundantKube, syncedDevices := ds.findDiffDevice(edgeDevices, kubeDevices)
+	ds.refreshProperties(context.TODO(), syncedDevices)
+	if len(redundantKube) != 0 {
+		t.Errorf("expected no redundant kube device, got %v", redundantKube)
+	}
+	created, ok := redundantEdge["Random-Integer-Device"]
+	if !ok || created.Name != testNodePool+"-random-integer-device" || created.Spec.Managed {
+		t.Errorf("expected Random-Integer-Device to be imported as an unmanaged device, got %+v", created)
+	}
+	updated, ok := syncedDevices["Random-Float-Device"]
+	if !ok || updated.Status.DeviceProperties["Float32"].ActualValue != "3.14" {
+		t.Errorf("expected the actual properties of Random-Float-Device to be synced, got %+v", updated)
+	}
+}
+
+func TestDeviceReconcilerOrphansEdgeDevice(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceReconciler(t, store, newTestDevice("random-device"))
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicyOrphan
+	d := reconcileTestDevice(t, r, "random-device")
+
+	if err := r.Delete(context.TODO(), d); err != nil {
+		t.Fatalf("failed to delete the device: %v", err)
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "random-device"}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile the deleted device: %v", err)
+	}
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.Device{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the device to be deleted from OpenYurt, got %v", err)
+	}
+	deviceCli := fake.NewFakeDeviceClient(store)
+	ed, err := deviceCli.Get(context.TODO(), "random-device", clients.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the device to be left on the edge platform: %v", err)
+	}
+	if !isOrphaned(ed.Spec.Labels) {
+		t.Errorf("expected the edge device to be labelled as orphaned, got labels %v", ed.Spec.Labels)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonOrphanedOnEdge)
+
+	ds := DeviceSyncer{Client: newTestClient(t), deviceCli: deviceCli, NodePool: testNodePool, Namespace: "default"}
+	redundantEdge, _ := ds.diffEdgeDevices(map[string]devicev1alpha1.Device{"random-device": *ed}, map[string]devicev1alpha1.Device{})
+	if len(redundantEdge) != 0 {
+		t.Errorf("expected the orphaned device not to be imported, got %v", redundantEdge)
+	}
+
+	// a device created again adopts the orphaned device
+	if err := r.Create(context.TODO(), newTestDevice("random-device")); err != nil {
+		t.Fatalf("failed to create the device again: %v", err)
+	}
+	if d = reconcileTestDevice(t, r, "random-device"); !d.Status.Synced || d.Status.EdgeId != ed.Status.EdgeId {
+		t.Errorf("expected the device to be synced with edge id %s, got status %+v", ed.Status.EdgeId, d.Status)
+	}
+	if ed, err = deviceCli.Get(context.TODO(), "random-device", clients.GetOptions{}); err != nil || isOrphaned(ed.Spec.Labels) {
+		t.Errorf("expected the orphaned label to be removed from the adopted device, got %v, %v", ed, err)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch
+
+// payloadsMaxTotalSize is the size limit of the data of a ConfigMap, the payloads of a device are stored within it
+const payloadsMaxTotalSize = corev1.MaxSecretSize
+
+// payloadConfigMapName returns the name of the ConfigMap holding the binary payloads of the device
+func payloadConfigMapName(d *devicev1alpha1.Device) string {
+	return d.Name + "-payloads"
+}
+
+// storeBinaryPayloads moves the payloads of the binary properties into the ConfigMap of the device, keyed by the
+// property names, and replaces the actual values with the hashes of the payloads. A payload larger than maxSize is
+// not stored, nor is a payload whose hash is the same as the one in the status of the device. The payloads already
+// stored are kept first, then the new ones are stored in the order of the property names as long as the ConfigMap
+// stays within its size limit. The payloads of the properties no longer reported are removed from the ConfigMap,
+// which is owned by the device so that it is deleted with the device.
+func storeBinaryPayloads(ctx context.Context, c client.Client, d *devicev1alpha1.Device, aps map[string]devicev1alpha1.ActualPropertyState, maxSize int64) error {
+	names := make([]string, 0, len(aps))
+	for name := range aps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var totalSize int64
+	candidates := map[string][]byte{}
+	for _, name := range names {
+		ap := aps[name]
+		payload, ok := clients.BinaryPayload(&ap)
+		if !ok {
+			continue
+		}
+		ap.Binary = ap.Binary.DeepCopy()
+		ap.ActualValue = ap.Binary.Hash
+		old := d.Status.DeviceProperties[name].Binary
+		switch {
+		case old != nil && old.Hash == ap.Binary.Hash && old.PayloadRef != nil:
+			ap.Binary.PayloadRef = old.PayloadRef.DeepCopy()
+			totalSize += int64(len(payload))
+		case ap.Binary.Size > maxSize:
+			klog.V(4).InfoS("the binary payload is larger than the limit, it is not stored", "DeviceName", d.Name,
+				"propertyName", name, "size", ap.Binary.Size, "limit", maxSize)
+		case len(validation.IsConfigMapKey(name)) != 0:
+			klog.V(4).InfoS("the property name is not a valid ConfigMap key, the binary payload is not stored",
+				"DeviceName", d.Name, "propertyName", name)
+		default:
+			candidates[name] = payload
+		}
+		aps[name] = ap
+	}
+
+	payloads := map[string][]byte{}
+	for _, name := range names {
+		payload, ok := candidates[name]
+		if !ok {
+			continue
+		}
+		if totalSize+int64(len(payload)) > payloadsMaxTotalSize {
+			klog.V(4).InfoS("the ConfigMap of the binary payloads is full, the binary payload is not stored",
+				"DeviceName", d.Name, "propertyName", name, "size", len(payload), "limit", payloadsMaxTotalSize)
+			continue
+		}
+		totalSize += int64(len(payload))
+		payloads[name] = payload
+		ap := aps[name]
+		ap.Binary.PayloadRef = &devicev1alpha1.PayloadReference{ConfigMapName: payloadConfigMapName(d), Key: name}
+		aps[name] = ap
+	}
+	if len(payloads) == 0 && !hasStalePayloads(d, aps) {
+		return nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	cm.Name, cm.Namespace = payloadConfigMapName(d), d.Namespace
+	_, err := controllerutil.CreateOrUpdate(ctx, c, cm, func() error {
+		if cm.BinaryData == nil {
+			cm.BinaryData = map[string][]byte{}
+		}
+		for name := range cm.BinaryData {
+			if aps[name].Binary == nil || aps[name].Binary.PayloadRef == nil {
+				delete(cm.BinaryData, name)
+			}
+		}
+		for name, payload := range payloads {
+			cm.BinaryData[name] = payload
+		}
+		return controllerutil.SetOwnerReference(d, cm, c.Scheme())
+	})
+	if err != nil {
+		// the hashes are still reported, without the payloads
+		for name := range payloads {
+			ap := aps[name]
+			ap.Binary.PayloadRef = nil
+			aps[name] = ap
+		}
+	}
+	return err
+}
+
+// hasStalePayloads checks if the ConfigMap of the device holds the payload of a property whose payload is no
+// longer stored, e.g. the property is no longer reported
+func hasStalePayloads(d *devicev1alpha1.Device, aps map[string]devicev1alpha1.ActualPropertyState) bool {
+	for name, old := range d.Status.DeviceProperties {
+		if old.Binary == nil || old.Binary.PayloadRef == nil {
+			continue
+		}
+		if ap, ok := aps[name]; !ok || ap.Binary == nil || ap.Binary.PayloadRef == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -18,17 +18,25 @@ package controllers
 
 import (
 	"context"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
 	edgeCli "github.com/openyurtio/device-controller/pkg/clients"
-	efCli "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
 	"github.com/openyurtio/device-controller/pkg/controllers/util"
+	"github.com/openyurtio/device-controller/pkg/metrics"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -40,19 +48,57 @@ type DeviceSyncer struct {
 	NodePool string
 	// edge platform's client
 	deviceCli edgeCli.DeviceInterface
+	// the edge events of the devices, nil if the changes are not subscribed
+	events   *edgeEventSubscription
+	recorder record.EventRecorder
 	// syncing period in seconds
 	syncPeriod time.Duration
-	Namespace  string
+	// syncing period when the changes are subscribed
+	resyncPeriod time.Duration
+	Namespace    string
+	// the default source of the actual device property values
+	propertySource devicev1alpha1.PropertySource
+	// the number of devices whose properties are refreshed concurrently
+	propertyWorkers int
+	// the deadline of refreshing the properties of a device
+	propertyTimeout time.Duration
+	// the time a round may spend on refreshing the properties
+	roundBudget time.Duration
+	// the size limit of the binary payloads stored in the ConfigMaps of the devices
+	payloadMaxSize int64
+	// lastRefreshed records when the properties of the devices were refreshed, keyed by the edge device name,
+	// the devices refreshed the longest time ago are refreshed first
+	lastRefreshed map[string]time.Time
+	// unrefreshed are the devices whose properties were not refreshed in the last round, keyed by the edge device
+	// name, they are refreshed before the pages of the next round so that no device is skipped round after round
+	unrefreshed map[string]struct{}
 }
 
 // NewDeviceSyncer initialize a New DeviceSyncer
-func NewDeviceSyncer(client client.Client, opts *options.YurtDeviceControllerOptions) (DeviceSyncer, error) {
+func NewDeviceSyncer(client client.Client, recorder record.EventRecorder, driver edgeCli.Driver, bus *EdgeEventBus, opts *options.YurtDeviceControllerOptions) (DeviceSyncer, error) {
+	deviceCli, err := driver.NewDeviceClient()
+	if err != nil {
+		return DeviceSyncer{}, err
+	}
+	roundBudget := time.Duration(opts.SyncRoundBudget) * time.Second
+	if roundBudget == 0 {
+		roundBudget = time.Duration(opts.EdgeSyncPeriod) * time.Second
+	}
 	return DeviceSyncer{
-		syncPeriod: time.Duration(opts.EdgeSyncPeriod) * time.Second,
-		deviceCli:  efCli.NewEdgexDeviceClient(opts.CoreMetadataAddr, opts.CoreCommandAddr),
-		Client:     client,
-		NodePool:   opts.Nodepool,
-		Namespace:  opts.Namespace,
+		syncPeriod:      time.Duration(opts.EdgeSyncPeriod) * time.Second,
+		resyncPeriod:    time.Duration(opts.EdgeResyncPeriod) * time.Second,
+		deviceCli:       deviceCli,
+		events:          bus.subscribe(edgeCli.DeviceEventKind),
+		recorder:        recorder,
+		Client:          client,
+		NodePool:        opts.Nodepool,
+		Namespace:       opts.Namespace,
+		propertySource:  devicev1alpha1.PropertySource(opts.PropertySource),
+		propertyWorkers: opts.PropertyWorkers,
+		propertyTimeout: time.Duration(opts.PropertyTimeout) * time.Second,
+		roundBudget:     roundBudget,
+		payloadMaxSize:  int64(opts.PayloadMaxSize),
+		lastRefreshed:   map[string]time.Time{},
 	}, nil
 }
 
@@ -64,77 +110,303 @@ func (ds *DeviceSyncer) NewDeviceSyncerRunnable() ctrlmgr.RunnableFunc {
 	}
 }
 
+// Run runs the rounds of synchronization and handles the edge events one at a time until stop is closed,
+// the next round is scheduled once the previous one completes so that the rounds never overlap
 func (ds *DeviceSyncer) Run(stop <-chan struct{}) {
 	klog.V(1).Info("[Device] Starting the syncer...")
+	events, overflow, period := ds.events.wait(stop, ds.syncPeriod, ds.resyncPeriod)
+	// abort the in-flight requests to the edge platform once stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
+		timer := time.NewTimer(period)
+		defer timer.Stop()
 		for {
-			<-time.After(ds.syncPeriod)
-			klog.V(2).Info("[Device] Start a round of synchronization.")
-			// 1. get device on edge platform and OpenYurt
-			edgeDevices, kubeDevices, err := ds.getAllDevices()
-			if err != nil {
-				klog.V(3).ErrorS(err, "fail to list the devices")
-				continue
+			select {
+			case <-stop:
+				return
+			case e := <-events:
+				ds.handleEdgeEvent(ctx, e)
+			case <-overflow:
+				ds.syncRound(ctx)
+			case <-timer.C:
+				ds.syncRound(ctx)
+				timer.Reset(period)
 			}
+		}
+	}()
 
-			// 2. find the device that need to be synchronized
-			redundantEdgeDevices, redundantKubeDevices, syncedDevices := ds.findDiffDevice(edgeDevices, kubeDevices)
-			klog.V(2).Infof("[Device] The number of objects waiting for synchronization { %s:%d, %s:%d, %s:%d }",
-				"Edge device should be added to OpenYurt", len(redundantEdgeDevices),
-				"OpenYurt device that should be deleted", len(redundantKubeDevices),
-				"Devices that should be synchronized", len(syncedDevices))
+	<-stop
+	klog.V(1).Info("[Device] Stopping the syncer")
+}
 
-			// 3. create device on OpenYurt which are exists in edge platform but not in OpenYurt
-			if err := ds.syncEdgeToKube(redundantEdgeDevices); err != nil {
-				klog.V(3).ErrorS(err, "fail to create devices on OpenYurt")
-			}
+// syncRound runs a round of synchronization of all the devices, the devices on the edge platform
+// are listed, diffed and synchronized page by page so that only a page of them is held in memory,
+// and only their names are kept to find the devices on OpenYurt which no longer exist on the edge platform
+func (ds *DeviceSyncer) syncRound(ctx context.Context) {
+	klog.V(2).Info("[Device] Start a round of synchronization.")
+	start := time.Now()
+	// 1. get device on OpenYurt
+	kubeDevices, err := ds.getKubeDevices()
+	if err != nil {
+		klog.V(3).ErrorS(err, "fail to list the devices object on the OpenYurt")
+		metrics.ObserveSyncRound(metrics.KindDevice, ds.NodePool, start, true)
+		return
+	}
 
-			// 4. delete redundant device on OpenYurt
-			if err := ds.deleteDevices(redundantKubeDevices); err != nil {
-				klog.V(3).ErrorS(err, "fail to delete redundant devices on OpenYurt")
+	// 2. refresh the properties of the devices which were not refreshed in the last round first, then for every
+	// page of the devices on edge platform, create device on OpenYurt which are exists in edge platform but not
+	// in OpenYurt, refresh the properties of the other devices which exist on both sides within the budget of
+	// the round and update their status on OpenYurt
+	roundCtx, cancel := withOptionalTimeout(ctx, ds.roundBudget)
+	defer cancel()
+	unrefreshedDevices := map[string]*devicev1alpha1.Device{}
+	for name := range ds.unrefreshed {
+		if kd, exist := kubeDevices[name]; exist {
+			unrefreshedDevices[name] = kd.DeepCopy()
+		}
+	}
+	skippedCount := ds.refreshProperties(roundCtx, unrefreshedDevices)
+	edgeDeviceNames := map[string]struct{}{}
+	syncedDeviceNames := map[string]struct{}{}
+	redundantEdgeCount := 0
+	err = ds.deviceCli.ListPages(ctx, edgeCli.ListOptions{}, func(devices []devicev1alpha1.Device) error {
+		edgeDevices := map[string]devicev1alpha1.Device{}
+		for i := range devices {
+			deviceName := util.GetEdgeDeviceName(&devices[i], EdgeXObjectName)
+			edgeDevices[deviceName] = devices[i]
+			edgeDeviceNames[deviceName] = struct{}{}
+		}
+		redundantEdgeDevices, syncedDevices := ds.diffEdgeDevices(edgeDevices, kubeDevices)
+		redundantEdgeCount += len(redundantEdgeDevices)
+		if err := ds.syncEdgeToKube(redundantEdgeDevices); err != nil {
+			klog.V(3).ErrorS(err, "fail to create devices on OpenYurt")
+		}
+		refreshedDevices := map[string]*devicev1alpha1.Device{}
+		for name := range syncedDevices {
+			syncedDeviceNames[name] = struct{}{}
+			if d, exist := unrefreshedDevices[name]; exist {
+				ed := edgeDevices[name]
+				syncedDevices[name] = ds.completeUpdateContent(d, &ed)
+			} else {
+				refreshedDevices[name] = syncedDevices[name]
 			}
+		}
+		skippedCount += ds.refreshProperties(roundCtx, refreshedDevices)
+		if err := ds.updateDevices(syncedDevices, kubeDevices); err != nil {
+			klog.V(3).ErrorS(err, "fail to update devices status")
+		}
+		return nil
+	})
+	if err != nil {
+		// the devices on OpenYurt can't be told redundant without visiting all the devices on edge platform
+		klog.V(3).ErrorS(err, "fail to list the devices object on the Edge Platform")
+		metrics.ObserveSyncRound(metrics.KindDevice, ds.NodePool, start, true)
+		return
+	}
+	if skippedCount > 0 {
+		klog.V(2).Infof("[Device] %d devices were not refreshed within the round budget %v", skippedCount, ds.roundBudget)
+		metrics.AddPropertyRefreshSkipped(ds.NodePool, skippedCount)
+	}
+	// forget the devices which no longer exist on edge platform
+	for name := range ds.lastRefreshed {
+		if _, exist := edgeDeviceNames[name]; !exist {
+			delete(ds.lastRefreshed, name)
+		}
+	}
+	ds.unrefreshed = map[string]struct{}{}
+	for name := range syncedDeviceNames {
+		if ds.lastRefreshed[name].Before(start) {
+			ds.unrefreshed[name] = struct{}{}
+		}
+	}
 
-			// 5. update device status on OpenYurt
-			if err := ds.updateDevices(syncedDevices); err != nil {
-				klog.V(3).ErrorS(err, "fail to update devices status")
-			}
-			klog.V(2).Info("[Device] One round of synchronization is complete")
+	// 3. delete redundant device on OpenYurt
+	redundantKubeDevices := ds.findRedundantKubeDevices(kubeDevices, func(name string) bool {
+		_, exists := edgeDeviceNames[name]
+		return exists
+	})
+	ds.confirmRedundantKubeDevices(ctx, redundantKubeDevices)
+	klog.V(2).Infof("[Device] The number of objects synchronized { %s:%d, %s:%d, %s:%d }",
+		"Edge device should be added to OpenYurt", redundantEdgeCount,
+		"OpenYurt device that should be deleted", len(redundantKubeDevices),
+		"Devices that should be synchronized", len(syncedDeviceNames))
+	metrics.SetSyncObjects(metrics.KindDevice, ds.NodePool, redundantEdgeCount, len(redundantKubeDevices), len(syncedDeviceNames))
+	if err := ds.deleteDevices(redundantKubeDevices); err != nil {
+		klog.V(3).ErrorS(err, "fail to delete redundant devices on OpenYurt")
+	}
+	metrics.ObserveSyncRound(metrics.KindDevice, ds.NodePool, start, false)
+	klog.V(2).Info("[Device] One round of synchronization is complete")
+}
+
+// handleEdgeEvent synchronizes the device changed on the edge platform right away
+func (ds *DeviceSyncer) handleEdgeEvent(ctx context.Context, e edgeCli.Event) {
+	klog.V(4).InfoS("[Device] Received an edge event", "Action", e.Action, "DeviceName", e.Name)
+	var err error
+	if e.Action == edgeCli.ReadingAction {
+		err = ds.updateReadings(e.Name, e.Readings)
+	} else {
+		err = ds.syncDevice(ctx, e.Name)
+	}
+	if err != nil {
+		klog.V(3).ErrorS(err, "fail to synchronize the device changed on the edge platform", "DeviceName", e.Name)
+	}
+}
+
+// syncDevice synchronizes a single device between the edge platform and OpenYurt
+func (ds *DeviceSyncer) syncDevice(ctx context.Context, name string) error {
+	edgeDevices, kubeDevices, err := ds.getDevice(ctx, name)
+	if err != nil {
+		return err
+	}
+	redundantEdgeDevices, redundantKubeDevices, syncedDevices := ds.findDiffDevice(edgeDevices, kubeDevices)
+	if err := ds.syncEdgeToKube(redundantEdgeDevices); err != nil {
+		return err
+	}
+	if err := ds.deleteDevices(redundantKubeDevices); err != nil {
+		return err
+	}
+	ds.refreshProperties(ctx, syncedDevices)
+	return ds.updateDevices(syncedDevices, kubeDevices)
+}
+
+// updateReadings sets the actual values of the device properties to the readings reported by the device,
+// the readings of the deviceResources are mapped onto the properties named by their read commands
+func (ds *DeviceSyncer) updateReadings(name string, readings map[string]string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		kd, err := ds.findKubeDevice(name)
+		if err != nil || kd == nil {
+			return err
 		}
-	}()
+		dp, err := findDeviceProfile(context.TODO(), ds.Client, kd)
+		if err != nil {
+			return err
+		}
+		updatedDevice := kd.DeepCopy()
+		if updatedDevice.Status.DeviceProperties == nil {
+			updatedDevice.Status.DeviceProperties = map[string]devicev1alpha1.ActualPropertyState{}
+		}
+		setReadings(updatedDevice.Status.DeviceProperties, readings, dp)
+		if equality.Semantic.DeepEqual(kd.Status, updatedDevice.Status) {
+			return nil
+		}
+		return ds.Client.Status().Update(context.TODO(), updatedDevice)
+	})
+}
 
-	<-stop
-	klog.V(1).Info("[Device] Stopping the syncer")
+// setReadings sets the readings of the deviceResources to the properties named by the read commands like
+// the properties read from core-command: a deviceResource is read by the command of its name unless it is hidden,
+// a deviceCommand operating a single deviceResource takes its reading as the value, and a deviceCommand operating
+// several takes their readings as the parameters. The readings are set as they are if the deviceProfile is not found.
+func setReadings(aps map[string]devicev1alpha1.ActualPropertyState, readings map[string]string, dp *devicev1alpha1.DeviceProfile) {
+	setValue := func(pn, value string) {
+		ap := aps[pn]
+		ap.Name = pn
+		// a binary reading carries the hash of the payload, the new payload is stored when the property is refreshed
+		if ap.Binary != nil && ap.Binary.Hash != value {
+			ap.Binary = nil
+		}
+		ap.ActualValue = value
+		aps[pn] = ap
+	}
+	if dp == nil {
+		for pn, value := range readings {
+			setValue(pn, value)
+		}
+		return
+	}
+	for _, dr := range dp.Spec.DeviceResources {
+		if value, ok := readings[dr.Name]; ok && !dr.IsHidden && strings.Contains(dr.Properties.ReadWrite, "R") {
+			setValue(dr.Name, value)
+		}
+	}
+	for _, dc := range dp.Spec.DeviceCommands {
+		if dc.IsHidden || !strings.Contains(dc.ReadWrite, "R") || len(dc.ResourceOperations) == 0 {
+			continue
+		}
+		if len(dc.ResourceOperations) == 1 {
+			if value, ok := readings[dc.ResourceOperations[0].DeviceResource]; ok {
+				setValue(dc.Name, value)
+			}
+			continue
+		}
+		for _, ro := range dc.ResourceOperations {
+			value, ok := readings[ro.DeviceResource]
+			if !ok {
+				continue
+			}
+			ap := aps[dc.Name]
+			ap.Name = dc.Name
+			parameters := make(map[string]string, len(dc.ResourceOperations))
+			for k, v := range ap.ActualParameters {
+				parameters[k] = v
+			}
+			parameters[ro.DeviceResource] = value
+			ap.ActualParameters = parameters
+			aps[dc.Name] = ap
+		}
+	}
 }
 
-// Get the existing Device on the Edge platform, as well as OpenYurt existing Device
-// edgeDevice：map[actualName]device
-// kubeDevice：map[actualName]device
-func (ds *DeviceSyncer) getAllDevices() (map[string]devicev1alpha1.Device, map[string]devicev1alpha1.Device, error) {
+// getDevice gets the device of the given name on the edge platform and OpenYurt,
+// the returned maps are empty if the device doesn't exist
+func (ds *DeviceSyncer) getDevice(ctx context.Context, name string) (map[string]devicev1alpha1.Device, map[string]devicev1alpha1.Device, error) {
 	edgeDevice := map[string]devicev1alpha1.Device{}
 	kubeDevice := map[string]devicev1alpha1.Device{}
-	// 1. list devices on edge platform
-	eDevs, err := ds.deviceCli.List(nil, edgeCli.ListOptions{})
+	ed, err := ds.deviceCli.Get(ctx, name, edgeCli.GetOptions{})
+	if err == nil {
+		edgeDevice[util.GetEdgeDeviceName(ed, EdgeXObjectName)] = *ed
+	} else if !edgeCli.IsNotFoundErr(err) {
+		return edgeDevice, kubeDevice, err
+	}
+	kd, err := ds.findKubeDevice(name)
 	if err != nil {
-		klog.V(4).ErrorS(err, "fail to list the devices object on the Edge Platform")
 		return edgeDevice, kubeDevice, err
 	}
-	// 2. list devices on OpenYurt (filter objects belonging to edgeServer)
+	if kd != nil {
+		kubeDevice[name] = *kd
+	}
+	return edgeDevice, kubeDevice, nil
+}
+
+// findKubeDevice returns the device on OpenYurt which has the given name on the edge platform,
+// it is looked up by the label of its edge name, or by its name if it has no such label
+func (ds *DeviceSyncer) findKubeDevice(name string) (*devicev1alpha1.Device, error) {
 	var kDevs devicev1alpha1.DeviceList
 	listOptions := client.MatchingFields{util.IndexerPathForNodepool: ds.NodePool}
-	if err = ds.List(context.TODO(), &kDevs, listOptions, client.InNamespace(ds.Namespace)); err != nil {
-		klog.V(4).ErrorS(err, "fail to list the devices object on the OpenYurt")
-		return edgeDevice, kubeDevice, err
+	if err := ds.List(context.TODO(), &kDevs, listOptions, client.MatchingLabels{EdgeXObjectName: name},
+		client.InNamespace(ds.Namespace)); err != nil {
+		return nil, err
 	}
-	for i := range eDevs {
-		deviceName := util.GetEdgeDeviceName(&eDevs[i], EdgeXObjectName)
-		edgeDevice[deviceName] = eDevs[i]
+	for i := range kDevs.Items {
+		if kDevs.Items[i].Spec.NodePool == ds.NodePool {
+			return &kDevs.Items[i], nil
+		}
+	}
+	var kd devicev1alpha1.Device
+	if err := ds.Get(context.TODO(), types.NamespacedName{Namespace: ds.Namespace, Name: name}, &kd); err != nil {
+		return nil, client.IgnoreNotFound(err)
 	}
+	if kd.Spec.NodePool != ds.NodePool || util.GetEdgeDeviceName(&kd, EdgeXObjectName) != name {
+		return nil, nil
+	}
+	return &kd, nil
+}
 
+// getKubeDevices gets the devices on OpenYurt which belong to the nodePool
+// kubeDevice：map[actualName]device
+func (ds *DeviceSyncer) getKubeDevices() (map[string]devicev1alpha1.Device, error) {
+	kubeDevice := map[string]devicev1alpha1.Device{}
+	var kDevs devicev1alpha1.DeviceList
+	listOptions := client.MatchingFields{util.IndexerPathForNodepool: ds.NodePool}
+	if err := ds.List(context.TODO(), &kDevs, listOptions, client.InNamespace(ds.Namespace)); err != nil {
+		return kubeDevice, err
+	}
 	for i := range kDevs.Items {
 		deviceName := util.GetEdgeDeviceName(&kDevs.Items[i], EdgeXObjectName)
 		kubeDevice[deviceName] = kDevs.Items[i]
 	}
-	return edgeDevice, kubeDevice, nil
+	return kubeDevice, nil
 }
 
 // Get the list of devices that need to be added, deleted and updated
@@ -142,14 +414,30 @@ func (ds *DeviceSyncer) findDiffDevice(
 	edgeDevices map[string]devicev1alpha1.Device, kubeDevices map[string]devicev1alpha1.Device) (
 	redundantEdgeDevices map[string]*devicev1alpha1.Device, redundantKubeDevices map[string]*devicev1alpha1.Device, syncedDevices map[string]*devicev1alpha1.Device) {
 
+	redundantEdgeDevices, syncedDevices = ds.diffEdgeDevices(edgeDevices, kubeDevices)
+	redundantKubeDevices = ds.findRedundantKubeDevices(kubeDevices, func(name string) bool {
+		_, exists := edgeDevices[name]
+		return exists
+	})
+	return
+}
+
+// diffEdgeDevices finds the devices on edge platform which need to be added to OpenYurt,
+// and the ones which exist on both sides and need to be synchronized
+func (ds *DeviceSyncer) diffEdgeDevices(edgeDevices map[string]devicev1alpha1.Device, kubeDevices map[string]devicev1alpha1.Device) (
+	redundantEdgeDevices map[string]*devicev1alpha1.Device, syncedDevices map[string]*devicev1alpha1.Device) {
+
 	redundantEdgeDevices = map[string]*devicev1alpha1.Device{}
-	redundantKubeDevices = map[string]*devicev1alpha1.Device{}
 	syncedDevices = map[string]*devicev1alpha1.Device{}
-
 	for i := range edgeDevices {
 		ed := edgeDevices[i]
 		edName := util.GetEdgeDeviceName(&ed, EdgeXObjectName)
 		if _, exists := kubeDevices[edName]; !exists {
+			if isOrphaned(ed.Spec.Labels) {
+				// the device was left on the edge platform when its OpenYurt device was deleted
+				klog.V(5).Infof("skip orphaned edge device %s", edName)
+				continue
+			}
 			klog.V(5).Infof("found redundant edge device %s", edName)
 			redundantEdgeDevices[edName] = ds.completeCreateContent(&ed)
 		} else {
@@ -158,18 +446,34 @@ func (ds *DeviceSyncer) findDiffDevice(
 			syncedDevices[edName] = ds.completeUpdateContent(&kd, &ed)
 		}
 	}
+	return
+}
 
+// findRedundantKubeDevices finds the synced devices on OpenYurt which no longer exist on edge platform
+func (ds *DeviceSyncer) findRedundantKubeDevices(kubeDevices map[string]devicev1alpha1.Device, existsOnEdge func(name string) bool) map[string]*devicev1alpha1.Device {
+	redundantKubeDevices := map[string]*devicev1alpha1.Device{}
 	for i := range kubeDevices {
 		kd := kubeDevices[i]
 		if !kd.Status.Synced {
 			continue
 		}
 		kdName := util.GetEdgeDeviceName(&kd, EdgeXObjectName)
-		if _, exists := edgeDevices[kdName]; !exists {
+		if !existsOnEdge(kdName) {
 			redundantKubeDevices[kdName] = &kd
 		}
 	}
-	return
+	return redundantKubeDevices
+}
+
+// confirmRedundantKubeDevices keeps only the redundant devices which are confirmed to no longer exist on edge
+// platform, the paged list of the edge platform misses a device when another one is deleted meanwhile
+func (ds *DeviceSyncer) confirmRedundantKubeDevices(ctx context.Context, redundantKubeDevices map[string]*devicev1alpha1.Device) {
+	for name := range redundantKubeDevices {
+		if _, err := ds.deviceCli.Get(ctx, name, edgeCli.GetOptions{}); !edgeCli.IsNotFoundErr(err) {
+			klog.V(4).InfoS("the device is not confirmed to be deleted from the edge platform, keep it", "DeviceName", name, "err", err)
+			delete(redundantKubeDevices, name)
+		}
+	}
 }
 
 // syncEdgeToKube creates device on OpenYurt which are exists in edge platform but not in OpenYurt
@@ -180,8 +484,12 @@ func (ds *DeviceSyncer) syncEdgeToKube(edgeDevs map[string]*devicev1alpha1.Devic
 				continue
 			}
 			klog.V(5).ErrorS(err, "fail to create device on OpenYurt", "DeviceName", strings.ToLower(ed.Name))
+			ds.recorder.Eventf(ed, corev1.EventTypeWarning, EventReasonImportFailed, "Failed to import device %s from the edge platform: %v",
+				util.GetEdgeDeviceName(ed, EdgeXObjectName), err)
 			return err
 		}
+		ds.recorder.Eventf(ed, corev1.EventTypeNormal, EventReasonImported, "Imported device %s from the edge platform",
+			util.GetEdgeDeviceName(ed, EdgeXObjectName))
 	}
 	return nil
 }
@@ -194,12 +502,14 @@ func (ds *DeviceSyncer) deleteDevices(redundantKubeDevices map[string]*devicev1a
 				"DeviceName", kd.Name)
 			return err
 		}
+		ds.recorder.Eventf(kd, corev1.EventTypeNormal, EventReasonRemovedFromEdge, "Deleted the device since %s no longer exists on the edge platform",
+			util.GetEdgeDeviceName(kd, EdgeXObjectName))
 	}
 	return nil
 }
 
-// updateDevicesStatus updates device status on OpenYurt
-func (ds *DeviceSyncer) updateDevices(syncedDevices map[string]*devicev1alpha1.Device) error {
+// updateDevicesStatus updates device status on OpenYurt, kubeDevices are the devices before the update
+func (ds *DeviceSyncer) updateDevices(syncedDevices map[string]*devicev1alpha1.Device, kubeDevices map[string]devicev1alpha1.Device) error {
 	for n := range syncedDevices {
 		if err := ds.Client.Status().Update(context.TODO(), syncedDevices[n]); err != nil {
 			if apierrors.IsConflict(err) {
@@ -208,6 +518,7 @@ func (ds *DeviceSyncer) updateDevices(syncedDevices map[string]*devicev1alpha1.D
 			}
 			return err
 		}
+		recordAdminStateChange(ds.recorder, syncedDevices[n], kubeDevices[n].Status.AdminState, syncedDevices[n].Status.AdminState)
 	}
 	return nil
 }
@@ -223,15 +534,150 @@ func (ds *DeviceSyncer) completeCreateContent(edgeDevice *devicev1alpha1.Device)
 	return createDevice
 }
 
-// completeUpdateContent completes the content of the device which will be updated on OpenYurt
+// completeUpdateContent completes the content of the device which will be updated on OpenYurt,
+// the properties are refreshed by refreshProperties
 func (ds *DeviceSyncer) completeUpdateContent(kubeDevice *devicev1alpha1.Device, edgeDevice *devicev1alpha1.Device) *devicev1alpha1.Device {
 	updatedDevice := kubeDevice.DeepCopy()
-	_, aps, _ := ds.deviceCli.ListPropertiesState(nil, updatedDevice, edgeCli.ListOptions{})
 	// update device status
 	updatedDevice.Status.LastConnected = edgeDevice.Status.LastConnected
 	updatedDevice.Status.LastReported = edgeDevice.Status.LastReported
 	updatedDevice.Status.AdminState = edgeDevice.Status.AdminState
 	updatedDevice.Status.OperatingState = edgeDevice.Status.OperatingState
-	updatedDevice.Status.DeviceProperties = aps
+	updatedDevice.Status.AutoEvents = edgeDevice.Status.AutoEvents
 	return updatedDevice
 }
+
+// refreshProperties gets the actual property values of the devices with a bounded number of workers.
+// Every device has its own deadline, and the devices not refreshed within the round budget keep their
+// previous values, it returns the number of them. The devices failed to refresh keep their previous values too.
+func (ds *DeviceSyncer) refreshProperties(ctx context.Context, devices map[string]*devicev1alpha1.Device) (skipped int) {
+	if len(devices) == 0 {
+		return 0
+	}
+	if ds.lastRefreshed == nil {
+		ds.lastRefreshed = map[string]time.Time{}
+	}
+	// the devices refreshed the longest time ago go first, so that no device is skipped round after round
+	names := make([]string, 0, len(devices))
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := ds.lastRefreshed[names[i]], ds.lastRefreshed[names[j]]
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return names[i] < names[j]
+	})
+
+	ctx, cancel := withOptionalTimeout(ctx, ds.roundBudget)
+	defer cancel()
+	workers := ds.propertyWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(names) {
+		workers = len(names)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	queue := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range queue {
+				d := devices[name]
+				deviceCtx, deviceCancel := withOptionalTimeout(ctx, ds.propertyTimeout)
+				aps, err := ds.getActualProperties(deviceCtx, d)
+				deadlineExceeded := deviceCtx.Err() != nil
+				deviceCancel()
+				mu.Lock()
+				if err == nil {
+					d.Status.DeviceProperties = aps
+					ds.lastRefreshed[name] = time.Now()
+				} else if deadlineExceeded {
+					skipped++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i, name := range names {
+		select {
+		case queue <- name:
+		case <-ctx.Done():
+			mu.Lock()
+			skipped += len(names) - i
+			mu.Unlock()
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return skipped
+}
+
+// withOptionalTimeout returns a context with the timeout, or without any deadline if the timeout is not positive
+func withOptionalTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// getActualProperties gets the actual property values of the device from the source it is configured with.
+// If the deadline of ctx is exceeded or the edge platform fails, an error is returned and the failure
+// is recorded in the EdgeAvailable condition of the device.
+func (ds *DeviceSyncer) getActualProperties(ctx context.Context, d *devicev1alpha1.Device) (map[string]devicev1alpha1.ActualPropertyState, error) {
+	fromCoreData := effectivePropertySource(d.Spec.PropertySource, ds.propertySource) == devicev1alpha1.CoreDataSource
+	var aps map[string]devicev1alpha1.ActualPropertyState
+	var err error
+	if fromCoreData {
+		aps, err = ds.deviceCli.ListLatestPropertiesState(ctx, d, edgeCli.ListOptions{})
+	} else {
+		_, aps, err = ds.deviceCli.ListPropertiesState(ctx, d, edgeCli.ListOptions{})
+	}
+	if ctx.Err() != nil {
+		klog.V(5).InfoS("deadline exceeded when refreshing the properties of device", "DeviceName", d.Name)
+		return nil, ctx.Err()
+	}
+	handleEdgeError(d, ctrl.Result{}, err)
+	if err != nil {
+		klog.V(5).ErrorS(err, "fail to get the actual properties of device", "DeviceName", d.Name)
+		return nil, err
+	}
+	if fromCoreData {
+		// readings don't carry the read command of a property, keep the one we already know
+		for name, ap := range aps {
+			if old, exist := d.Status.DeviceProperties[name]; exist {
+				ap.GetURL = old.GetURL
+				aps[name] = ap
+			}
+		}
+	}
+	ds.completeProperties(ctx, d, aps)
+	return aps, nil
+}
+
+// completeProperties sets the units of the actual property values from the deviceProfile of the device,
+// and stores the payloads of the binary properties
+func (ds *DeviceSyncer) completeProperties(ctx context.Context, d *devicev1alpha1.Device, aps map[string]devicev1alpha1.ActualPropertyState) {
+	if err := storeBinaryPayloads(ctx, ds.Client, d, aps, ds.payloadMaxSize); err != nil {
+		klog.V(3).ErrorS(err, "fail to store the binary payloads of device", "DeviceName", d.Name)
+	}
+	if len(aps) == 0 {
+		return
+	}
+	dp, err := findDeviceProfile(ctx, ds.Client, d)
+	if err != nil {
+		klog.V(5).ErrorS(err, "fail to get the deviceProfile of device", "DeviceName", d.Name)
+		return
+	}
+	setPropertyUnits(aps, dp)
+}
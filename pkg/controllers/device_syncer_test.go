@@ -0,0 +1,514 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+	edgex "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
+	"github.com/openyurtio/device-controller/pkg/clients/edgex-foundry/edgextest"
+	"github.com/openyurtio/device-controller/pkg/clients/fake"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDeviceSyncerHandlesEdgeEvents(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := devicev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+	store := fake.NewStore()
+	ds := DeviceSyncer{
+		Client:         fakeclient.NewClientBuilder().WithScheme(scheme).Build(),
+		NodePool:       testNodePool,
+		Namespace:      "default",
+		deviceCli:      fake.NewFakeDeviceClient(store),
+		recorder:       record.NewFakeRecorder(10),
+		syncPeriod:     time.Second,
+		resyncPeriod:   time.Hour,
+		propertySource: devicev1alpha1.CoreDataSource,
+	}
+	bus := NewEdgeEventBus(fake.NewFakeEventClient(store))
+	ds.events = bus.subscribe(clients.DeviceEventKind)
+	stop := make(chan struct{})
+	defer close(stop)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bus.Start(ctx)
+	go ds.Run(stop)
+	<-bus.ready
+
+	store.AddDevice(&devicev1alpha1.Device{
+		ObjectMeta: metav1.ObjectMeta{Name: "random-device"},
+		Spec: devicev1alpha1.DeviceSpec{
+			AdminState:     devicev1alpha1.UnLocked,
+			OperatingState: devicev1alpha1.Up,
+			Service:        "device-virtual",
+			Profile:        "Random-Integer-Device",
+		},
+	})
+	key := types.NamespacedName{Namespace: "default", Name: testNodePool + "-random-device"}
+	var d devicev1alpha1.Device
+	waitFor(t, "the edge device to be imported", func() bool {
+		return ds.Get(context.TODO(), key, &d) == nil
+	})
+
+	store.SetProperty("random-device", "Int8", "12")
+	waitFor(t, "the reading to be synchronized", func() bool {
+		if err := ds.Get(context.TODO(), key, &d); err != nil {
+			return false
+		}
+		return d.Status.DeviceProperties["Int8"].ActualValue == "12"
+	})
+}
+
+func TestDeviceSyncerKeysReadingsByReadCommands(t *testing.T) {
+	kd := newTestDevice("random-device")
+	kd.Labels = map[string]string{EdgeXObjectName: "edge-random-device"}
+	dp := newTestDeviceProfile("Random-Integer-Device")
+	dp.Spec.DeviceResources = []devicev1alpha1.DeviceResource{
+		{Name: "Int8", Properties: devicev1alpha1.ResourceProperties{ValueType: "Int8", ReadWrite: "RW"}},
+		{Name: "RawInt16", IsHidden: true, Properties: devicev1alpha1.ResourceProperties{ValueType: "Int16", ReadWrite: "R"}},
+		{Name: "R", IsHidden: true, Properties: devicev1alpha1.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+		{Name: "G", IsHidden: true, Properties: devicev1alpha1.ResourceProperties{ValueType: "Uint8", ReadWrite: "RW"}},
+	}
+	dp.Spec.DeviceCommands = []devicev1alpha1.DeviceProfileCommand{
+		{Name: "Int16", ReadWrite: "R", ResourceOperations: []devicev1alpha1.ResourceOperation{{DeviceResource: "RawInt16"}}},
+		{Name: "Color", ReadWrite: "RW", ResourceOperations: []devicev1alpha1.ResourceOperation{{DeviceResource: "R"}, {DeviceResource: "G"}}},
+	}
+	ds := DeviceSyncer{
+		Client:    newTestClient(t, kd, dp),
+		NodePool:  testNodePool,
+		Namespace: "default",
+	}
+	if err := ds.updateReadings("edge-random-device", map[string]string{"Int8": "12", "RawInt16": "300", "R": "255"}); err != nil {
+		t.Fatalf("failed to update the readings: %v", err)
+	}
+	if err := ds.updateReadings("edge-random-device", map[string]string{"G": "128"}); err != nil {
+		t.Fatalf("failed to update the readings: %v", err)
+	}
+	var got devicev1alpha1.Device
+	if err := ds.Get(context.TODO(), client.ObjectKeyFromObject(kd), &got); err != nil {
+		t.Fatalf("failed to get the device: %v", err)
+	}
+	want := map[string]devicev1alpha1.ActualPropertyState{
+		"Int8":  {Name: "Int8", ActualValue: "12"},
+		"Int16": {Name: "Int16", ActualValue: "300"},
+		"Color": {Name: "Color", ActualParameters: map[string]string{"R": "255", "G": "128"}},
+	}
+	if !reflect.DeepEqual(got.Status.DeviceProperties, want) {
+		t.Errorf("expected the properties %+v, got %+v", want, got.Status.DeviceProperties)
+	}
+}
+
+func TestEdgeEventBusSignalsOverflow(t *testing.T) {
+	bus := NewEdgeEventBus(fake.NewFakeEventClient(fake.NewStore()))
+	devices := bus.subscribe(clients.DeviceEventKind)
+	services := bus.subscribe(clients.DeviceServiceEventKind)
+	for i := 0; i < edgeEventBufferSize+2; i++ {
+		bus.dispatch(clients.Event{Kind: clients.DeviceEventKind, Action: clients.UpdateAction, Name: "random-device"})
+	}
+	if len(devices.events) != edgeEventBufferSize {
+		t.Errorf("expected %d buffered events, got %d", edgeEventBufferSize, len(devices.events))
+	}
+	if len(devices.overflow) != 1 {
+		t.Errorf("expected the overflow of the devices to be signaled once")
+	}
+	if len(services.events) != 0 || len(services.overflow) != 0 {
+		t.Errorf("expected no event delivered to the deviceServices")
+	}
+}
+
+func TestEdgeEventBusResyncsAllSyncers(t *testing.T) {
+	bus := NewEdgeEventBus(fake.NewFakeEventClient(fake.NewStore()))
+	devices := bus.subscribe(clients.DeviceEventKind)
+	services := bus.subscribe(clients.DeviceServiceEventKind)
+	profiles := bus.subscribe(clients.DeviceProfileEventKind)
+	bus.dispatch(clients.Event{Action: clients.ResyncAction})
+	bus.dispatch(clients.Event{Action: clients.ResyncAction})
+	for _, sub := range []*edgeEventSubscription{devices, services, profiles} {
+		if len(sub.overflow) != 1 {
+			t.Errorf("expected the %s syncer to be signaled to resynchronize once", sub.kind)
+		}
+		if len(sub.events) != 0 {
+			t.Errorf("expected no event delivered to the %s syncer", sub.kind)
+		}
+	}
+}
+
+func TestDeviceSyncerPagesThroughEdgeDevices(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := devicev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+	removed := newTestDevice("removed-device")
+	removed.Finalizers = nil
+	removed.Status.Synced = true
+	store := fake.NewStore()
+	store.SetPageSize(2)
+	for i := 0; i < 5; i++ {
+		store.AddDevice(&devicev1alpha1.Device{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("random-device-%d", i)},
+			Spec:       devicev1alpha1.DeviceSpec{Service: "device-virtual", Profile: "Random-Integer-Device"},
+		})
+	}
+	ds := DeviceSyncer{
+		Client:          fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(removed).Build(),
+		NodePool:        testNodePool,
+		Namespace:       "default",
+		deviceCli:       fake.NewFakeDeviceClient(store),
+		recorder:        record.NewFakeRecorder(20),
+		propertySource:  devicev1alpha1.CoreDataSource,
+		propertyWorkers: 1,
+		propertyTimeout: time.Second,
+	}
+
+	// the devices on OpenYurt are kept if the devices on edge platform can't be listed completely
+	store.InjectError(fake.ListVerb, fake.DeviceKind, "", fake.ErrTimeout)
+	ds.syncRound(context.TODO())
+	var d devicev1alpha1.Device
+	if err := ds.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "removed-device"}, &d); err != nil {
+		t.Errorf("expected the device to be kept when the list fails: %v", err)
+	}
+
+	// nor if they are not confirmed to be deleted from the edge platform, since the paged list may miss them
+	store.ClearErrors()
+	store.InjectError(fake.GetVerb, fake.DeviceKind, "removed-device", fake.ErrTimeout)
+	ds.syncRound(context.TODO())
+	if err := ds.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "removed-device"}, &d); err != nil {
+		t.Errorf("expected the device to be kept when it is not confirmed to be deleted: %v", err)
+	}
+
+	store.ClearErrors()
+	ds.syncRound(context.TODO())
+	var devices devicev1alpha1.DeviceList
+	if err := ds.List(context.TODO(), &devices); err != nil {
+		t.Fatalf("failed to list devices: %v", err)
+	}
+	if len(devices.Items) != 5 {
+		t.Errorf("expected the 5 edge devices to be imported and the removed device to be deleted, got %d devices", len(devices.Items))
+	}
+	for _, d := range devices.Items {
+		if d.Name == "removed-device" {
+			t.Errorf("expected device %s to be deleted", d.Name)
+		}
+	}
+
+	// the imported devices are refreshed and updated page by page
+	for i := 0; i < 5; i++ {
+		store.SetProperty(fmt.Sprintf("random-device-%d", i), "Int8", strconv.Itoa(i))
+	}
+	ds.syncRound(context.TODO())
+	devices = devicev1alpha1.DeviceList{}
+	if err := ds.List(context.TODO(), &devices); err != nil {
+		t.Fatalf("failed to list devices: %v", err)
+	}
+	for _, d := range devices.Items {
+		if v := d.Status.DeviceProperties["Int8"].ActualValue; v == "" {
+			t.Errorf("expected the properties of device %s to be refreshed", d.Name)
+		}
+	}
+}
+
+func TestDeviceSyncerRefreshesUnrefreshedDevicesFirst(t *testing.T) {
+	store := fake.NewStore()
+	store.SetPageSize(2)
+	var objs []client.Object
+	for i := 0; i < 4; i++ {
+		d := newTestDevice(fmt.Sprintf("random-device-%d", i))
+		d.Labels = map[string]string{EdgeXObjectName: d.Name}
+		store.AddDevice(d)
+		store.SetProperty(d.Name, "Int8", "1")
+		objs = append(objs, d)
+	}
+	// only one device is refreshed within the budget of a round
+	var mu sync.Mutex
+	store.SetPropertyReadFunc(func(deviceName, propertyName string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		time.Sleep(150 * time.Millisecond)
+		return "1", nil
+	})
+	ds := DeviceSyncer{
+		Client:          newTestClient(t, objs...),
+		NodePool:        testNodePool,
+		Namespace:       "default",
+		deviceCli:       fake.NewFakeDeviceClient(store),
+		recorder:        record.NewFakeRecorder(20),
+		propertySource:  devicev1alpha1.CoreCommandSource,
+		propertyWorkers: 1,
+		roundBudget:     200 * time.Millisecond,
+	}
+
+	// the pages come in the same order every round, the devices of the last page are refreshed too
+	for i := 0; i < 4; i++ {
+		ds.syncRound(context.TODO())
+	}
+	for _, obj := range objs {
+		var d devicev1alpha1.Device
+		if err := ds.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: obj.GetName()}, &d); err != nil {
+			t.Fatalf("failed to get device %s: %v", obj.GetName(), err)
+		}
+		if _, ok := d.Status.DeviceProperties["Int8"]; !ok {
+			t.Errorf("expected the properties of device %s to be refreshed within 4 rounds", d.Name)
+		}
+	}
+}
+
+// waitFor waits a second at most for the condition to be true
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeviceSyncerRefreshProperties(t *testing.T) {
+	s := edgextest.NewServer()
+	defer s.Close()
+	s.AddDeviceService(dtos.DeviceService{Name: "device-virtual", BaseAddress: "http://edgex-device-virtual:59900", AdminState: "UNLOCKED"})
+	s.AddDeviceProfile(dtos.DeviceProfile{
+		Name: "Random-Integer-Device",
+		DeviceResources: []dtos.DeviceResource{
+			{Name: "Int8", Properties: dtos.ResourceProperties{ValueType: "Int8", ReadWrite: "RW", DefaultValue: "0"}},
+			{Name: "Int16", Properties: dtos.ResourceProperties{ValueType: "Int16", ReadWrite: "R", DefaultValue: "0"}},
+		},
+	})
+	devices := map[string]*devicev1alpha1.Device{}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("random-device-%02d", i)
+		s.AddDevice(dtos.Device{Name: name, ServiceName: "device-virtual", ProfileName: "Random-Integer-Device",
+			AdminState: "UNLOCKED", OperatingState: "UP"})
+		devices[name] = &devicev1alpha1.Device{ObjectMeta: metav1.ObjectMeta{
+			Name:   testNodePool + "-" + name,
+			Labels: map[string]string{EdgeXObjectName: name},
+		}}
+	}
+	// reading the properties of a device takes 3 requests of 100ms, 6s for all the devices one by one
+	s.SetLatency(100 * time.Millisecond)
+
+	ds := DeviceSyncer{
+		Client:          newTestClient(t),
+		NodePool:        testNodePool,
+		deviceCli:       edgex.NewEdgexDeviceClient(s.MetadataAddr(), s.CommandAddr(), s.DataAddr()),
+		propertySource:  devicev1alpha1.CoreCommandSource,
+		propertyWorkers: 10,
+		propertyTimeout: 2 * time.Second,
+		roundBudget:     5 * time.Second,
+	}
+	start := time.Now()
+	if skipped := ds.refreshProperties(context.TODO(), devices); skipped != 0 {
+		t.Errorf("expected all the devices to be refreshed, %d skipped", skipped)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the devices to be refreshed concurrently, took %v", elapsed)
+	}
+	for name, d := range devices {
+		if _, ok := d.Status.DeviceProperties["Int16"]; !ok {
+			t.Errorf("expected the properties of device %s to be refreshed, got %+v", name, d.Status.DeviceProperties)
+		}
+		d.Status.DeviceProperties = nil
+	}
+
+	// the round stops at the budget and leaves the remaining devices as they are
+	ds.roundBudget = 400 * time.Millisecond
+	start = time.Now()
+	skipped := ds.refreshProperties(context.TODO(), devices)
+	if skipped == 0 || skipped == len(devices) {
+		t.Errorf("expected a part of the devices to be skipped, %d skipped", skipped)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the round to stop at its budget, took %v", elapsed)
+	}
+	var stale []string
+	for name, d := range devices {
+		if d.Status.DeviceProperties == nil {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) != skipped {
+		t.Fatalf("expected %d devices to keep their properties, got %d", skipped, len(stale))
+	}
+
+	// the skipped devices go first in the next round
+	ds.propertyWorkers = 1
+	ds.refreshProperties(context.TODO(), devices)
+	for _, name := range stale {
+		if devices[name].Status.DeviceProperties != nil {
+			return
+		}
+	}
+	t.Errorf("expected the devices skipped in the last round to be refreshed first")
+}
+
+func TestDeviceSyncerKeepsPropertiesWhenEdgeFails(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("random-device")
+	device.Status = *store.AddDevice(device).Status.DeepCopy()
+	device.Status.DeviceProperties = map[string]devicev1alpha1.ActualPropertyState{
+		"Int8": {Name: "Int8", ActualValue: "1"},
+	}
+	store.SetProperty("random-device", "Int8", "2")
+	ds := DeviceSyncer{
+		Client:          newTestClient(t, device),
+		NodePool:        testNodePool,
+		Namespace:       "default",
+		deviceCli:       fake.NewFakeDeviceClient(store),
+		propertySource:  devicev1alpha1.CoreCommandSource,
+		propertyWorkers: 1,
+	}
+	devices := map[string]*devicev1alpha1.Device{"random-device": device}
+
+	store.InjectError(fake.ListPropertyVerb, fake.DeviceKind, "random-device", fake.ErrTimeout)
+	if skipped := ds.refreshProperties(context.TODO(), devices); skipped != 0 {
+		t.Errorf("expected the failed device not to be counted as skipped, %d skipped", skipped)
+	}
+	if v := device.Status.DeviceProperties["Int8"].ActualValue; v != "1" {
+		t.Errorf("expected the previous value of Int8 to be kept, got %q", v)
+	}
+	if !conditions.IsFalse(device, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected condition %s to be false", devicev1alpha1.EdgeAvailableCondition)
+	}
+
+	store.ClearErrors()
+	ds.refreshProperties(context.TODO(), devices)
+	if v := device.Status.DeviceProperties["Int8"].ActualValue; v != "2" {
+		t.Errorf("expected the actual value of Int8 to be refreshed, got %q", v)
+	}
+	if !conditions.IsTrue(device, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected condition %s to be true", devicev1alpha1.EdgeAvailableCondition)
+	}
+}
+
+func TestDeviceSyncerStoresBinaryPayloads(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("camera")
+	device.Labels = map[string]string{EdgeXObjectName: "camera"}
+	store.AddDevice(device)
+	snapshot := []byte{0xff, 0xd8, 0xff, 0xe0}
+	store.SetBinaryProperty("camera", "Snapshot", "image/jpeg", snapshot)
+	store.SetBinaryProperty("camera", "Video", "video/mp4", make([]byte, 1024))
+	ds := DeviceSyncer{
+		Client:          newTestClient(t, device),
+		NodePool:        testNodePool,
+		Namespace:       "default",
+		deviceCli:       fake.NewFakeDeviceClient(store),
+		propertyWorkers: 1,
+		payloadMaxSize:  512,
+	}
+
+	devices := map[string]*devicev1alpha1.Device{"camera": device}
+	ds.refreshProperties(context.TODO(), devices)
+	snapshotState := device.Status.DeviceProperties["Snapshot"]
+	if snapshotState.ActualValue != clients.PayloadHash(snapshot) || snapshotState.Binary == nil ||
+		snapshotState.Binary.Size != 4 || snapshotState.Binary.MediaType != "image/jpeg" {
+		t.Fatalf("expected the hash and the size of the snapshot in the status, got %+v", snapshotState)
+	}
+	ref := snapshotState.Binary.PayloadRef
+	if ref == nil || ref.ConfigMapName != "camera-payloads" || ref.Key != "Snapshot" {
+		t.Fatalf("expected the snapshot to refer to its ConfigMap, got %+v", ref)
+	}
+	var cm corev1.ConfigMap
+	if err := ds.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: ref.ConfigMapName}, &cm); err != nil {
+		t.Fatalf("failed to get the ConfigMap of the payloads: %v", err)
+	}
+	if !reflect.DeepEqual(cm.BinaryData["Snapshot"], snapshot) {
+		t.Errorf("expected the snapshot to be stored in the ConfigMap, got %v", cm.BinaryData["Snapshot"])
+	}
+	if len(cm.OwnerReferences) != 1 || cm.OwnerReferences[0].Name != "camera" {
+		t.Errorf("expected the ConfigMap to be owned by the device, got %+v", cm.OwnerReferences)
+	}
+
+	// the payload larger than the limit is only reported by its size and hash
+	video := device.Status.DeviceProperties["Video"]
+	if video.Binary == nil || video.Binary.Size != 1024 || video.Binary.PayloadRef != nil {
+		t.Errorf("expected the video not to be stored, got %+v", video.Binary)
+	}
+	if _, exist := cm.BinaryData["Video"]; exist {
+		t.Errorf("expected the video not to be stored in the ConfigMap")
+	}
+}
+
+func TestDeviceSyncerLimitsBinaryPayloads(t *testing.T) {
+	store := fake.NewStore()
+	device := newTestDevice("camera")
+	device.Labels = map[string]string{EdgeXObjectName: "camera"}
+	store.AddDevice(device)
+	store.SetBinaryProperty("camera", "Front", "image/jpeg", make([]byte, 600*1024))
+	store.SetBinaryProperty("camera", "Rear", "image/jpeg", make([]byte, 600*1024))
+	store.SetBinaryProperty("camera", "Snapshot", "image/jpeg", []byte{0xff, 0xd8, 0xff, 0xe0})
+	ds := DeviceSyncer{
+		Client:          newTestClient(t, device),
+		NodePool:        testNodePool,
+		Namespace:       "default",
+		deviceCli:       fake.NewFakeDeviceClient(store),
+		propertyWorkers: 1,
+		payloadMaxSize:  payloadsMaxTotalSize,
+	}
+
+	// the payloads are stored in the order of the property names as long as the ConfigMap is not full
+	devices := map[string]*devicev1alpha1.Device{"camera": device}
+	ds.refreshProperties(context.TODO(), devices)
+	for name, stored := range map[string]bool{"Front": true, "Rear": false, "Snapshot": true} {
+		if ref := device.Status.DeviceProperties[name].Binary.PayloadRef; (ref != nil) != stored {
+			t.Errorf("expected the payload of %s to be stored: %t, got %+v", name, stored, ref)
+		}
+	}
+	var cm corev1.ConfigMap
+	key := types.NamespacedName{Namespace: "default", Name: "camera-payloads"}
+	if err := ds.Get(context.TODO(), key, &cm); err != nil {
+		t.Fatalf("failed to get the ConfigMap of the payloads: %v", err)
+	}
+	if len(cm.BinaryData) != 2 {
+		t.Errorf("expected the payloads of Front and Snapshot to be stored, got %d payloads", len(cm.BinaryData))
+	}
+
+	// the payloads of the properties no longer reported are removed
+	store = fake.NewStore()
+	store.AddDevice(device)
+	store.SetBinaryProperty("camera", "Rear", "image/jpeg", make([]byte, 600*1024))
+	ds.deviceCli = fake.NewFakeDeviceClient(store)
+	ds.refreshProperties(context.TODO(), devices)
+	cm = corev1.ConfigMap{}
+	if err := ds.Get(context.TODO(), key, &cm); err != nil {
+		t.Fatalf("failed to get the ConfigMap of the payloads: %v", err)
+	}
+	if _, exist := cm.BinaryData["Rear"]; len(cm.BinaryData) != 1 || !exist {
+		t.Errorf("expected only the payload of Rear to be stored, got %d payloads", len(cm.BinaryData))
+	}
+}
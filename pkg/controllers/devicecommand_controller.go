@@ -0,0 +1,229 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
+	"github.com/openyurtio/device-controller/pkg/clients"
+	"github.com/openyurtio/device-controller/pkg/controllers/util"
+	"github.com/openyurtio/device-controller/pkg/metrics"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// DeviceCommandReconciler runs a DeviceCommand once on the device it refers to, like a Job, and records the result
+type DeviceCommandReconciler struct {
+	client.Client
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	deviceCli clients.DeviceInterface
+	// which nodePool deviceController is deployed in
+	NodePool string
+}
+
+//+kubebuilder:rbac:groups=device.openyurt.io,resources=devicecommands,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=device.openyurt.io,resources=devicecommands/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=device.openyurt.io,resources=devices,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+func (r *DeviceCommandReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var dc devicev1alpha1.DeviceCommand
+	if err := r.Get(ctx, req.NamespacedName, &dc); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// a command is run at most once
+	if dc.Status.IsCompleted() {
+		return ctrl.Result{}, nil
+	}
+
+	// 1. Resolve the device, the command waits until the device exists and has been added to the edge platform
+	var d devicev1alpha1.Device
+	if err := r.Get(ctx, types.NamespacedName{Namespace: dc.Namespace, Name: dc.Spec.DeviceName}, &d); err != nil {
+		if apierrors.IsNotFound(err) {
+			klog.V(4).Infof("DeviceCommand %s is waiting for device %s", dc.GetName(), dc.Spec.DeviceName)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	// If the device doesn't belong to the Edge platform to which the controller is connected, the controller does not run the command
+	if d.Spec.NodePool != r.NodePool || !d.Status.Synced {
+		return ctrl.Result{}, nil
+	}
+
+	klog.V(3).Infof("Reconciling the DeviceCommand: %s", dc.GetName())
+	// 2. A command found running has been sent by a controller that stopped before recording the result,
+	// it is not sent again since the device may or may not have run it
+	if dc.Status.Phase == devicev1alpha1.DeviceCommandRunning {
+		r.completeCommand(&dc, nil, fmt.Errorf("the controller restarted while the command was running, the command may or may not have been run"))
+		return ctrl.Result{}, r.updateCommandStatus(ctx, &dc)
+	}
+	if err := validateDeviceCommand(&dc); err != nil {
+		conditions.MarkFalse(&dc, devicev1alpha1.DeviceCommandCompletedCondition, "the command is invalid", clusterv1.ConditionSeverityError, err.Error())
+		r.completeCommand(&dc, nil, err)
+		return ctrl.Result{}, r.updateCommandStatus(ctx, &dc)
+	}
+
+	// 3. Record that the command is running before sending it, so that it is never sent twice
+	now := metav1.Now()
+	dc.Status.Phase = devicev1alpha1.DeviceCommandRunning
+	dc.Status.StartTime = &now
+	if err := r.updateCommandStatus(ctx, &dc); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// 4. Run the command on the edge platform and record the result
+	readings, err := r.runCommand(ctx, &dc, &d)
+	var circuitErr *clients.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		// the command has not been sent, it stays pending until the edge platform is available again
+		dc.Status.Phase = devicev1alpha1.DeviceCommandPending
+		dc.Status.StartTime = nil
+		result, _ := handleEdgeError(&dc, ctrl.Result{}, err)
+		return result, r.updateCommandStatus(ctx, &dc)
+	}
+	// the command is not retried, only the EdgeAvailable condition is kept
+	_, _ = handleEdgeError(&dc, ctrl.Result{}, err)
+	if err != nil {
+		conditions.MarkFalse(&dc, devicev1alpha1.DeviceCommandCompletedCondition, edgeErrorReason("failed to run the command on the edge platform", err),
+			clusterv1.ConditionSeverityWarning, err.Error())
+	}
+	r.completeCommand(&dc, readings, err)
+	return ctrl.Result{}, r.updateCommandStatus(ctx, &dc)
+}
+
+// runCommand sends the command to the device within the timeout of the command
+func (r *DeviceCommandReconciler) runCommand(ctx context.Context, dc *devicev1alpha1.DeviceCommand, d *devicev1alpha1.Device) (map[string]string, error) {
+	timeout := devicev1alpha1.DefaultDeviceCommandTimeoutSeconds
+	if dc.Spec.TimeoutSeconds != nil {
+		timeout = *dc.Spec.TimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	klog.V(4).Infof("Running the %s command %s on device %s", dc.Spec.Operation, dc.Spec.Command, d.GetName())
+	if dc.Spec.Operation == devicev1alpha1.CommandOperationSet {
+		return nil, r.deviceCli.SetCommand(ctx, dc.Spec.Command, d, dc.Spec.Parameters, clients.UpdateOptions{})
+	}
+	return r.deviceCli.GetCommand(ctx, dc.Spec.Command, d, clients.GetOptions{})
+}
+
+// completeCommand records the result of the command in its status, the command failed if err is not nil
+func (r *DeviceCommandReconciler) completeCommand(dc *devicev1alpha1.DeviceCommand, readings map[string]string, err error) {
+	now := metav1.Now()
+	if dc.Status.StartTime == nil {
+		dc.Status.StartTime = &now
+	}
+	dc.Status.CompletionTime = &now
+	duration := now.Sub(dc.Status.StartTime.Time)
+	if err != nil {
+		dc.Status.Phase = devicev1alpha1.DeviceCommandFailed
+		dc.Status.Error = err.Error()
+		if !conditions.IsFalse(dc, devicev1alpha1.DeviceCommandCompletedCondition) {
+			conditions.MarkFalse(dc, devicev1alpha1.DeviceCommandCompletedCondition, "the command failed", clusterv1.ConditionSeverityWarning, err.Error())
+		}
+		r.Recorder.Eventf(dc, corev1.EventTypeWarning, EventReasonCommandFailed, "Failed to run the %s command %s on device %s: %v",
+			dc.Spec.Operation, dc.Spec.Command, dc.Spec.DeviceName, err)
+		metrics.ObserveDeviceCommand(string(dc.Spec.Operation), "failed", duration)
+		return
+	}
+	dc.Status.Phase = devicev1alpha1.DeviceCommandSucceeded
+	dc.Status.Readings = readings
+	conditions.MarkTrue(dc, devicev1alpha1.DeviceCommandCompletedCondition)
+	r.Recorder.Eventf(dc, corev1.EventTypeNormal, EventReasonCommandSucceeded, "Ran the %s command %s on device %s in %v",
+		dc.Spec.Operation, dc.Spec.Command, dc.Spec.DeviceName, duration.Round(time.Millisecond))
+	metrics.ObserveDeviceCommand(string(dc.Spec.Operation), "succeeded", duration)
+}
+
+// updateCommandStatus updates the status of the command. A conflict means the command has been updated
+// by another reconciliation, the error is returned so that the command is requeued and reconciled again from
+// its latest state, and a command whose running phase failed to be recorded is never sent
+func (r *DeviceCommandReconciler) updateCommandStatus(ctx context.Context, dc *devicev1alpha1.DeviceCommand) error {
+	if err := r.Status().Update(ctx, dc); err != nil {
+		if apierrors.IsConflict(err) {
+			klog.V(4).Infof("DeviceCommand %s has been modified, requeue it", dc.GetName())
+		}
+		return client.IgnoreNotFound(err)
+	}
+	return nil
+}
+
+// validateDeviceCommand checks the fields that can't be validated by the schema of the deviceCommand
+func validateDeviceCommand(dc *devicev1alpha1.DeviceCommand) error {
+	switch dc.Spec.Operation {
+	case devicev1alpha1.CommandOperationGet:
+		if len(dc.Spec.Parameters) != 0 {
+			return fmt.Errorf("a get command does not take parameters")
+		}
+	case devicev1alpha1.CommandOperationSet:
+		if len(dc.Spec.Parameters) == 0 {
+			return fmt.Errorf("a set command requires parameters")
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", dc.Spec.Operation)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DeviceCommandReconciler) SetupWithManager(mgr ctrl.Manager, driver clients.Driver, opts *options.YurtDeviceControllerOptions) error {
+	var err error
+	if r.deviceCli, err = driver.NewDeviceClient(); err != nil {
+		return err
+	}
+	r.NodePool = opts.Nodepool
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&devicev1alpha1.DeviceCommand{}).
+		Watches(&source.Kind{Type: &devicev1alpha1.Device{}}, handler.EnqueueRequestsFromMapFunc(r.pendingCommandsOfDevice)).
+		Complete(r)
+}
+
+// pendingCommandsOfDevice enqueues the commands waiting for the device to be created or added to the edge platform
+func (r *DeviceCommandReconciler) pendingCommandsOfDevice(obj client.Object) []reconcile.Request {
+	var dcl devicev1alpha1.DeviceCommandList
+	if err := r.List(context.TODO(), &dcl, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{util.IndexerPathForDeviceName: obj.GetName()}); err != nil {
+		klog.V(4).ErrorS(err, "failed to list the deviceCommands of device", "DeviceName", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, dc := range dcl.Items {
+		if dc.Status.Phase == "" || dc.Status.Phase == devicev1alpha1.DeviceCommandPending {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: dc.Namespace, Name: dc.Name}})
+		}
+	}
+	return requests
+}
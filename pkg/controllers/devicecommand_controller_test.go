@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+	"github.com/openyurtio/device-controller/pkg/clients/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestDeviceCommand(name string, operation devicev1alpha1.CommandOperation, parameters map[string]string) *devicev1alpha1.DeviceCommand {
+	return &devicev1alpha1.DeviceCommand{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: devicev1alpha1.DeviceCommandSpec{
+			DeviceName: "random-device",
+			Command:    "Int8",
+			Operation:  operation,
+			Parameters: parameters,
+		},
+	}
+}
+
+// newTestDeviceCommandReconciler sets up the reconciler with a device that has been added to the edge platform
+func newTestDeviceCommandReconciler(t *testing.T, store *fake.Store, objs ...client.Object) *DeviceCommandReconciler {
+	scheme := runtime.NewScheme()
+	if err := devicev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+	device := newTestDevice("random-device")
+	device.Status = *store.AddDevice(device).Status.DeepCopy()
+	return &DeviceCommandReconciler{
+		Client:    fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(append(objs, device)...).Build(),
+		Scheme:    scheme,
+		Recorder:  record.NewFakeRecorder(10),
+		deviceCli: fake.NewFakeDeviceClient(store),
+		NodePool:  testNodePool,
+	}
+}
+
+func reconcileTestDeviceCommand(t *testing.T, r *DeviceCommandReconciler, name string) (ctrl.Result, *devicev1alpha1.DeviceCommand) {
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("failed to reconcile deviceCommand %s: %v", name, err)
+	}
+	var dc devicev1alpha1.DeviceCommand
+	if err := r.Get(context.TODO(), key, &dc); err != nil {
+		t.Fatalf("failed to get deviceCommand %s: %v", name, err)
+	}
+	return result, &dc
+}
+
+func TestDeviceCommandReconcilerRunsCommandOnce(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceCommandReconciler(t, store,
+		newTestDeviceCommand("set-int8", devicev1alpha1.CommandOperationSet, map[string]string{"Int8": "42"}),
+		newTestDeviceCommand("get-int8", devicev1alpha1.CommandOperationGet, nil))
+
+	_, dc := reconcileTestDeviceCommand(t, r, "set-int8")
+	if dc.Status.Phase != devicev1alpha1.DeviceCommandSucceeded || dc.Status.StartTime == nil || dc.Status.CompletionTime == nil {
+		t.Errorf("expected the set command to succeed with its start and completion time, got status %+v", dc.Status)
+	}
+	if v, _ := store.GetProperty("random-device", "Int8"); v != "42" {
+		t.Errorf("expected Int8 to be set to 42 on the edge platform, got %q", v)
+	}
+
+	_, dc = reconcileTestDeviceCommand(t, r, "get-int8")
+	if dc.Status.Phase != devicev1alpha1.DeviceCommandSucceeded || dc.Status.Readings["Int8"] != "42" {
+		t.Errorf("expected the get command to read 42, got status %+v", dc.Status)
+	}
+	if !conditions.IsTrue(dc, devicev1alpha1.DeviceCommandCompletedCondition) {
+		t.Errorf("expected condition %s to be true", devicev1alpha1.DeviceCommandCompletedCondition)
+	}
+
+	// a completed command is never run again
+	store.SetProperty("random-device", "Int8", "1")
+	_, dc = reconcileTestDeviceCommand(t, r, "get-int8")
+	if dc.Status.Readings["Int8"] != "42" {
+		t.Errorf("expected the readings of the completed command to be kept, got %+v", dc.Status.Readings)
+	}
+}
+
+func TestDeviceCommandReconcilerReportsFailures(t *testing.T) {
+	store := fake.NewStore()
+	interrupted := newTestDeviceCommand("interrupted", devicev1alpha1.CommandOperationSet, map[string]string{"Int8": "42"})
+	interrupted.Status.Phase = devicev1alpha1.DeviceCommandRunning
+	interrupted.Status.StartTime = &metav1.Time{Time: time.Now()}
+	r := newTestDeviceCommandReconciler(t, store, interrupted,
+		newTestDeviceCommand("invalid", devicev1alpha1.CommandOperationSet, nil),
+		newTestDeviceCommand("locked", devicev1alpha1.CommandOperationGet, nil))
+	store.SetProperty("random-device", "Int8", "1")
+
+	// a command interrupted by a restart of the controller is not sent again
+	_, dc := reconcileTestDeviceCommand(t, r, "interrupted")
+	if dc.Status.Phase != devicev1alpha1.DeviceCommandFailed || dc.Status.Error == "" {
+		t.Errorf("expected the interrupted command to fail, got status %+v", dc.Status)
+	}
+	if v, _ := store.GetProperty("random-device", "Int8"); v != "1" {
+		t.Errorf("expected Int8 to stay 1 on the edge platform, got %q", v)
+	}
+
+	_, dc = reconcileTestDeviceCommand(t, r, "invalid")
+	if reason := conditions.GetReason(dc, devicev1alpha1.DeviceCommandCompletedCondition); dc.Status.Phase != devicev1alpha1.DeviceCommandFailed || reason != "the command is invalid" {
+		t.Errorf("expected the set command without parameters to be invalid, got status %+v", dc.Status)
+	}
+
+	store.InjectError(fake.GetCommandVerb, fake.DeviceKind, "random-device", fake.ErrLocked)
+	_, dc = reconcileTestDeviceCommand(t, r, "locked")
+	if reason := conditions.GetReason(dc, devicev1alpha1.DeviceCommandCompletedCondition); reason != "failed to run the command on the edge platform (locked)" {
+		t.Errorf("unexpected reason of condition %s: %q", devicev1alpha1.DeviceCommandCompletedCondition, reason)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeWarning, EventReasonCommandFailed)
+}
+
+func TestDeviceCommandReconcilerWaitsForEdgePlatform(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceCommandReconciler(t, store, newTestDeviceCommand("get-int8", devicev1alpha1.CommandOperationGet, nil))
+	store.SetProperty("random-device", "Int8", "1")
+
+	// refused by an open circuit breaker, the command has not been sent and stays pending
+	store.InjectError(fake.GetCommandVerb, fake.DeviceKind, "", &clients.CircuitOpenError{Endpoint: "edgex-core-command:59882", RetryAfter: 10 * time.Second})
+	result, dc := reconcileTestDeviceCommand(t, r, "get-int8")
+	if result.RequeueAfter != 10*time.Second || dc.Status.Phase != devicev1alpha1.DeviceCommandPending || dc.Status.StartTime != nil {
+		t.Errorf("expected the command to be pending and requeued after 10s, got %+v, status %+v", result, dc.Status)
+	}
+
+	store.ClearErrors()
+	_, dc = reconcileTestDeviceCommand(t, r, "get-int8")
+	if dc.Status.Phase != devicev1alpha1.DeviceCommandSucceeded || !conditions.IsTrue(dc, devicev1alpha1.EdgeAvailableCondition) {
+		t.Errorf("expected the command to succeed once the edge platform is available, got status %+v", dc.Status)
+	}
+}
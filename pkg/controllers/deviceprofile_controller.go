@@ -20,17 +20,22 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
 	"github.com/openyurtio/device-controller/pkg/clients"
-	edgexclis "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
 	"github.com/openyurtio/device-controller/pkg/controllers/util"
 
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -39,8 +44,11 @@ import (
 type DeviceProfileReconciler struct {
 	client.Client
 	Scheme     *runtime.Scheme
+	Recorder   record.EventRecorder
 	edgeClient clients.DeviceProfileInterface
 	NodePool   string
+	// the deletion policy of the deviceProfiles which do not set their own
+	defaultDeletionPolicy devicev1alpha1.DeletionPolicy
 }
 
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=deviceprofiles,verbs=get;list;watch;create;update;patch;delete
@@ -48,7 +56,7 @@ type DeviceProfileReconciler struct {
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=deviceprofiles/finalizers,verbs=update
 
 // Reconcile make changes to a deviceprofile object in EdgeX based on it in Kubernetes
-func (r *DeviceProfileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *DeviceProfileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	var dp devicev1alpha1.DeviceProfile
 	if err := r.Get(ctx, req.NamespacedName, &dp); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -57,6 +65,19 @@ func (r *DeviceProfileReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, nil
 	}
 	klog.V(3).Infof("Reconciling the DeviceProfile: %s", dp.GetName())
+	// Update the conditions for deviceProfile
+	defer func() {
+		result, err = handleEdgeError(&dp, result, err)
+		conditions.SetSummary(&dp,
+			conditions.WithConditions(devicev1alpha1.DeviceProfileSyncedCondition, devicev1alpha1.DeviceProfileUpdatedCondition, devicev1alpha1.EdgeAvailableCondition),
+		)
+		updateErr := r.Status().Update(ctx, &dp)
+		if client.IgnoreNotFound(updateErr) != nil {
+			if !apierrors.IsConflict(updateErr) {
+				klog.V(4).ErrorS(updateErr, "update deviceProfile conditions failed", "DeviceProfileName", dp.GetName())
+			}
+		}
+	}()
 
 	// gets the actual name of deviceProfile on the edge platform from the Label of the deviceProfile
 	dpActualName := util.GetEdgeDeviceProfileName(&dp, EdgeXObjectName)
@@ -77,17 +98,26 @@ func (r *DeviceProfileReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 				return ctrl.Result{}, err
 			}
 		}
+	} else {
+		// 3. Handle the deviceProfile update event
+		if err := r.reconcileUpdateDeviceProfile(ctx, &dp, dpActualName); err != nil {
+			if apierrors.IsConflict(err) {
+				return ctrl.Result{Requeue: true}, nil
+			}
+			return ctrl.Result{}, err
+		}
 	}
-	// 3. Handle the deviceProfile update event
-	// TODO
-
 	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
-func (r *DeviceProfileReconciler) SetupWithManager(mgr ctrl.Manager, opts *options.YurtDeviceControllerOptions) error {
-	r.edgeClient = edgexclis.NewEdgexDeviceProfile(opts.CoreMetadataAddr)
+func (r *DeviceProfileReconciler) SetupWithManager(mgr ctrl.Manager, driver clients.Driver, opts *options.YurtDeviceControllerOptions) error {
+	var err error
+	if r.edgeClient, err = driver.NewDeviceProfileClient(); err != nil {
+		return err
+	}
 	r.NodePool = opts.Nodepool
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicy(opts.DeletionPolicy)
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&devicev1alpha1.DeviceProfile{}).
@@ -111,53 +141,216 @@ func (r *DeviceProfileReconciler) reconcileDeleteDeviceProfile(ctx context.Conte
 				}
 			}
 		}
-	} else {
-		patchString := map[string]interface{}{
-			"metadata": map[string]interface{}{
-				"finalizers": []string{},
-			},
-		}
-		// delete the deviceProfile in OpenYurt
-		if patchData, err := json.Marshal(patchString); err != nil {
+	} else if effectiveDeletionPolicy(dp.Spec.DeletionPolicy, r.defaultDeletionPolicy) == devicev1alpha1.DeletionPolicyOrphan {
+		// leave the deviceProfile on the edge platform and label it, so that the syncer does not import it again
+		err := r.orphanEdgeDeviceProfile(ctx, actualName)
+		if err != nil && !clients.IsNotFoundErr(err) {
+			r.Recorder.Eventf(dp, corev1.EventTypeWarning, EventReasonOrphanOnEdgeFailed, "Failed to label deviceProfile %s as orphaned on the edge platform: %v", actualName, err)
 			return err
-		} else {
-			if err = r.Patch(ctx, dp, client.RawPatch(types.MergePatchType, patchData)); err != nil {
-				return err
-			}
 		}
-
+		r.Recorder.Eventf(dp, corev1.EventTypeNormal, EventReasonOrphanedOnEdge, "Left deviceProfile %s on the edge platform as orphaned", actualName)
+		return r.removeDeviceProfileFinalizers(ctx, dp)
+	} else {
 		// delete the deviceProfile object on edge platform
-		err := r.edgeClient.Delete(nil, actualName, clients.DeleteOptions{})
+		err := r.edgeClient.Delete(ctx, actualName, clients.DeleteOptions{})
 		if err != nil && !clients.IsNotFoundErr(err) {
+			r.Recorder.Eventf(dp, corev1.EventTypeWarning, EventReasonDeleteOnEdgeFailed, "Failed to delete deviceProfile %s from the edge platform: %v", actualName, err)
 			return err
 		}
+		r.Recorder.Eventf(dp, corev1.EventTypeNormal, EventReasonDeletedOnEdge, "Deleted deviceProfile %s from the edge platform", actualName)
+		return r.removeDeviceProfileFinalizers(ctx, dp)
 	}
 	return nil
 }
 
+// removeDeviceProfileFinalizers removes the finalizers of the deviceProfile, so that it is deleted in OpenYurt
+func (r *DeviceProfileReconciler) removeDeviceProfileFinalizers(ctx context.Context, dp *devicev1alpha1.DeviceProfile) error {
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers": []string{},
+		},
+	})
+	return r.Patch(ctx, dp, client.RawPatch(types.MergePatchType, patchData))
+}
+
+// orphanEdgeDeviceProfile adds the orphaned label to the deviceProfile on the edge platform
+func (r *DeviceProfileReconciler) orphanEdgeDeviceProfile(ctx context.Context, actualName string) error {
+	edgeDp, err := r.edgeClient.Get(ctx, actualName, clients.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if isOrphaned(edgeDp.Spec.Labels) {
+		return nil
+	}
+	edgeDp.Spec.Labels = withOrphanedLabel(edgeDp.Spec.Labels)
+	_, err = r.edgeClient.Update(ctx, edgeDp, clients.UpdateOptions{Fields: []string{"labels"}})
+	return err
+}
+
 func (r *DeviceProfileReconciler) reconcileCreateDeviceProfile(ctx context.Context, dp *devicev1alpha1.DeviceProfile, actualName string) error {
 	klog.V(4).Infof("Checking if deviceProfile already exist on the edge platform: %s", dp.GetName())
-	if edgeDp, err := r.edgeClient.Get(nil, actualName, clients.GetOptions{}); err != nil {
+	if edgeDp, err := r.edgeClient.Get(ctx, actualName, clients.GetOptions{}); err != nil {
 		if !clients.IsNotFoundErr(err) {
 			klog.V(4).ErrorS(err, "fail to visit the edge platform")
-			return nil
+			return err
 		}
 	} else {
 		// a. If object exists, the status of the deviceProfile on OpenYurt is updated
 		klog.V(4).Info("DeviceProfile already exists on edge platform")
+		if isOrphaned(edgeDp.Spec.Labels) {
+			// the deviceProfile left on the edge platform by a deleted deviceProfile is adopted, so it is no longer orphaned
+			edgeDp.Spec.Labels = withoutOrphanedLabel(edgeDp.Spec.Labels)
+			if _, err := r.edgeClient.Update(ctx, edgeDp, clients.UpdateOptions{Fields: []string{"labels"}}); err != nil {
+				conditions.MarkFalse(dp, devicev1alpha1.DeviceProfileSyncedCondition, edgeErrorReason("failed to adopt the orphaned DeviceProfile on EdgeX", err), clusterv1.ConditionSeverityWarning, err.Error())
+				return fmt.Errorf("failed to adopt the orphaned deviceProfile on edge platform: %w", err)
+			}
+		}
 		dp.Status.Synced = true
 		dp.Status.EdgeId = edgeDp.Status.EdgeId
+		conditions.MarkTrue(dp, devicev1alpha1.DeviceProfileSyncedCondition)
+		// the spec on the edge platform is the one synced, the fields which differ on OpenYurt are updated next
+		if err := r.recordSyncedSpec(ctx, dp, edgeDp.Spec); err != nil {
+			return err
+		}
 		return r.Status().Update(ctx, dp)
 	}
 
 	// b. If object does not exist, a request is sent to the edge platform to create a new deviceProfile
-	createDp, err := r.edgeClient.Create(context.Background(), dp, clients.CreateOptions{})
+	createDp, err := r.edgeClient.Create(ctx, dp, clients.CreateOptions{})
 	if err != nil {
 		klog.V(4).ErrorS(err, "failed to create deviceProfile on edge platform")
-		return fmt.Errorf("failed to add deviceProfile to edge platform: %v", err)
+		conditions.MarkFalse(dp, devicev1alpha1.DeviceProfileSyncedCondition, edgeErrorReason("failed to add DeviceProfile to EdgeX", err), clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(dp, corev1.EventTypeWarning, EventReasonCreateOnEdgeFailed, "Failed to add deviceProfile %s to the edge platform: %v", actualName, err)
+		return fmt.Errorf("failed to add deviceProfile to edge platform: %w", err)
 	}
 	klog.V(3).Infof("Successfully add DeviceProfile to edge platform, Name: %s, EdgeId: %s", createDp.GetName(), createDp.Status.EdgeId)
+	r.Recorder.Eventf(dp, corev1.EventTypeNormal, EventReasonCreatedOnEdge, "Added deviceProfile %s to the edge platform, EdgeId: %s", actualName, createDp.Status.EdgeId)
 	dp.Status.EdgeId = createDp.Status.EdgeId
 	dp.Status.Synced = true
+	conditions.MarkTrue(dp, devicev1alpha1.DeviceProfileSyncedCondition)
+	if err := r.recordSyncedSpec(ctx, dp, dp.Spec); err != nil {
+		return err
+	}
 	return r.Status().Update(ctx, dp)
 }
+
+// reconcileUpdateDeviceProfile applies the changes of the deviceProfile on OpenYurt to the edge platform.
+// The spec last synced is used to find out what has changed, so that the changes made on the edge platform
+// to the other fields are kept.
+func (r *DeviceProfileReconciler) reconcileUpdateDeviceProfile(ctx context.Context, dp *devicev1alpha1.DeviceProfile, actualName string) error {
+	syncedDp, ok := lastSyncedDeviceProfile(dp)
+	if !ok {
+		// the deviceProfile was synced before its spec was recorded, the current spec is taken as synced
+		conditions.MarkTrue(dp, devicev1alpha1.DeviceProfileUpdatedCondition)
+		return r.recordSyncedSpec(ctx, dp, dp.Spec)
+	}
+	changedFields := findDeviceProfileDiff(dp, syncedDp)
+	if len(changedFields) == 0 {
+		conditions.MarkTrue(dp, devicev1alpha1.DeviceProfileUpdatedCondition)
+		return nil
+	}
+	klog.V(4).Infof("DeviceProfileName: %s, fields %v have changed, updating the deviceProfile on edge platform", dp.GetName(), changedFields)
+	if _, err := r.edgeClient.Update(ctx, dp, clients.UpdateOptions{Fields: changedFields}); err != nil {
+		if clients.IsNotFoundErr(err) {
+			// the syncer will clean up the deviceProfile which has been deleted on the edge platform
+			return nil
+		}
+		// e.g. EdgeX refuses to remove a deviceResource which is still used by devices
+		klog.V(4).ErrorS(err, "failed to update deviceProfile on edge platform", "DeviceProfileName", dp.GetName())
+		conditions.MarkFalse(dp, devicev1alpha1.DeviceProfileUpdatedCondition, edgeErrorReason("failed to update DeviceProfile on EdgeX", err), clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(dp, corev1.EventTypeWarning, EventReasonUpdateOnEdgeFailed, "Failed to update fields %v of deviceProfile %s on the edge platform: %v", changedFields, actualName, err)
+		return fmt.Errorf("failed to update deviceProfile on edge platform: %w", err)
+	}
+	klog.V(3).Infof("Successfully update DeviceProfile on edge platform, Name: %s", dp.GetName())
+	r.Recorder.Eventf(dp, corev1.EventTypeNormal, EventReasonUpdatedOnEdge, "Updated fields %v of deviceProfile %s on the edge platform", changedFields, actualName)
+	conditions.MarkTrue(dp, devicev1alpha1.DeviceProfileUpdatedCondition)
+	return r.recordSyncedSpec(ctx, dp, dp.Spec)
+}
+
+// lastSyncedDeviceProfile returns the deviceProfile with the spec recorded by LastSyncedSpecAnnotation,
+// ok is false if there is no such record
+func lastSyncedDeviceProfile(dp *devicev1alpha1.DeviceProfile) (*devicev1alpha1.DeviceProfile, bool) {
+	recorded, ok := dp.Annotations[LastSyncedSpecAnnotation]
+	if !ok {
+		return nil, false
+	}
+	var syncedDp devicev1alpha1.DeviceProfile
+	if err := json.Unmarshal([]byte(recorded), &syncedDp.Spec); err != nil {
+		klog.V(3).ErrorS(err, "fail to parse the last synced spec of deviceProfile", "DeviceProfileName", dp.GetName())
+		return nil, false
+	}
+	return &syncedDp, true
+}
+
+// recordSyncedSpec records the spec synced to the edge platform in LastSyncedSpecAnnotation of the deviceProfile
+func (r *DeviceProfileReconciler) recordSyncedSpec(ctx context.Context, dp *devicev1alpha1.DeviceProfile, syncedSpec devicev1alpha1.DeviceProfileSpec) error {
+	spec, err := json.Marshal(syncedSpec)
+	if err != nil {
+		return err
+	}
+	if dp.Annotations[LastSyncedSpecAnnotation] == string(spec) {
+		return nil
+	}
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{LastSyncedSpecAnnotation: string(spec)},
+		},
+	})
+	// the status is updated once the reconciliation completes, so only the annotation is patched
+	status := dp.Status.DeepCopy()
+	err = r.Patch(ctx, dp, client.RawPatch(types.MergePatchType, patchData))
+	dp.Status = *status
+	return err
+}
+
+// findDeviceProfileDiff returns the fields of the spec that differ between the deviceProfile on OpenYurt and the one last synced
+func findDeviceProfileDiff(kubeDp, edgeDp *devicev1alpha1.DeviceProfile) []string {
+	var fields []string
+	if kubeDp.Spec.Description != edgeDp.Spec.Description {
+		fields = append(fields, "description")
+	}
+	if kubeDp.Spec.Manufacturer != edgeDp.Spec.Manufacturer {
+		fields = append(fields, "manufacturer")
+	}
+	if kubeDp.Spec.Model != edgeDp.Spec.Model {
+		fields = append(fields, "model")
+	}
+	if !apiequality.Semantic.DeepEqual(kubeDp.Spec.Labels, edgeDp.Spec.Labels) {
+		fields = append(fields, "labels")
+	}
+	if !deviceResourcesEqual(kubeDp.Spec.DeviceResources, edgeDp.Spec.DeviceResources) {
+		fields = append(fields, "deviceResources")
+	}
+	if !apiequality.Semantic.DeepEqual(kubeDp.Spec.DeviceCommands, edgeDp.Spec.DeviceCommands) {
+		fields = append(fields, "deviceCommands")
+	}
+	return fields
+}
+
+// deviceResourcesEqual compares the deviceResources with their attributes normalized, since the attributes
+// are typed on the edge platform, e.g. 1, while they are strings on OpenYurt, e.g. "1.0"
+func deviceResourcesEqual(kubeDrs, edgeDrs []devicev1alpha1.DeviceResource) bool {
+	return apiequality.Semantic.DeepEqual(normalizeDeviceResources(kubeDrs), normalizeDeviceResources(edgeDrs))
+}
+
+func normalizeDeviceResources(drs []devicev1alpha1.DeviceResource) []devicev1alpha1.DeviceResource {
+	ret := make([]devicev1alpha1.DeviceResource, len(drs))
+	for i := range drs {
+		drs[i].DeepCopyInto(&ret[i])
+		for k, v := range ret[i].Attributes {
+			ret[i].Attributes[k] = normalizeAttribute(v)
+		}
+	}
+	return ret
+}
+
+// normalizeAttribute formats the numbers and the booleans of the attributes in the same way
+func normalizeAttribute(v string) string {
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	if b, err := strconv.ParseBool(v); err == nil {
+		return strconv.FormatBool(b)
+	}
+	return v
+}
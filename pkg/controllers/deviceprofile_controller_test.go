@@ -0,0 +1,245 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+	edgex "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
+	"github.com/openyurtio/device-controller/pkg/clients/edgex-foundry/edgextest"
+	"github.com/openyurtio/device-controller/pkg/clients/fake"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestDeviceProfile(name string) *devicev1alpha1.DeviceProfile {
+	return &devicev1alpha1.DeviceProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  "default",
+			Finalizers: []string{devicev1alpha1.DeviceProfileFinalizer},
+		},
+		Spec: devicev1alpha1.DeviceProfileSpec{
+			NodePool:     testNodePool,
+			Manufacturer: "IOTech",
+			DeviceResources: []devicev1alpha1.DeviceResource{{
+				Name:       "Int8",
+				Properties: devicev1alpha1.ResourceProperties{ValueType: "Int8", ReadWrite: "RW"},
+			}},
+		},
+	}
+}
+
+func newTestDeviceProfileReconciler(t *testing.T, store *fake.Store, objs ...client.Object) *DeviceProfileReconciler {
+	c := newTestClient(t, objs...)
+	return &DeviceProfileReconciler{
+		Client:     c,
+		Scheme:     c.Scheme(),
+		Recorder:   record.NewFakeRecorder(10),
+		edgeClient: fake.NewFakeDeviceProfileClient(store),
+		NodePool:   testNodePool,
+	}
+}
+
+// deleteTestDeviceProfile deletes the synced deviceProfile from OpenYurt and reconciles the deletion
+func deleteTestDeviceProfile(t *testing.T, r *DeviceProfileReconciler, name string) error {
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile deviceProfile %s: %v", name, err)
+	}
+	var dp devicev1alpha1.DeviceProfile
+	if err := r.Get(context.TODO(), key, &dp); err != nil {
+		t.Fatalf("failed to get deviceProfile %s: %v", name, err)
+	}
+	if err := r.Delete(context.TODO(), &dp); err != nil {
+		t.Fatalf("failed to delete deviceProfile %s: %v", name, err)
+	}
+	_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+	return err
+}
+
+func TestDeviceProfileReconcilerDeletesEdgeDeviceProfile(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceProfileReconciler(t, store, newTestDeviceProfile("random-integer-device"))
+	edgeCli := fake.NewFakeDeviceProfileClient(store)
+	key := types.NamespacedName{Namespace: "default", Name: "random-integer-device"}
+
+	store.InjectError(fake.DeleteVerb, fake.DeviceProfileKind, "random-integer-device", errors.New("connection refused"))
+	if err := deleteTestDeviceProfile(t, r, "random-integer-device"); err == nil {
+		t.Errorf("expected the failed deletion on the edge platform to be retried")
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeWarning, EventReasonDeleteOnEdgeFailed)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceProfile{}); err != nil {
+		t.Errorf("expected the deviceProfile to be kept in OpenYurt until it is deleted on the edge platform, got %v", err)
+	}
+
+	store.ClearErrors()
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile the deleted deviceProfile: %v", err)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonDeletedOnEdge)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceProfile{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the deviceProfile to be deleted from OpenYurt, got %v", err)
+	}
+	if _, err := edgeCli.Get(context.TODO(), "random-integer-device", clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Errorf("expected the deviceProfile to be deleted from the edge platform, got %v", err)
+	}
+}
+
+func TestDeviceProfileReconcilerOrphansEdgeDeviceProfile(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceProfileReconciler(t, store, newTestDeviceProfile("random-integer-device"))
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicyOrphan
+	edgeCli := fake.NewFakeDeviceProfileClient(store)
+	key := types.NamespacedName{Namespace: "default", Name: "random-integer-device"}
+
+	// the deviceProfile is kept until it is labelled as orphaned, otherwise the syncer would import it again
+	store.InjectError(fake.UpdateVerb, fake.DeviceProfileKind, "random-integer-device", errors.New("connection refused"))
+	if err := deleteTestDeviceProfile(t, r, "random-integer-device"); err == nil {
+		t.Errorf("expected the failed orphaning on the edge platform to be retried")
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeWarning, EventReasonOrphanOnEdgeFailed)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceProfile{}); err != nil {
+		t.Errorf("expected the deviceProfile to be kept in OpenYurt until it is orphaned, got %v", err)
+	}
+
+	store.ClearErrors()
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile the deleted deviceProfile: %v", err)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonOrphanedOnEdge)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceProfile{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the deviceProfile to be deleted from OpenYurt, got %v", err)
+	}
+	edp, err := edgeCli.Get(context.TODO(), "random-integer-device", clients.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the deviceProfile to be left on the edge platform: %v", err)
+	}
+	if !isOrphaned(edp.Spec.Labels) {
+		t.Errorf("expected the edge deviceProfile to be labelled as orphaned, got labels %v", edp.Spec.Labels)
+	}
+}
+
+func TestDeviceProfileReconcilerOrphansEdgeXDeviceProfile(t *testing.T) {
+	s := edgextest.NewServer()
+	defer s.Close()
+	s.AddDeviceProfile(dtos.DeviceProfile{
+		Name:         "random-integer-device",
+		Manufacturer: "IOTech",
+		Labels:       []string{"random"},
+		DeviceResources: []dtos.DeviceResource{{
+			Name:       "Int8",
+			Properties: dtos.ResourceProperties{ValueType: "Int8", ReadWrite: "RW"},
+			Attributes: map[string]interface{}{"startingAddress": 1.0, "isByteSwap": true},
+		}},
+	})
+	r := newTestDeviceProfileReconciler(t, fake.NewStore(), newTestDeviceProfile("random-integer-device"))
+	r.edgeClient = edgex.NewEdgexDeviceProfile(s.MetadataAddr())
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicyOrphan
+
+	// only the labels of the deviceProfile are changed on EdgeX, the attributes keep their types
+	if err := deleteTestDeviceProfile(t, r, "random-integer-device"); err != nil {
+		t.Fatalf("failed to orphan the deviceProfile: %v", err)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonOrphanedOnEdge)
+	edp, exist := s.DeviceProfile("random-integer-device")
+	if !exist {
+		t.Fatalf("expected the deviceProfile to be left on EdgeX")
+	}
+	if !isOrphaned(edp.Labels) {
+		t.Errorf("expected the deviceProfile to be labelled as orphaned on EdgeX, got labels %v", edp.Labels)
+	}
+	attrs := edp.DeviceResources[0].Attributes
+	if attrs["startingAddress"] != 1.0 || attrs["isByteSwap"] != true {
+		t.Errorf("expected the attributes to be kept as they are on EdgeX, got %v", attrs)
+	}
+}
+
+func TestDeviceProfileReconcilerUpdatesChangedDeviceProfile(t *testing.T) {
+	s := edgextest.NewServer()
+	defer s.Close()
+	// the attributes are typed on EdgeX
+	edgeDp := dtos.DeviceProfile{
+		Name:         "modbus-device",
+		Manufacturer: "IOTech",
+		DeviceResources: []dtos.DeviceResource{{
+			Name:       "Temperature",
+			Properties: dtos.ResourceProperties{ValueType: "Float32", ReadWrite: "R"},
+			Attributes: map[string]interface{}{"primaryTable": "HOLDING_REGISTERS", "startingAddress": 1, "isByteSwap": true, "scale": 0.5},
+		}},
+	}
+	edgeDp.Id = s.AddDeviceProfile(edgeDp)
+	dp := newTestDeviceProfile("modbus-device")
+	dp.Spec.DeviceResources = []devicev1alpha1.DeviceResource{{
+		Name:       "Temperature",
+		Properties: devicev1alpha1.ResourceProperties{ValueType: "Float32", ReadWrite: "R"},
+		Attributes: map[string]string{"primaryTable": "HOLDING_REGISTERS", "startingAddress": "1.000000", "isByteSwap": "true", "scale": "0.50"},
+	}}
+	r := newTestDeviceProfileReconciler(t, fake.NewStore(), dp)
+	r.edgeClient = edgex.NewEdgexDeviceProfile(s.MetadataAddr())
+	key := types.NamespacedName{Namespace: "default", Name: "modbus-device"}
+	reconcile := func() {
+		t.Helper()
+		if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("failed to reconcile the deviceProfile: %v", err)
+		}
+		if err := r.Get(context.TODO(), key, dp); err != nil {
+			t.Fatalf("failed to get the deviceProfile: %v", err)
+		}
+	}
+
+	// the deviceProfile existing on EdgeX is adopted, it is not sent since it has not changed on OpenYurt
+	reconcile()
+	requests := s.RequestCount()
+	reconcile()
+	if n := s.RequestCount() - requests; n != 0 {
+		t.Errorf("expected the unchanged deviceProfile not to be sent, got %d requests", n)
+	}
+
+	// only the field changed on OpenYurt is sent, and only once, the description changed on EdgeX is kept
+	edgeDp.Description = "changed on EdgeX"
+	s.AddDeviceProfile(edgeDp)
+	dp.Spec.DeviceResources[0].Attributes["startingAddress"] = "2"
+	if err := r.Update(context.TODO(), dp); err != nil {
+		t.Fatalf("failed to update the deviceProfile: %v", err)
+	}
+	reconcile()
+	reconcile()
+	if n := s.RequestCount() - requests; n != 2 {
+		t.Errorf("expected the changed deviceProfile to be got and sent once, got %d requests in 2 reconciles", n)
+	}
+	edp, _ := s.DeviceProfile("modbus-device")
+	if v := edp.DeviceResources[0].Attributes["startingAddress"]; v != "2" {
+		t.Errorf("expected the attribute to be updated on EdgeX, got %v", v)
+	}
+	if edp.Description != "changed on EdgeX" {
+		t.Errorf("expected the description changed on EdgeX to be kept, got %q", edp.Description)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonUpdatedOnEdge)
+}
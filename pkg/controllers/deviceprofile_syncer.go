@@ -24,10 +24,12 @@ import (
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
 	devcli "github.com/openyurtio/device-controller/pkg/clients"
-	edgexclis "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
 	"github.com/openyurtio/device-controller/pkg/controllers/util"
+	"github.com/openyurtio/device-controller/pkg/metrics"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
@@ -36,8 +38,13 @@ import (
 type DeviceProfileSyncer struct {
 	// syncing period in seconds
 	syncPeriod time.Duration
+	// syncing period when the changes are subscribed
+	resyncPeriod time.Duration
 	// edge platform client
 	edgeClient devcli.DeviceProfileInterface
+	// the edge events of the deviceProfiles, nil if the changes are not subscribed
+	events   *edgeEventSubscription
+	recorder record.EventRecorder
 	// Kubernetes client
 	client.Client
 	NodePool  string
@@ -45,13 +52,20 @@ type DeviceProfileSyncer struct {
 }
 
 // NewDeviceProfileSyncer initialize a New DeviceProfileSyncer
-func NewDeviceProfileSyncer(client client.Client, opts *options.YurtDeviceControllerOptions) (DeviceProfileSyncer, error) {
+func NewDeviceProfileSyncer(client client.Client, recorder record.EventRecorder, driver devcli.Driver, bus *EdgeEventBus, opts *options.YurtDeviceControllerOptions) (DeviceProfileSyncer, error) {
+	edgeClient, err := driver.NewDeviceProfileClient()
+	if err != nil {
+		return DeviceProfileSyncer{}, err
+	}
 	return DeviceProfileSyncer{
-		syncPeriod: time.Duration(opts.EdgeSyncPeriod) * time.Second,
-		edgeClient: edgexclis.NewEdgexDeviceProfile(opts.CoreMetadataAddr),
-		Client:     client,
-		NodePool:   opts.Nodepool,
-		Namespace:  opts.Namespace,
+		syncPeriod:   time.Duration(opts.EdgeSyncPeriod) * time.Second,
+		resyncPeriod: time.Duration(opts.EdgeResyncPeriod) * time.Second,
+		edgeClient:   edgeClient,
+		events:       bus.subscribe(devcli.DeviceProfileEventKind),
+		recorder:     recorder,
+		Client:       client,
+		NodePool:     opts.Nodepool,
+		Namespace:    opts.Namespace,
 	}, nil
 }
 
@@ -65,25 +79,44 @@ func (dps *DeviceProfileSyncer) NewDeviceProfileSyncerRunnable() ctrlmgr.Runnabl
 
 func (dps *DeviceProfileSyncer) Run(stop <-chan struct{}) {
 	klog.V(1).Info("[DeviceProfile] Starting the syncer...")
+	events, overflow, period := dps.events.wait(stop, dps.syncPeriod, dps.resyncPeriod)
+	// abort the in-flight requests to the edge platform once stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
+		timer := time.NewTimer(period)
+		defer timer.Stop()
 		for {
-			<-time.After(dps.syncPeriod)
+			select {
+			case <-stop:
+				return
+			case e := <-events:
+				dps.handleEdgeEvent(ctx, e)
+				continue
+			case <-overflow:
+			case <-timer.C:
+				timer.Reset(period)
+			}
 			klog.V(2).Info("[DeviceProfile] Start a round of synchronization.")
+			start := time.Now()
 
 			// 1. get deviceProfiles on edge platform and OpenYurt
-			edgeDeviceProfiles, kubeDeviceProfiles, err := dps.getAllDeviceProfiles()
+			edgeDeviceProfiles, kubeDeviceProfiles, err := dps.getAllDeviceProfiles(ctx)
 			if err != nil {
 				klog.V(3).ErrorS(err, "fail to list the deviceProfiles")
+				metrics.ObserveSyncRound(metrics.KindDeviceProfile, dps.NodePool, start, true)
 				continue
 			}
 
 			// 2. find the deviceProfiles that need to be synchronized
 			redundantEdgeDeviceProfiles, redundantKubeDeviceProfiles, syncedDeviceProfiles :=
 				dps.findDiffDeviceProfiles(edgeDeviceProfiles, kubeDeviceProfiles)
+			dps.confirmRedundantKubeDeviceProfiles(ctx, redundantKubeDeviceProfiles)
 			klog.V(2).Infof("[DeviceProfile] The number of objects waiting for synchronization { %s:%d, %s:%d, %s:%d }",
 				"Edge deviceProfiles should be added to OpenYurt", len(redundantEdgeDeviceProfiles),
 				"OpenYurt deviceProfiles that should be deleted", len(redundantKubeDeviceProfiles),
 				"DeviceProfiles that should be synchronized", len(syncedDeviceProfiles))
+			metrics.SetSyncObjects(metrics.KindDeviceProfile, dps.NodePool, len(redundantEdgeDeviceProfiles), len(redundantKubeDeviceProfiles), len(syncedDeviceProfiles))
 
 			// 3. create deviceProfiles on OpenYurt which are exists in edge platform but not in OpenYurt
 			if err := dps.syncEdgeToKube(redundantEdgeDeviceProfiles); err != nil {
@@ -97,6 +130,8 @@ func (dps *DeviceProfileSyncer) Run(stop <-chan struct{}) {
 
 			// 5. update deviceProfiles on OpenYurt
 			// TODO
+
+			metrics.ObserveSyncRound(metrics.KindDeviceProfile, dps.NodePool, start, false)
 		}
 	}()
 
@@ -104,17 +139,67 @@ func (dps *DeviceProfileSyncer) Run(stop <-chan struct{}) {
 	klog.V(1).Info("[DeviceProfile] Stopping the syncer")
 }
 
+// handleEdgeEvent synchronizes the deviceProfile changed on the edge platform right away
+func (dps *DeviceProfileSyncer) handleEdgeEvent(ctx context.Context, e devcli.Event) {
+	klog.V(4).InfoS("[DeviceProfile] Received an edge event", "Action", e.Action, "DeviceProfileName", e.Name)
+	if err := dps.syncDeviceProfile(ctx, e.Name); err != nil {
+		klog.V(3).ErrorS(err, "fail to synchronize the deviceProfile changed on the edge platform", "DeviceProfileName", e.Name)
+	}
+}
+
+// syncDeviceProfile synchronizes a single deviceProfile between the edge platform and OpenYurt
+func (dps *DeviceProfileSyncer) syncDeviceProfile(ctx context.Context, name string) error {
+	edgeDeviceProfiles, kubeDeviceProfiles, err := dps.getDeviceProfile(ctx, name)
+	if err != nil {
+		return err
+	}
+	redundantEdgeDeviceProfiles, redundantKubeDeviceProfiles, _ :=
+		dps.findDiffDeviceProfiles(edgeDeviceProfiles, kubeDeviceProfiles)
+	if err := dps.syncEdgeToKube(redundantEdgeDeviceProfiles); err != nil {
+		return err
+	}
+	return dps.deleteDeviceProfiles(redundantKubeDeviceProfiles)
+}
+
+// getDeviceProfile gets the deviceProfile of the given name on the edge platform and OpenYurt,
+// the returned maps are empty if the deviceProfile doesn't exist
+func (dps *DeviceProfileSyncer) getDeviceProfile(ctx context.Context, name string) (
+	map[string]devicev1alpha1.DeviceProfile, map[string]devicev1alpha1.DeviceProfile, error) {
+
+	edgeDeviceProfiles := map[string]devicev1alpha1.DeviceProfile{}
+	kubeDeviceProfiles := map[string]devicev1alpha1.DeviceProfile{}
+	edp, err := dps.edgeClient.Get(ctx, name, devcli.GetOptions{})
+	if err == nil {
+		edgeDeviceProfiles[util.GetEdgeDeviceProfileName(edp, EdgeXObjectName)] = *edp
+	} else if !devcli.IsNotFoundErr(err) {
+		return edgeDeviceProfiles, kubeDeviceProfiles, err
+	}
+
+	var kDps devicev1alpha1.DeviceProfileList
+	listOptions := client.MatchingFields{util.IndexerPathForNodepool: dps.NodePool}
+	if err = dps.List(context.TODO(), &kDps, listOptions, client.InNamespace(dps.Namespace)); err != nil {
+		return edgeDeviceProfiles, kubeDeviceProfiles, err
+	}
+	for i := range kDps.Items {
+		if util.GetEdgeDeviceProfileName(&kDps.Items[i], EdgeXObjectName) == name {
+			kubeDeviceProfiles[name] = kDps.Items[i]
+			break
+		}
+	}
+	return edgeDeviceProfiles, kubeDeviceProfiles, nil
+}
+
 // Get the existing DeviceProfile on the Edge platform, as well as OpenYurt existing DeviceProfile
 // edgeDeviceProfiles：map[actualName]DeviceProfile
 // kubeDeviceProfiles：map[actualName]DeviceProfile
-func (dps *DeviceProfileSyncer) getAllDeviceProfiles() (
+func (dps *DeviceProfileSyncer) getAllDeviceProfiles(ctx context.Context) (
 	map[string]devicev1alpha1.DeviceProfile, map[string]devicev1alpha1.DeviceProfile, error) {
 
 	edgeDeviceProfiles := map[string]devicev1alpha1.DeviceProfile{}
 	kubeDeviceProfiles := map[string]devicev1alpha1.DeviceProfile{}
 
 	// 1. list deviceProfiles on edge platform
-	eDps, err := dps.edgeClient.List(nil, devcli.ListOptions{})
+	eDps, err := dps.edgeClient.List(ctx, devcli.ListOptions{})
 	if err != nil {
 		klog.V(4).ErrorS(err, "fail to list the deviceProfiles on the edge platform")
 		return edgeDeviceProfiles, kubeDeviceProfiles, err
@@ -151,6 +236,11 @@ func (dps *DeviceProfileSyncer) findDiffDeviceProfiles(
 		edp := edgeDeviceProfiles[i]
 		edpName := util.GetEdgeDeviceProfileName(&edp, EdgeXObjectName)
 		if _, exists := kubeDeviceProfiles[edpName]; !exists {
+			if isOrphaned(edp.Spec.Labels) {
+				// the deviceProfile was left on the edge platform when its OpenYurt deviceProfile was deleted
+				klog.V(5).Infof("skip orphaned edge deviceProfile %s", edpName)
+				continue
+			}
 			redundantEdgeDeviceProfiles[edpName] = dps.completeCreateContent(&edp)
 		} else {
 			kdp := kubeDeviceProfiles[edpName]
@@ -195,12 +285,27 @@ func (dps *DeviceProfileSyncer) syncEdgeToKube(edgeDps map[string]*devicev1alpha
 				continue
 			}
 			klog.Infof("created deviceProfile failed: %s", strings.ToLower(edp.Name))
+			dps.recorder.Eventf(edp, corev1.EventTypeWarning, EventReasonImportFailed, "Failed to import deviceProfile %s from the edge platform: %v",
+				util.GetEdgeDeviceProfileName(edp, EdgeXObjectName), err)
 			return err
 		}
+		dps.recorder.Eventf(edp, corev1.EventTypeNormal, EventReasonImported, "Imported deviceProfile %s from the edge platform",
+			util.GetEdgeDeviceProfileName(edp, EdgeXObjectName))
 	}
 	return nil
 }
 
+// confirmRedundantKubeDeviceProfiles keeps only the redundant deviceProfiles which are confirmed to no longer exist
+// on edge platform, the paged list of the edge platform misses a deviceProfile when another one is deleted meanwhile
+func (dps *DeviceProfileSyncer) confirmRedundantKubeDeviceProfiles(ctx context.Context, redundantKubeDeviceProfiles map[string]*devicev1alpha1.DeviceProfile) {
+	for name := range redundantKubeDeviceProfiles {
+		if _, err := dps.edgeClient.Get(ctx, name, devcli.GetOptions{}); !devcli.IsNotFoundErr(err) {
+			klog.V(4).InfoS("the deviceProfile is not confirmed to be deleted from the edge platform, keep it", "DeviceProfile", name, "err", err)
+			delete(redundantKubeDeviceProfiles, name)
+		}
+	}
+}
+
 // deleteDeviceProfiles deletes redundant deviceProfiles on OpenYurt
 func (dps *DeviceProfileSyncer) deleteDeviceProfiles(redundantKubeDeviceProfiles map[string]*devicev1alpha1.DeviceProfile) error {
 	for _, kdp := range redundantKubeDeviceProfiles {
@@ -209,6 +314,8 @@ func (dps *DeviceProfileSyncer) deleteDeviceProfiles(redundantKubeDeviceProfiles
 				"DeviceProfile", kdp.Name)
 			return err
 		}
+		dps.recorder.Eventf(kdp, corev1.EventTypeNormal, EventReasonRemovedFromEdge, "Deleted the deviceProfile since %s no longer exists on the edge platform",
+			util.GetEdgeDeviceProfileName(kdp, EdgeXObjectName))
 	}
 	return nil
 }
@@ -24,12 +24,13 @@ import (
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
 	"github.com/openyurtio/device-controller/pkg/clients"
-	edgexCli "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
 	"github.com/openyurtio/device-controller/pkg/controllers/util"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/util/conditions"
@@ -41,15 +42,18 @@ import (
 type DeviceServiceReconciler struct {
 	client.Client
 	Scheme           *runtime.Scheme
+	Recorder         record.EventRecorder
 	deviceServiceCli clients.DeviceServiceInterface
 	NodePool         string
+	// the deletion policy of the deviceServices which do not set their own
+	defaultDeletionPolicy devicev1alpha1.DeletionPolicy
 }
 
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=deviceservices,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=deviceservices/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=device.openyurt.io,resources=deviceservices/finalizers,verbs=update
 
-func (r *DeviceServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *DeviceServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	var ds devicev1alpha1.DeviceService
 	if err := r.Get(ctx, req.NamespacedName, &ds); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -62,17 +66,18 @@ func (r *DeviceServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	klog.V(3).Infof("Reconciling the DeviceService: %s", ds.GetName())
 	// Update deviceService conditions
 	defer func() {
+		result, err = handleEdgeError(&ds, result, err)
 		if ds.Spec.Managed != true {
 			conditions.MarkFalse(&ds, devicev1alpha1.DeviceServiceManagingCondition, "this deviceService is not managed by openyurt", clusterv1.ConditionSeverityInfo, "")
 		}
 		conditions.SetSummary(&ds,
 			conditions.WithConditions(
-				devicev1alpha1.DeviceServiceSyncedCondition, devicev1alpha1.DeviceServiceManagingCondition),
+				devicev1alpha1.DeviceServiceSyncedCondition, devicev1alpha1.DeviceServiceManagingCondition, devicev1alpha1.EdgeAvailableCondition),
 		)
-		err := r.Status().Update(ctx, &ds)
-		if client.IgnoreNotFound(err) != nil {
-			if !apierrors.IsConflict(err) {
-				klog.V(4).ErrorS(err, "update deviceService conditions failed", "deviceService", ds.GetName())
+		updateErr := r.Status().Update(ctx, &ds)
+		if client.IgnoreNotFound(updateErr) != nil {
+			if !apierrors.IsConflict(updateErr) {
+				klog.V(4).ErrorS(updateErr, "update deviceService conditions failed", "deviceService", ds.GetName())
 			}
 		}
 	}()
@@ -107,9 +112,13 @@ func (r *DeviceServiceReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 }
 
 // SetupWithManager sets up the controller with the Manager.
-func (r *DeviceServiceReconciler) SetupWithManager(mgr ctrl.Manager, opts *options.YurtDeviceControllerOptions) error {
-	r.deviceServiceCli = edgexCli.NewEdgexDeviceServiceClient(opts.CoreMetadataAddr)
+func (r *DeviceServiceReconciler) SetupWithManager(mgr ctrl.Manager, driver clients.Driver, opts *options.YurtDeviceControllerOptions) error {
+	var err error
+	if r.deviceServiceCli, err = driver.NewDeviceServiceClient(); err != nil {
+		return err
+	}
 	r.NodePool = opts.Nodepool
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicy(opts.DeletionPolicy)
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&devicev1alpha1.DeviceService{}).
@@ -134,48 +143,72 @@ func (r *DeviceServiceReconciler) reconcileDeleteDeviceService(ctx context.Conte
 				}
 			}
 		}
-	} else {
-		patchString := map[string]interface{}{
-			"metadata": map[string]interface{}{
-				"finalizers": []string{},
-			},
-		}
-		// delete the deviceService in OpenYurt
-		if patchData, err := json.Marshal(patchString); err != nil {
+	} else if effectiveDeletionPolicy(ds.Spec.DeletionPolicy, r.defaultDeletionPolicy) == devicev1alpha1.DeletionPolicyOrphan {
+		// leave the deviceService on the edge platform and label it, so that the syncer does not import it again
+		err := r.orphanEdgeDeviceService(ctx, edgeDeviceServiceName)
+		if err != nil && !clients.IsNotFoundErr(err) {
+			r.Recorder.Eventf(ds, corev1.EventTypeWarning, EventReasonOrphanOnEdgeFailed, "Failed to label deviceService %s as orphaned on the edge platform: %v", edgeDeviceServiceName, err)
 			return err
-		} else {
-			if err = r.Patch(ctx, ds, client.RawPatch(types.MergePatchType, patchData)); err != nil {
-				return err
-			}
 		}
-
+		r.Recorder.Eventf(ds, corev1.EventTypeNormal, EventReasonOrphanedOnEdge, "Left deviceService %s on the edge platform as orphaned", edgeDeviceServiceName)
+		return r.removeDeviceServiceFinalizers(ctx, ds)
+	} else {
 		// delete the deviceService object on edge platform
-		err := r.deviceServiceCli.Delete(nil, edgeDeviceServiceName, clients.DeleteOptions{})
+		err := r.deviceServiceCli.Delete(ctx, edgeDeviceServiceName, clients.DeleteOptions{})
 		if err != nil && !clients.IsNotFoundErr(err) {
+			r.Recorder.Eventf(ds, corev1.EventTypeWarning, EventReasonDeleteOnEdgeFailed, "Failed to delete deviceService %s from the edge platform: %v", edgeDeviceServiceName, err)
 			return err
 		}
+		r.Recorder.Eventf(ds, corev1.EventTypeNormal, EventReasonDeletedOnEdge, "Deleted deviceService %s from the edge platform", edgeDeviceServiceName)
+		return r.removeDeviceServiceFinalizers(ctx, ds)
 	}
 	return nil
 }
 
+// removeDeviceServiceFinalizers removes the finalizers of the deviceService, so that it is deleted in OpenYurt
+func (r *DeviceServiceReconciler) removeDeviceServiceFinalizers(ctx context.Context, ds *devicev1alpha1.DeviceService) error {
+	patchData, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers": []string{},
+		},
+	})
+	return r.Patch(ctx, ds, client.RawPatch(types.MergePatchType, patchData))
+}
+
+// orphanEdgeDeviceService adds the orphaned label to the deviceService on the edge platform
+func (r *DeviceServiceReconciler) orphanEdgeDeviceService(ctx context.Context, edgeDeviceServiceName string) error {
+	edgeDs, err := r.deviceServiceCli.Get(ctx, edgeDeviceServiceName, clients.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if isOrphaned(edgeDs.Spec.Labels) {
+		return nil
+	}
+	edgeDs.Spec.Labels = withOrphanedLabel(edgeDs.Spec.Labels)
+	_, err = r.deviceServiceCli.Update(ctx, edgeDs, clients.UpdateOptions{Fields: []string{"labels"}})
+	return err
+}
+
 func (r *DeviceServiceReconciler) reconcileCreateDeviceService(ctx context.Context, ds *devicev1alpha1.DeviceService) error {
 	// get the actual name of deviceService on the Edge platform from the Label of the device
 	edgeDeviceServiceName := util.GetEdgeDeviceServiceName(ds, EdgeXObjectName)
 	klog.V(4).Infof("Checking if deviceService already exist on the edge platform: %s", ds.GetName())
 	// Checking if deviceService already exist on the edge platform
-	if edgeDs, err := r.deviceServiceCli.Get(nil, edgeDeviceServiceName, clients.GetOptions{}); err != nil {
+	if edgeDs, err := r.deviceServiceCli.Get(ctx, edgeDeviceServiceName, clients.GetOptions{}); err != nil {
 		if !clients.IsNotFoundErr(err) {
 			klog.V(4).ErrorS(err, "fail to visit the edge platform")
-			return nil
+			return err
 		} else {
-			createdDs, err := r.deviceServiceCli.Create(nil, ds, clients.CreateOptions{})
+			createdDs, err := r.deviceServiceCli.Create(ctx, ds, clients.CreateOptions{})
 			if err != nil {
 				klog.V(4).ErrorS(err, "failed to create deviceService on edge platform")
-				conditions.MarkFalse(ds, devicev1alpha1.DeviceServiceSyncedCondition, "failed to add DeviceService to EdgeX", clusterv1.ConditionSeverityWarning, err.Error())
-				return fmt.Errorf("fail to add DeviceService to edge platform: %v", err)
+				conditions.MarkFalse(ds, devicev1alpha1.DeviceServiceSyncedCondition, edgeErrorReason("failed to add DeviceService to EdgeX", err), clusterv1.ConditionSeverityWarning, err.Error())
+				r.Recorder.Eventf(ds, corev1.EventTypeWarning, EventReasonCreateOnEdgeFailed, "Failed to add deviceService %s to the edge platform: %v", edgeDeviceServiceName, err)
+				return fmt.Errorf("fail to add DeviceService to edge platform: %w", err)
 			}
 
 			klog.V(4).Infof("Successfully add DeviceService to Edge Platform, Name: %s, EdgeId: %s", ds.GetName(), createdDs.Status.EdgeId)
+			r.Recorder.Eventf(ds, corev1.EventTypeNormal, EventReasonCreatedOnEdge, "Added deviceService %s to the edge platform, EdgeId: %s", edgeDeviceServiceName, createdDs.Status.EdgeId)
 			ds.Status.EdgeId = createdDs.Status.EdgeId
 			ds.Status.Synced = true
 			conditions.MarkTrue(ds, devicev1alpha1.DeviceServiceSyncedCondition)
@@ -184,6 +217,14 @@ func (r *DeviceServiceReconciler) reconcileCreateDeviceService(ctx context.Conte
 	} else {
 		// a. If object exists, the status of the device on OpenYurt is updated
 		klog.V(4).Infof("DeviceServiceName: %s, obj already exists on edge platform", ds.GetName())
+		if isOrphaned(edgeDs.Spec.Labels) {
+			// the deviceService left on the edge platform by a deleted deviceService is adopted, so it is no longer orphaned
+			edgeDs.Spec.Labels = withoutOrphanedLabel(edgeDs.Spec.Labels)
+			if _, err := r.deviceServiceCli.Update(ctx, edgeDs, clients.UpdateOptions{Fields: []string{"labels"}}); err != nil {
+				conditions.MarkFalse(ds, devicev1alpha1.DeviceServiceSyncedCondition, edgeErrorReason("failed to adopt the orphaned DeviceService on EdgeX", err), clusterv1.ConditionSeverityWarning, err.Error())
+				return fmt.Errorf("fail to adopt the orphaned DeviceService on edge platform: %w", err)
+			}
+		}
 		ds.Status.Synced = true
 		ds.Status.EdgeId = edgeDs.Status.EdgeId
 		return r.Status().Update(ctx, ds)
@@ -201,11 +242,13 @@ func (r *DeviceServiceReconciler) reconcileUpdateDeviceService(ctx context.Conte
 		updateDeviceService.Spec.AdminState = ""
 	}
 
-	_, err := r.deviceServiceCli.Update(nil, updateDeviceService, clients.UpdateOptions{})
+	_, err := r.deviceServiceCli.Update(ctx, updateDeviceService, clients.UpdateOptions{Fields: []string{"adminState"}})
 	if err != nil {
-		conditions.MarkFalse(ds, devicev1alpha1.DeviceServiceManagingCondition, "failed to update AdminState of deviceService on edge platform", clusterv1.ConditionSeverityWarning, err.Error())
+		conditions.MarkFalse(ds, devicev1alpha1.DeviceServiceManagingCondition, edgeErrorReason("failed to update AdminState of deviceService on edge platform", err), clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(ds, corev1.EventTypeWarning, EventReasonUpdateOnEdgeFailed, "Failed to update deviceService %s on the edge platform: %v", util.GetEdgeDeviceServiceName(ds, EdgeXObjectName), err)
 		return err
 	}
+	recordAdminStateChange(r.Recorder, ds, ds.Status.AdminState, newDeviceServiceStatus.AdminState)
 
 	// 2. update the device status on OpenYurt
 	ds.Status = *newDeviceServiceStatus
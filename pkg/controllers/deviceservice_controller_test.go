@@ -0,0 +1,177 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+	edgex "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
+	"github.com/openyurtio/device-controller/pkg/clients/edgex-foundry/edgextest"
+	"github.com/openyurtio/device-controller/pkg/clients/fake"
+
+	"github.com/edgexfoundry/go-mod-core-contracts/v2/dtos"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTestDeviceService(name string) *devicev1alpha1.DeviceService {
+	return &devicev1alpha1.DeviceService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       name,
+			Namespace:  "default",
+			Finalizers: []string{devicev1alpha1.DeviceServiceFinalizer},
+		},
+		Spec: devicev1alpha1.DeviceServiceSpec{
+			NodePool:    testNodePool,
+			Managed:     true,
+			BaseAddress: "http://edgex-device-virtual:59900",
+			AdminState:  devicev1alpha1.UnLocked,
+		},
+	}
+}
+
+func newTestDeviceServiceReconciler(t *testing.T, store *fake.Store, objs ...client.Object) *DeviceServiceReconciler {
+	c := newTestClient(t, objs...)
+	return &DeviceServiceReconciler{
+		Client:           c,
+		Scheme:           c.Scheme(),
+		Recorder:         record.NewFakeRecorder(10),
+		deviceServiceCli: fake.NewFakeDeviceServiceClient(store),
+		NodePool:         testNodePool,
+	}
+}
+
+// deleteTestDeviceService deletes the synced deviceService from OpenYurt and reconciles the deletion
+func deleteTestDeviceService(t *testing.T, r *DeviceServiceReconciler, name string) error {
+	key := types.NamespacedName{Namespace: "default", Name: name}
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile deviceService %s: %v", name, err)
+	}
+	var ds devicev1alpha1.DeviceService
+	if err := r.Get(context.TODO(), key, &ds); err != nil {
+		t.Fatalf("failed to get deviceService %s: %v", name, err)
+	}
+	if err := r.Delete(context.TODO(), &ds); err != nil {
+		t.Fatalf("failed to delete deviceService %s: %v", name, err)
+	}
+	_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key})
+	return err
+}
+
+func TestDeviceServiceReconcilerDeletesEdgeDeviceService(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceServiceReconciler(t, store, newTestDeviceService("device-virtual"))
+	edgeCli := fake.NewFakeDeviceServiceClient(store)
+	key := types.NamespacedName{Namespace: "default", Name: "device-virtual"}
+
+	store.InjectError(fake.DeleteVerb, fake.DeviceServiceKind, "device-virtual", errors.New("connection refused"))
+	if err := deleteTestDeviceService(t, r, "device-virtual"); err == nil {
+		t.Errorf("expected the failed deletion on the edge platform to be retried")
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeWarning, EventReasonDeleteOnEdgeFailed)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceService{}); err != nil {
+		t.Errorf("expected the deviceService to be kept in OpenYurt until it is deleted on the edge platform, got %v", err)
+	}
+
+	store.ClearErrors()
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile the deleted deviceService: %v", err)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonDeletedOnEdge)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceService{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the deviceService to be deleted from OpenYurt, got %v", err)
+	}
+	if _, err := edgeCli.Get(context.TODO(), "device-virtual", clients.GetOptions{}); !clients.IsNotFoundErr(err) {
+		t.Errorf("expected the deviceService to be deleted from the edge platform, got %v", err)
+	}
+}
+
+func TestDeviceServiceReconcilerOrphansEdgeDeviceService(t *testing.T) {
+	store := fake.NewStore()
+	r := newTestDeviceServiceReconciler(t, store, newTestDeviceService("device-virtual"))
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicyOrphan
+	edgeCli := fake.NewFakeDeviceServiceClient(store)
+	key := types.NamespacedName{Namespace: "default", Name: "device-virtual"}
+
+	// the deviceService is kept until it is labelled as orphaned, otherwise the syncer would import it again
+	store.InjectError(fake.UpdateVerb, fake.DeviceServiceKind, "device-virtual", errors.New("connection refused"))
+	if err := deleteTestDeviceService(t, r, "device-virtual"); err == nil {
+		t.Errorf("expected the failed orphaning on the edge platform to be retried")
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeWarning, EventReasonOrphanOnEdgeFailed)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceService{}); err != nil {
+		t.Errorf("expected the deviceService to be kept in OpenYurt until it is orphaned, got %v", err)
+	}
+
+	store.ClearErrors()
+	if _, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("failed to reconcile the deleted deviceService: %v", err)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonOrphanedOnEdge)
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceService{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the deviceService to be deleted from OpenYurt, got %v", err)
+	}
+	eds, err := edgeCli.Get(context.TODO(), "device-virtual", clients.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the deviceService to be left on the edge platform: %v", err)
+	}
+	if !isOrphaned(eds.Spec.Labels) {
+		t.Errorf("expected the edge deviceService to be labelled as orphaned, got labels %v", eds.Spec.Labels)
+	}
+}
+
+func TestDeviceServiceReconcilerOrphansEdgeXDeviceService(t *testing.T) {
+	s := edgextest.NewServer()
+	defer s.Close()
+	s.AddDeviceService(dtos.DeviceService{
+		Name:        "device-virtual",
+		Description: "managed on EdgeX",
+		BaseAddress: "http://edgex-device-virtual:59900",
+		Labels:      []string{"virtual"},
+		AdminState:  "UNLOCKED",
+	})
+	r := newTestDeviceServiceReconciler(t, fake.NewStore(), newTestDeviceService("device-virtual"))
+	r.deviceServiceCli = edgex.NewEdgexDeviceServiceClient(s.MetadataAddr())
+	r.defaultDeletionPolicy = devicev1alpha1.DeletionPolicyOrphan
+
+	// only the labels of the deviceService are patched on EdgeX
+	if err := deleteTestDeviceService(t, r, "device-virtual"); err != nil {
+		t.Fatalf("failed to orphan the deviceService: %v", err)
+	}
+	expectEvent(t, r.Recorder, corev1.EventTypeNormal, EventReasonOrphanedOnEdge)
+	key := types.NamespacedName{Namespace: "default", Name: "device-virtual"}
+	if err := r.Get(context.TODO(), key, &devicev1alpha1.DeviceService{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the deviceService to be deleted from OpenYurt, got %v", err)
+	}
+	eds, exist := s.DeviceService("device-virtual")
+	if !exist {
+		t.Fatalf("expected the deviceService to be left on EdgeX")
+	}
+	if !isOrphaned(eds.Labels) || eds.Description != "managed on EdgeX" {
+		t.Errorf("expected only the orphaned label to be added on EdgeX, got %+v", eds)
+	}
+}
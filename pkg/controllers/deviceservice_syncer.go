@@ -24,10 +24,12 @@ import (
 	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
 	"github.com/openyurtio/device-controller/cmd/yurt-device-controller/options"
 	iotcli "github.com/openyurtio/device-controller/pkg/clients"
-	edgexCli "github.com/openyurtio/device-controller/pkg/clients/edgex-foundry"
 	"github.com/openyurtio/device-controller/pkg/controllers/util"
+	"github.com/openyurtio/device-controller/pkg/metrics"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrlmgr "sigs.k8s.io/controller-runtime/pkg/manager"
@@ -37,16 +39,28 @@ type DeviceServiceSyncer struct {
 	// Kubernetes client
 	client.Client
 	// syncing period in seconds
-	syncPeriod       time.Duration
+	syncPeriod time.Duration
+	// syncing period when the changes are subscribed
+	resyncPeriod     time.Duration
 	deviceServiceCli iotcli.DeviceServiceInterface
-	NodePool         string
-	Namespace        string
+	// the edge events of the deviceServices, nil if the changes are not subscribed
+	events    *edgeEventSubscription
+	recorder  record.EventRecorder
+	NodePool  string
+	Namespace string
 }
 
-func NewDeviceServiceSyncer(client client.Client, opts *options.YurtDeviceControllerOptions) (DeviceServiceSyncer, error) {
+func NewDeviceServiceSyncer(client client.Client, recorder record.EventRecorder, driver iotcli.Driver, bus *EdgeEventBus, opts *options.YurtDeviceControllerOptions) (DeviceServiceSyncer, error) {
+	deviceServiceCli, err := driver.NewDeviceServiceClient()
+	if err != nil {
+		return DeviceServiceSyncer{}, err
+	}
 	return DeviceServiceSyncer{
 		syncPeriod:       time.Duration(opts.EdgeSyncPeriod) * time.Second,
-		deviceServiceCli: edgexCli.NewEdgexDeviceServiceClient(opts.CoreMetadataAddr),
+		resyncPeriod:     time.Duration(opts.EdgeResyncPeriod) * time.Second,
+		deviceServiceCli: deviceServiceCli,
+		events:           bus.subscribe(iotcli.DeviceServiceEventKind),
+		recorder:         recorder,
 		Client:           client,
 		NodePool:         opts.Nodepool,
 		Namespace:        opts.Namespace,
@@ -62,24 +76,43 @@ func (ds *DeviceServiceSyncer) NewDeviceServiceSyncerRunnable() ctrlmgr.Runnable
 
 func (ds *DeviceServiceSyncer) Run(stop <-chan struct{}) {
 	klog.V(1).Info("[DeviceService] Starting the syncer...")
+	events, overflow, period := ds.events.wait(stop, ds.syncPeriod, ds.resyncPeriod)
+	// abort the in-flight requests to the edge platform once stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
+		timer := time.NewTimer(period)
+		defer timer.Stop()
 		for {
-			<-time.After(ds.syncPeriod)
+			select {
+			case <-stop:
+				return
+			case e := <-events:
+				ds.handleEdgeEvent(ctx, e)
+				continue
+			case <-overflow:
+			case <-timer.C:
+				timer.Reset(period)
+			}
 			klog.V(2).Info("[DeviceService] Start a round of synchronization.")
+			start := time.Now()
 			// 1. get deviceServices on edge platform and OpenYurt
-			edgeDeviceServices, kubeDeviceServices, err := ds.getAllDeviceServices()
+			edgeDeviceServices, kubeDeviceServices, err := ds.getAllDeviceServices(ctx)
 			if err != nil {
 				klog.V(3).ErrorS(err, "fail to list the deviceServices")
+				metrics.ObserveSyncRound(metrics.KindDeviceService, ds.NodePool, start, true)
 				continue
 			}
 
 			// 2. find the deviceServices that need to be synchronized
 			redundantEdgeDeviceServices, redundantKubeDeviceServices, syncedDeviceServices :=
 				ds.findDiffDeviceServices(edgeDeviceServices, kubeDeviceServices)
+			ds.confirmRedundantKubeDeviceServices(ctx, redundantKubeDeviceServices)
 			klog.V(2).Infof("[DeviceService] The number of objects waiting for synchronization { %s:%d, %s:%d, %s:%d }",
 				"Edge deviceServices should be added to OpenYurt", len(redundantEdgeDeviceServices),
 				"OpenYurt deviceServices that should be deleted", len(redundantKubeDeviceServices),
 				"DeviceServices that should be synchronized", len(syncedDeviceServices))
+			metrics.SetSyncObjects(metrics.KindDeviceService, ds.NodePool, len(redundantEdgeDeviceServices), len(redundantKubeDeviceServices), len(syncedDeviceServices))
 
 			// 3. create deviceServices on OpenYurt which are exists in edge platform but not in OpenYurt
 			if err := ds.syncEdgeToKube(redundantEdgeDeviceServices); err != nil {
@@ -92,9 +125,10 @@ func (ds *DeviceServiceSyncer) Run(stop <-chan struct{}) {
 			}
 
 			// 5. update deviceService status on OpenYurt
-			if err := ds.updateDeviceServices(syncedDeviceServices); err != nil {
+			if err := ds.updateDeviceServices(syncedDeviceServices, kubeDeviceServices); err != nil {
 				klog.V(3).ErrorS(err, "fail to update deviceServices")
 			}
+			metrics.ObserveSyncRound(metrics.KindDeviceService, ds.NodePool, start, false)
 			klog.V(2).Info("[DeviceService] One round of synchronization is complete")
 		}
 	}()
@@ -103,17 +137,70 @@ func (ds *DeviceServiceSyncer) Run(stop <-chan struct{}) {
 	klog.V(1).Info("[DeviceService] Stopping the syncer")
 }
 
+// handleEdgeEvent synchronizes the deviceService changed on the edge platform right away
+func (ds *DeviceServiceSyncer) handleEdgeEvent(ctx context.Context, e iotcli.Event) {
+	klog.V(4).InfoS("[DeviceService] Received an edge event", "Action", e.Action, "DeviceServiceName", e.Name)
+	if err := ds.syncDeviceService(ctx, e.Name); err != nil {
+		klog.V(3).ErrorS(err, "fail to synchronize the deviceService changed on the edge platform", "DeviceServiceName", e.Name)
+	}
+}
+
+// syncDeviceService synchronizes a single deviceService between the edge platform and OpenYurt
+func (ds *DeviceServiceSyncer) syncDeviceService(ctx context.Context, name string) error {
+	edgeDeviceServices, kubeDeviceServices, err := ds.getDeviceService(ctx, name)
+	if err != nil {
+		return err
+	}
+	redundantEdgeDeviceServices, redundantKubeDeviceServices, syncedDeviceServices :=
+		ds.findDiffDeviceServices(edgeDeviceServices, kubeDeviceServices)
+	if err := ds.syncEdgeToKube(redundantEdgeDeviceServices); err != nil {
+		return err
+	}
+	if err := ds.deleteDeviceServices(redundantKubeDeviceServices); err != nil {
+		return err
+	}
+	return ds.updateDeviceServices(syncedDeviceServices, kubeDeviceServices)
+}
+
+// getDeviceService gets the deviceService of the given name on the edge platform and OpenYurt,
+// the returned maps are empty if the deviceService doesn't exist
+func (ds *DeviceServiceSyncer) getDeviceService(ctx context.Context, name string) (
+	map[string]devicev1alpha1.DeviceService, map[string]devicev1alpha1.DeviceService, error) {
+
+	edgeDeviceServices := map[string]devicev1alpha1.DeviceService{}
+	kubeDeviceServices := map[string]devicev1alpha1.DeviceService{}
+	eds, err := ds.deviceServiceCli.Get(ctx, name, iotcli.GetOptions{})
+	if err == nil {
+		edgeDeviceServices[util.GetEdgeDeviceServiceName(eds, EdgeXObjectName)] = *eds
+	} else if !iotcli.IsNotFoundErr(err) {
+		return edgeDeviceServices, kubeDeviceServices, err
+	}
+
+	var kDevSs devicev1alpha1.DeviceServiceList
+	listOptions := client.MatchingFields{util.IndexerPathForNodepool: ds.NodePool}
+	if err = ds.List(context.TODO(), &kDevSs, listOptions, client.InNamespace(ds.Namespace)); err != nil {
+		return edgeDeviceServices, kubeDeviceServices, err
+	}
+	for i := range kDevSs.Items {
+		if util.GetEdgeDeviceServiceName(&kDevSs.Items[i], EdgeXObjectName) == name {
+			kubeDeviceServices[name] = kDevSs.Items[i]
+			break
+		}
+	}
+	return edgeDeviceServices, kubeDeviceServices, nil
+}
+
 // Get the existing DeviceService on the Edge platform, as well as OpenYurt existing DeviceService
 // edgeDeviceServices：map[actualName]DeviceService
 // kubeDeviceServices：map[actualName]DeviceService
-func (ds *DeviceServiceSyncer) getAllDeviceServices() (
+func (ds *DeviceServiceSyncer) getAllDeviceServices(ctx context.Context) (
 	map[string]devicev1alpha1.DeviceService, map[string]devicev1alpha1.DeviceService, error) {
 
 	edgeDeviceServices := map[string]devicev1alpha1.DeviceService{}
 	kubeDeviceServices := map[string]devicev1alpha1.DeviceService{}
 
 	// 1. list deviceServices on edge platform
-	eDevSs, err := ds.deviceServiceCli.List(nil, iotcli.ListOptions{})
+	eDevSs, err := ds.deviceServiceCli.List(ctx, iotcli.ListOptions{})
 	if err != nil {
 		klog.V(4).ErrorS(err, "fail to list the deviceServices object on the edge platform")
 		return edgeDeviceServices, kubeDeviceServices, err
@@ -150,6 +237,11 @@ func (ds *DeviceServiceSyncer) findDiffDeviceServices(
 		eds := edgeDeviceService[i]
 		edName := util.GetEdgeDeviceServiceName(&eds, EdgeXObjectName)
 		if _, exists := kubeDeviceService[edName]; !exists {
+			if isOrphaned(eds.Spec.Labels) {
+				// the deviceService was left on the edge platform when its OpenYurt deviceService was deleted
+				klog.V(5).Infof("skip orphaned edge deviceService %s", edName)
+				continue
+			}
 			redundantEdgeDeviceServices[edName] = ds.completeCreateContent(&eds)
 		} else {
 			kd := kubeDeviceService[edName]
@@ -180,12 +272,27 @@ func (ds *DeviceServiceSyncer) syncEdgeToKube(edgeDevs map[string]*devicev1alpha
 				continue
 			}
 			klog.InfoS("created deviceService failed:", "DeviceService", strings.ToLower(ed.Name))
+			ds.recorder.Eventf(ed, corev1.EventTypeWarning, EventReasonImportFailed, "Failed to import deviceService %s from the edge platform: %v",
+				util.GetEdgeDeviceServiceName(ed, EdgeXObjectName), err)
 			return err
 		}
+		ds.recorder.Eventf(ed, corev1.EventTypeNormal, EventReasonImported, "Imported deviceService %s from the edge platform",
+			util.GetEdgeDeviceServiceName(ed, EdgeXObjectName))
 	}
 	return nil
 }
 
+// confirmRedundantKubeDeviceServices keeps only the redundant deviceServices which are confirmed to no longer exist
+// on edge platform, the paged list of the edge platform misses a deviceService when another one is deleted meanwhile
+func (ds *DeviceServiceSyncer) confirmRedundantKubeDeviceServices(ctx context.Context, redundantKubeDeviceServices map[string]*devicev1alpha1.DeviceService) {
+	for name := range redundantKubeDeviceServices {
+		if _, err := ds.deviceServiceCli.Get(ctx, name, iotcli.GetOptions{}); !iotcli.IsNotFoundErr(err) {
+			klog.V(4).InfoS("the deviceService is not confirmed to be deleted from the edge platform, keep it", "DeviceService", name, "err", err)
+			delete(redundantKubeDeviceServices, name)
+		}
+	}
+}
+
 // deleteDeviceServices deletes redundant deviceServices on OpenYurt
 func (ds *DeviceServiceSyncer) deleteDeviceServices(redundantKubeDeviceServices map[string]*devicev1alpha1.DeviceService) error {
 	for _, kds := range redundantKubeDeviceServices {
@@ -194,13 +301,16 @@ func (ds *DeviceServiceSyncer) deleteDeviceServices(redundantKubeDeviceServices
 				"DeviceService", kds.Name)
 			return err
 		}
+		ds.recorder.Eventf(kds, corev1.EventTypeNormal, EventReasonRemovedFromEdge, "Deleted the deviceService since %s no longer exists on the edge platform",
+			util.GetEdgeDeviceServiceName(kds, EdgeXObjectName))
 	}
 	return nil
 }
 
-// updateDeviceServices updates deviceServices status on OpenYurt
-func (ds *DeviceServiceSyncer) updateDeviceServices(syncedDeviceServices map[string]*devicev1alpha1.DeviceService) error {
-	for _, sd := range syncedDeviceServices {
+// updateDeviceServices updates deviceServices status on OpenYurt, kubeDeviceServices are the deviceServices before the update
+func (ds *DeviceServiceSyncer) updateDeviceServices(syncedDeviceServices map[string]*devicev1alpha1.DeviceService,
+	kubeDeviceServices map[string]devicev1alpha1.DeviceService) error {
+	for n, sd := range syncedDeviceServices {
 		if sd.ObjectMeta.ResourceVersion == "" {
 			continue
 		}
@@ -213,6 +323,7 @@ func (ds *DeviceServiceSyncer) updateDeviceServices(syncedDeviceServices map[str
 				"DeviceService", sd.Name)
 			return err
 		}
+		recordAdminStateChange(ds.recorder, sd, kubeDeviceServices[n].Status.AdminState, sd.Status.AdminState)
 	}
 	return nil
 }
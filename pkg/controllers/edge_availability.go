@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/clients"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// handleEdgeError marks the EdgeAvailable condition of obj by the error of reconciling it against the edge platform,
+// the condition is left as is if the error tells nothing about the edge platform, e.g. it is from OpenYurt.
+// An object refused by an open circuit breaker is requeued once the breaker lets requests through again, the other
+// errors are returned as they are so that the object is requeued with exponential backoff.
+func handleEdgeError(obj conditions.Setter, result ctrl.Result, err error) (ctrl.Result, error) {
+	var circuitErr *clients.CircuitOpenError
+	switch {
+	case errors.As(err, &circuitErr):
+		conditions.MarkFalse(obj, devicev1alpha1.EdgeAvailableCondition, "the circuit breaker of the edge platform is open",
+			clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{RequeueAfter: circuitErr.RetryAfter}, nil
+	case clients.IsTimeoutErr(err):
+		conditions.MarkFalse(obj, devicev1alpha1.EdgeAvailableCondition, "the edge platform did not respond in time",
+			clusterv1.ConditionSeverityWarning, err.Error())
+	case clients.IsUnavailableErr(err) || clients.IsRetryableErr(err):
+		conditions.MarkFalse(obj, devicev1alpha1.EdgeAvailableCondition, "the edge platform is unavailable",
+			clusterv1.ConditionSeverityWarning, err.Error())
+	case err == nil || clients.IsResponseErr(err):
+		conditions.MarkTrue(obj, devicev1alpha1.EdgeAvailableCondition)
+	}
+	return result, err
+}
+
+// edgeErrorReason returns the reason of a condition for a request the edge platform failed,
+// e.g. "failed to create device on edge platform (invalid request)"
+func edgeErrorReason(reason string, err error) string {
+	switch {
+	case clients.IsNotFoundErr(err):
+		return reason + " (not found)"
+	case clients.IsConflictErr(err):
+		return reason + " (conflict)"
+	case clients.IsLockedErr(err):
+		return reason + " (locked)"
+	case clients.IsInvalidRequestErr(err):
+		return reason + " (invalid request)"
+	case clients.IsTimeoutErr(err):
+		return reason + " (timed out)"
+	case clients.IsUnavailableErr(err):
+		return reason + " (unavailable)"
+	}
+	return reason
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	edgeCli "github.com/openyurtio/device-controller/pkg/clients"
+	"github.com/openyurtio/device-controller/pkg/metrics"
+
+	"k8s.io/klog/v2"
+)
+
+// edgeEventBufferSize is the number of edge events buffered for a syncer,
+// once the buffer is full the syncer runs a full synchronization instead of handling the dropped events
+const edgeEventBufferSize = 1024
+
+// EdgeEventBus holds the single subscription to the changes on the edge platform
+// and fans the events out to the syncers by their kind
+type EdgeEventBus struct {
+	eventCli      edgeCli.EventInterface
+	subscriptions map[edgeCli.EventKind]*edgeEventSubscription
+	// ready is closed once the bus has tried to subscribe
+	ready     chan struct{}
+	connected bool
+}
+
+// edgeEventSubscription is the events of a kind delivered to a syncer
+type edgeEventSubscription struct {
+	bus    *EdgeEventBus
+	kind   edgeCli.EventKind
+	events chan edgeCli.Event
+	// overflow is signaled when an event was dropped, the syncer runs a full synchronization
+	overflow chan struct{}
+}
+
+// NewEdgeEventBus creates the bus delivering the events of eventCli, the syncers
+// subscribe to it before it is started
+func NewEdgeEventBus(eventCli edgeCli.EventInterface) *EdgeEventBus {
+	return &EdgeEventBus{
+		eventCli:      eventCli,
+		subscriptions: map[edgeCli.EventKind]*edgeEventSubscription{},
+		ready:         make(chan struct{}),
+	}
+}
+
+// subscribe registers the syncer of the kind, it returns nil if there is no bus
+func (b *EdgeEventBus) subscribe(kind edgeCli.EventKind) *edgeEventSubscription {
+	if b == nil {
+		return nil
+	}
+	sub := &edgeEventSubscription{
+		bus:      b,
+		kind:     kind,
+		events:   make(chan edgeCli.Event, edgeEventBufferSize),
+		overflow: make(chan struct{}, 1),
+	}
+	b.subscriptions[kind] = sub
+	return sub
+}
+
+// Start subscribes to the edge platform once for all the syncers and delivers the events until ctx is done,
+// if it can't subscribe the syncers fall back to polling the edge platform
+func (b *EdgeEventBus) Start(ctx context.Context) error {
+	if err := b.eventCli.Subscribe(ctx, b.dispatch); err != nil {
+		klog.V(1).ErrorS(err, "fail to subscribe to the edge platform, fall back to polling")
+	} else {
+		klog.V(1).Info("subscribed to the edge platform")
+		b.connected = true
+	}
+	close(b.ready)
+	<-ctx.Done()
+	return nil
+}
+
+// dispatch delivers the event to the syncer of its kind, a resync event signals all the syncers to run
+// a full synchronization
+func (b *EdgeEventBus) dispatch(e edgeCli.Event) {
+	if e.Action == edgeCli.ResyncAction {
+		klog.V(2).Info("the edge events may have been lost, resynchronize")
+		for _, sub := range b.subscriptions {
+			select {
+			case sub.overflow <- struct{}{}:
+			default:
+			}
+		}
+		return
+	}
+	sub, ok := b.subscriptions[e.Kind]
+	if !ok {
+		return
+	}
+	select {
+	case sub.events <- e:
+	default:
+		metrics.AddEdgeEventDropped(string(e.Kind))
+		klog.V(3).InfoS("drop the edge event since too many events are waiting, resynchronize", "Kind", e.Kind, "Action", e.Action, "Name", e.Name)
+		select {
+		case sub.overflow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// wait waits for the bus to subscribe until stop is closed, and returns the events, the overflow signal
+// and the synchronization period to use. If there is no subscription, the returned channels are nil and
+// the syncer keeps polling the edge platform every syncPeriod, otherwise it only polls every resyncPeriod.
+func (s *edgeEventSubscription) wait(stop <-chan struct{}, syncPeriod, resyncPeriod time.Duration) (<-chan edgeCli.Event, <-chan struct{}, time.Duration) {
+	if s == nil {
+		return nil, nil, syncPeriod
+	}
+	select {
+	case <-stop:
+		return nil, nil, syncPeriod
+	case <-s.bus.ready:
+	}
+	if !s.bus.connected {
+		return nil, nil, syncPeriod
+	}
+	klog.V(1).InfoS("handle the events of the edge platform", "Kind", s.kind, "ResyncPeriod", resyncPeriod)
+	return s.events, s.overflow, resyncPeriod
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// The reasons of the events recorded on devices, deviceServices, deviceProfiles and deviceCommands by the reconcilers and syncers
+const (
+	// EventReasonCreatedOnEdge means the object on OpenYurt has been added to the edge platform
+	EventReasonCreatedOnEdge = "CreatedOnEdge"
+	// EventReasonCreateOnEdgeFailed means the object on OpenYurt could not be added to the edge platform
+	EventReasonCreateOnEdgeFailed = "CreateOnEdgeFailed"
+	// EventReasonUpdatedOnEdge means the changes of the object on OpenYurt have been applied to the edge platform
+	EventReasonUpdatedOnEdge = "UpdatedOnEdge"
+	// EventReasonUpdateOnEdgeFailed means the changes of the object on OpenYurt could not be applied to the edge platform
+	EventReasonUpdateOnEdgeFailed = "UpdateOnEdgeFailed"
+	// EventReasonDeletedOnEdge means the object has been deleted from the edge platform along with the object on OpenYurt
+	EventReasonDeletedOnEdge = "DeletedOnEdge"
+	// EventReasonDeleteOnEdgeFailed means the object could not be deleted from the edge platform
+	EventReasonDeleteOnEdgeFailed = "DeleteOnEdgeFailed"
+	// EventReasonOrphanedOnEdge means the object has been left on the edge platform and labelled as orphaned
+	// since the object on OpenYurt was deleted with the Orphan deletion policy
+	EventReasonOrphanedOnEdge = "OrphanedOnEdge"
+	// EventReasonOrphanOnEdgeFailed means the object left on the edge platform could not be labelled as orphaned
+	EventReasonOrphanOnEdgeFailed = "OrphanOnEdgeFailed"
+	// EventReasonImported means the object on the edge platform has been created on OpenYurt by the syncer
+	EventReasonImported = "Imported"
+	// EventReasonImportFailed means the object on the edge platform could not be created on OpenYurt by the syncer
+	EventReasonImportFailed = "ImportFailed"
+	// EventReasonRemovedFromEdge means the object has been deleted from OpenYurt by the syncer
+	// since it no longer exists on the edge platform
+	EventReasonRemovedFromEdge = "RemovedFromEdge"
+	// EventReasonPropertySet means a device property has been set to its desired value
+	EventReasonPropertySet = "PropertySet"
+	// EventReasonPropertySetFailed means a device property could not be read or set to its desired value
+	EventReasonPropertySetFailed = "PropertySetFailed"
+	// EventReasonAdminStateChanged means the admin state of the object on the edge platform has changed
+	EventReasonAdminStateChanged = "AdminStateChanged"
+	// EventReasonCommandSucceeded means a deviceCommand has been run on the device
+	EventReasonCommandSucceeded = "CommandSucceeded"
+	// EventReasonCommandFailed means a deviceCommand could not be run on the device
+	EventReasonCommandFailed = "CommandFailed"
+)
+
+// recordAdminStateChange records the transition of the admin state of the object, the first
+// admin state reported by the edge platform is not a transition
+func recordAdminStateChange(recorder record.EventRecorder, obj runtime.Object, oldState, newState devicev1alpha1.AdminState) {
+	if oldState == "" || oldState == newState {
+		return
+	}
+	recorder.Eventf(obj, corev1.EventTypeNormal, EventReasonAdminStateChanged, "Admin state changed from %s to %s", oldState, newState)
+}
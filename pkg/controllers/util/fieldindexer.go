@@ -27,6 +27,8 @@ import (
 
 const (
 	IndexerPathForNodepool = "spec.nodePool"
+	// IndexerPathForDeviceName indexes the deviceCommands by the device they are run on
+	IndexerPathForDeviceName = "spec.deviceName"
 )
 
 var registerOnce sync.Once
@@ -57,6 +59,14 @@ func RegisterFieldIndexers(fi client.FieldIndexer) error {
 		}); err != nil {
 			return
 		}
+
+		// register the fieldIndexer for deviceCommand
+		if err = fi.IndexField(context.TODO(), &v1alpha1.DeviceCommand{}, IndexerPathForDeviceName, func(rawObj client.Object) []string {
+			command := rawObj.(*v1alpha1.DeviceCommand)
+			return []string{command.Spec.DeviceName}
+		}); err != nil {
+			return
+		}
 	})
 	return err
 }
@@ -32,6 +32,8 @@ import (
 const (
 	PODHOSTNAME  = "/etc/hostname"
 	PODNAMESPACE = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// NodePoolLabel is the label of the nodes indicating which nodePool they belong to
+	NodePoolLabel = "apps.openyurt.io/nodepool"
 )
 
 // GetNodePool get nodepool where device-controller run
@@ -61,7 +63,7 @@ func GetNodePool(cfg *rest.Config) (string, error) {
 	if err != nil {
 		return nodePool, fmt.Errorf("not found node %s: %v", pod.Spec.NodeName, err)
 	}
-	nodePool, ok := node.Labels["apps.openyurt.io/nodepool"]
+	nodePool, ok := node.Labels[NodePoolLabel]
 	if !ok {
 		return nodePool, fmt.Errorf("node %s doesn't add to a nodepool", node.GetName())
 	}
@@ -0,0 +1,227 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// the value types of the deviceResources supported by EdgeX by their bit sizes, compared case-insensitively
+var (
+	IntValueTypes = map[string]int{
+		"int8": 8, "int16": 16, "int32": 32, "int64": 64,
+	}
+	UintValueTypes = map[string]int{
+		"uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64,
+	}
+	FloatValueTypes = map[string]int{
+		"float32": 32, "float64": 64,
+	}
+)
+
+// the relative differences below which two floats are equal, a float32 has about 7 significant digits
+var floatEpsilons = map[int]float64{32: 1e-6, 64: 1e-12}
+
+// FindDeviceProfile returns the deviceProfile in the namespace and nodePool which has the given name on the edge platform,
+// label is the label holding the names of the objects on the edge platform
+func FindDeviceProfile(ctx context.Context, c client.Reader, namespace, nodePool, edgeName, label string) (*devicev1alpha1.DeviceProfile, error) {
+	var dpl devicev1alpha1.DeviceProfileList
+	if err := c.List(ctx, &dpl, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range dpl.Items {
+		dp := &dpl.Items[i]
+		if dp.Spec.NodePool == nodePool && GetEdgeDeviceProfileName(dp, label) == edgeName {
+			return dp, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindProfileProperty returns the deviceResource or the deviceCommand of the deviceProfile by name,
+// the deviceCommand takes precedence like on EdgeX
+func FindProfileProperty(dp *devicev1alpha1.DeviceProfile, name string) (*devicev1alpha1.DeviceResource, *devicev1alpha1.DeviceProfileCommand) {
+	for i := range dp.Spec.DeviceCommands {
+		if dp.Spec.DeviceCommands[i].Name == name {
+			return nil, &dp.Spec.DeviceCommands[i]
+		}
+	}
+	return FindDeviceResource(dp, name), nil
+}
+
+// FindDeviceResource returns the deviceResource of the deviceProfile by name
+func FindDeviceResource(dp *devicev1alpha1.DeviceProfile, name string) *devicev1alpha1.DeviceResource {
+	for i := range dp.Spec.DeviceResources {
+		if dp.Spec.DeviceResources[i].Name == name {
+			return &dp.Spec.DeviceResources[i]
+		}
+	}
+	return nil
+}
+
+// PropertyResource returns the deviceResource holding the value of a device property, which is the deviceResource
+// itself or the only deviceResource operated by the deviceCommand, nil if the value of the property has no type
+func PropertyResource(dp *devicev1alpha1.DeviceProfile, name string) *devicev1alpha1.DeviceResource {
+	if dp == nil {
+		return nil
+	}
+	dr, dc := FindProfileProperty(dp, name)
+	if dc != nil {
+		// a single value is only sent to the commands with one parameter
+		if len(dc.ResourceOperations) != 1 {
+			return nil
+		}
+		return FindDeviceResource(dp, dc.ResourceOperations[0].DeviceResource)
+	}
+	return dr
+}
+
+// SplitArrayValue returns the elements of an array value like "[1, 2]" or "[\"a\", \"b\"]", the strings are unquoted
+func SplitArrayValue(value string) ([]string, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal([]byte(value), &elems); err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		e := string(elem)
+		if s, err := strconv.Unquote(e); err == nil {
+			e = s
+		}
+		values = append(values, e)
+	}
+	return values, nil
+}
+
+// PropertyValuesEqual compares two values of the deviceResource by its value type, e.g. "1.0" and "1" of a Float32 or
+// "true" and "TRUE" of a Bool are equal. The floats and the scaled numbers are equal within their precision, and the
+// values are compared as they are if the deviceResource is unknown or either value can't be parsed.
+func PropertyValuesEqual(dr *devicev1alpha1.DeviceResource, a, b string) bool {
+	if a == b {
+		return true
+	}
+	if dr == nil {
+		return false
+	}
+	vt := strings.ToLower(dr.Properties.ValueType)
+	if !strings.HasSuffix(vt, "array") {
+		return scalarValuesEqual(dr, vt, a, b)
+	}
+	as, errA := SplitArrayValue(a)
+	bs, errB := SplitArrayValue(b)
+	if errA != nil || errB != nil || len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if !scalarValuesEqual(dr, strings.TrimSuffix(vt, "array"), as[i], bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParametersEqual compares the parameters of a deviceCommand by the value types of their deviceResources in the
+// deviceProfile, every desired parameter must have an equal actual one
+func ParametersEqual(dp *devicev1alpha1.DeviceProfile, desired, actual map[string]string) bool {
+	for name, value := range desired {
+		var dr *devicev1alpha1.DeviceResource
+		if dp != nil {
+			dr = FindDeviceResource(dp, name)
+		}
+		if actualValue, ok := actual[name]; !ok || !PropertyValuesEqual(dr, value, actualValue) {
+			return false
+		}
+	}
+	return true
+}
+
+func scalarValuesEqual(dr *devicev1alpha1.DeviceResource, vt, a, b string) bool {
+	if vt == "string" || vt == "binary" || vt == "" {
+		return a == b
+	}
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if vt == "bool" {
+		va, errA := strconv.ParseBool(a)
+		vb, errB := strconv.ParseBool(b)
+		return errA == nil && errB == nil && va == vb
+	}
+	if vt == "object" {
+		var va, vb interface{}
+		if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+			return false
+		}
+		return reflect.DeepEqual(va, vb)
+	}
+
+	scale := parseScale(dr)
+	bits, isFloat := FloatValueTypes[vt]
+	if !isFloat && scale == 0 && !isOffset(dr) {
+		// the integers that are neither scaled nor offset are exact
+		if bits, ok := IntValueTypes[vt]; ok {
+			va, errA := strconv.ParseInt(a, 10, bits)
+			vb, errB := strconv.ParseInt(b, 10, bits)
+			return errA == nil && errB == nil && va == vb
+		}
+		if bits, ok := UintValueTypes[vt]; ok {
+			va, errA := strconv.ParseUint(a, 10, bits)
+			vb, errB := strconv.ParseUint(b, 10, bits)
+			return errA == nil && errB == nil && va == vb
+		}
+		return a == b
+	}
+	if !isFloat {
+		bits = 64
+	}
+	va, errA := strconv.ParseFloat(a, bits)
+	vb, errB := strconv.ParseFloat(b, bits)
+	if errA != nil || errB != nil {
+		return false
+	}
+	// the value written to a scaled resource is rounded to a multiple of the scale by the device
+	tolerance := math.Abs(scale) / 2
+	if relative := floatEpsilons[bits] * math.Max(math.Abs(va), math.Abs(vb)); relative > tolerance {
+		tolerance = relative
+	}
+	return math.Abs(va-vb) <= tolerance
+}
+
+// parseScale returns the scale of the deviceResource, 0 if it's not scaled
+func parseScale(dr *devicev1alpha1.DeviceResource) float64 {
+	if dr.Properties.Scale == "" {
+		return 0
+	}
+	scale, err := strconv.ParseFloat(dr.Properties.Scale, 64)
+	if err != nil || scale == 1 {
+		return 0
+	}
+	return scale
+}
+
+// isOffset returns whether an offset is added to the values of the deviceResource
+func isOffset(dr *devicev1alpha1.DeviceResource) bool {
+	offset, err := strconv.ParseFloat(dr.Properties.Offset, 64)
+	return err == nil && offset != 0
+}
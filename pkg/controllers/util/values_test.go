@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+)
+
+func TestPropertyValuesEqual(t *testing.T) {
+	resource := func(valueType, scale string) *devicev1alpha1.DeviceResource {
+		return &devicev1alpha1.DeviceResource{Properties: devicev1alpha1.ResourceProperties{ValueType: valueType, Scale: scale}}
+	}
+	cases := []struct {
+		dr    *devicev1alpha1.DeviceResource
+		a, b  string
+		equal bool
+	}{
+		{nil, "1", "1", true},
+		{nil, "1", "1.0", false},
+		{resource("Bool", ""), "true", "TRUE", true},
+		{resource("Bool", ""), "true", "false", false},
+		{resource("Int8", ""), "042", "42", true},
+		{resource("Int8", ""), "300", "300", true},
+		{resource("Int8", ""), "128", "-128", false},
+		{resource("Uint16", ""), "1", "2", false},
+		{resource("Float32", ""), "1", "1.0", true},
+		{resource("Float32", ""), "0.1", "0.10000000149011612", true},
+		{resource("Float64", ""), "0.1", "0.1000001", false},
+		{resource("Int16", "0.1"), "23.5", "23.54", true},
+		{resource("Int16", "0.1"), "23.5", "23.6", false},
+		{resource("Float32Array", ""), "[1, 2.0]", "[1.0,2]", true},
+		{resource("StringArray", ""), `["a", "b"]`, `["a","c"]`, false},
+		{resource("Object", ""), `{"a": 1, "b": 2}`, `{"b":2,"a":1}`, true},
+		{resource("String", ""), "on", "ON", false},
+	}
+	for _, c := range cases {
+		valueType := "<nil>"
+		if c.dr != nil {
+			valueType = c.dr.Properties.ValueType
+		}
+		if equal := PropertyValuesEqual(c.dr, c.a, c.b); equal != c.equal {
+			t.Errorf("expected %s values %q and %q to be equal: %v, got %v", valueType, c.a, c.b, c.equal, equal)
+		}
+	}
+}
@@ -18,4 +18,10 @@ package controllers
 
 const (
 	EdgeXObjectName = "device-controller/edgex-object.name"
+	// EdgeOrphanedLabel is added to the labels of the objects on the edge platform which are left there
+	// when their OpenYurt objects are deleted with the Orphan deletion policy, the syncers do not import them
+	EdgeOrphanedLabel = "device-controller/orphaned"
+	// LastSyncedSpecAnnotation records the spec of the deviceProfile last synced to the edge platform, so that only
+	// the fields changed on OpenYurt since then are updated on the edge platform
+	LastSyncedSpecAnnotation = "device-controller/last-synced-spec"
 )
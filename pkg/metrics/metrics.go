@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics defines the prometheus metrics of yurt-device-controller, they are registered to the
+// registry of controller-runtime and exposed on the metrics endpoint of the manager.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "yurt_device_controller"
+
+	// the kinds of the objects synchronized by the syncers
+	KindDevice        = "Device"
+	KindDeviceService = "DeviceService"
+	KindDeviceProfile = "DeviceProfile"
+
+	// the states of the objects found in a round of synchronization
+	StateRedundantEdge = "redundant_edge"
+	StateRedundantKube = "redundant_kube"
+	StateSynced        = "synced"
+)
+
+var (
+	syncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "syncer",
+		Name:      "round_duration_seconds",
+		Help:      "Duration of the rounds of synchronization between the edge platform and OpenYurt.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"kind", "nodepool", "result"})
+
+	syncObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "syncer",
+		Name:      "objects",
+		Help:      "Number of objects found in the last round of synchronization by state, redundant_edge objects only exist on the edge platform, redundant_kube objects only exist on OpenYurt.",
+	}, []string{"kind", "nodepool", "state"})
+
+	syncLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "syncer",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last round of synchronization that listed the objects of both the edge platform and OpenYurt.",
+	}, []string{"kind", "nodepool"})
+
+	edgeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "edge_client",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests sent to the edge platform by endpoint and method.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"endpoint", "method"})
+
+	edgeRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "edge_client",
+		Name:      "request_errors_total",
+		Help:      "Number of the requests to the edge platform that failed, by endpoint, method and status code, the code is \"error\" if no response was received.",
+	}, []string{"endpoint", "method", "code"})
+
+	edgeRequestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "edge_client",
+		Name:      "request_retries_total",
+		Help:      "Number of the requests to the edge platform that were sent again after failing for a transient reason, by endpoint and method.",
+	}, []string{"endpoint", "method"})
+
+	circuitBreakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "edge_client",
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker of the services of the edge platform, 0 is closed, 1 is half-open and 2 is open.",
+	}, []string{"endpoint"})
+
+	propertyRefreshSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "syncer",
+		Name:      "property_refresh_skipped_total",
+		Help:      "Number of the devices whose properties were not refreshed within the budget of a round of synchronization.",
+	}, []string{"nodepool"})
+
+	propertyFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "device",
+		Name:      "property_reconcile_failures_total",
+		Help:      "Number of the device properties that failed to reconcile, by device.",
+	}, []string{"namespace", "device"})
+
+	edgeEventsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "syncer",
+		Name:      "edge_events_dropped_total",
+		Help:      "Number of the edge events dropped since the buffer of the syncer was full, each drop triggers a full synchronization.",
+	}, []string{"kind"})
+
+	deviceCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "device",
+		Name:      "command_duration_seconds",
+		Help:      "Duration of the deviceCommands run on the edge platform by operation and result.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	}, []string{"operation", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		syncDuration,
+		syncObjects,
+		syncLastSuccess,
+		edgeRequestDuration,
+		edgeRequestErrors,
+		edgeRequestRetries,
+		circuitBreakerState,
+		propertyRefreshSkipped,
+		edgeEventsDropped,
+		propertyFailures,
+		deviceCommandDuration,
+	)
+}
+
+// ObserveSyncRound records a round of synchronization of the kind which started at start,
+// a round is failed if the objects could not be listed
+func ObserveSyncRound(kind, nodePool string, start time.Time, failed bool) {
+	result := "success"
+	if failed {
+		result = "failure"
+	} else {
+		syncLastSuccess.WithLabelValues(kind, nodePool).SetToCurrentTime()
+	}
+	syncDuration.WithLabelValues(kind, nodePool, result).Observe(time.Since(start).Seconds())
+}
+
+// SetSyncObjects records the number of objects found in a round of synchronization of the kind
+func SetSyncObjects(kind, nodePool string, redundantEdge, redundantKube, synced int) {
+	syncObjects.WithLabelValues(kind, nodePool, StateRedundantEdge).Set(float64(redundantEdge))
+	syncObjects.WithLabelValues(kind, nodePool, StateRedundantKube).Set(float64(redundantKube))
+	syncObjects.WithLabelValues(kind, nodePool, StateSynced).Set(float64(synced))
+}
+
+// ObserveEdgeRequest records a request to the edge platform, code is the status code of the response
+// or 0 if no response was received, the status codes of 400 and above are counted as errors
+func ObserveEdgeRequest(endpoint, method string, code int, duration time.Duration) {
+	edgeRequestDuration.WithLabelValues(endpoint, method).Observe(duration.Seconds())
+	if code == 0 {
+		edgeRequestErrors.WithLabelValues(endpoint, method, "error").Inc()
+	} else if code >= 400 {
+		edgeRequestErrors.WithLabelValues(endpoint, method, strconv.Itoa(code)).Inc()
+	}
+}
+
+// AddEdgeRequestRetry records a request to the edge platform that is sent again
+func AddEdgeRequestRetry(endpoint, method string) {
+	edgeRequestRetries.WithLabelValues(endpoint, method).Inc()
+}
+
+// SetCircuitBreakerState records the state of the circuit breaker of the service, 0 is closed, 1 is half-open and 2 is open
+func SetCircuitBreakerState(endpoint string, state int) {
+	circuitBreakerState.WithLabelValues(endpoint).Set(float64(state))
+}
+
+// AddPropertyRefreshSkipped records the devices whose properties were not refreshed in a round of synchronization
+func AddPropertyRefreshSkipped(nodePool string, count int) {
+	if count > 0 {
+		propertyRefreshSkipped.WithLabelValues(nodePool).Add(float64(count))
+	}
+}
+
+// AddEdgeEventDropped records an edge event of the kind dropped by the syncer
+func AddEdgeEventDropped(kind string) {
+	edgeEventsDropped.WithLabelValues(kind).Inc()
+}
+
+// AddPropertyFailures records the properties of the device that failed to reconcile
+func AddPropertyFailures(namespace, device string, count int) {
+	if count > 0 {
+		propertyFailures.WithLabelValues(namespace, device).Add(float64(count))
+	}
+}
+
+// ObserveDeviceCommand records a deviceCommand run on the edge platform, result is either succeeded or failed
+func ObserveDeviceCommand(operation, result string, duration time.Duration) {
+	deviceCommandDuration.WithLabelValues(operation, result).Observe(duration.Seconds())
+}
+
+// DeleteDevice removes the metrics of the device once it is deleted
+func DeleteDevice(namespace, device string) {
+	propertyFailures.DeleteLabelValues(namespace, device)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/mutate-device-openyurt-io-v1alpha1-device,mutating=true,failurePolicy=fail,sideEffects=None,groups=device.openyurt.io,resources=devices,verbs=create;update,versions=v1alpha1,name=mdevice.kb.io,admissionReviewVersions=v1
+
+// DeviceDefaulter sets the defaults of the devices created or updated on OpenYurt
+type DeviceDefaulter struct {
+	// Reader reads the namespaces of the devices, it should not be backed by the cache of the manager
+	Reader  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &DeviceDefaulter{}
+var _ admission.DecoderInjector = &DeviceDefaulter{}
+
+func (m *DeviceDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var d devicev1alpha1.Device
+	if err := m.decoder.Decode(req, &d); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !d.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if err := defaultNodePool(ctx, m.Reader, &d, &d.Spec.NodePool); err != nil {
+		klog.V(4).ErrorS(err, "failed to default the nodePool of the device", "DeviceName", d.GetName())
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	defaultEdgeXObjectName(&d)
+	defaultDevice(&d)
+
+	marshaled, err := json.Marshal(&d)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+func (m *DeviceDefaulter) InjectDecoder(d *admission.Decoder) error {
+	m.decoder = d
+	return nil
+}
+
+// defaultDevice sets the states of the device like EdgeX does, and keys the desired properties by their names
+func defaultDevice(d *devicev1alpha1.Device) {
+	if d.Spec.AdminState == "" {
+		d.Spec.AdminState = devicev1alpha1.UnLocked
+	}
+	if d.Spec.OperatingState == "" {
+		d.Spec.OperatingState = devicev1alpha1.Up
+	}
+	if len(d.Spec.DeviceProperties) == 0 {
+		return
+	}
+	// the reconciler works on the names of the properties, a property whose name differs from its key is
+	// moved to the key of its name, unless the names collide, then the properties are left for the validator to deny
+	properties := make(map[string]devicev1alpha1.DesiredPropertyState, len(d.Spec.DeviceProperties))
+	collided := false
+	for key, property := range d.Spec.DeviceProperties {
+		if property.Name == "" {
+			property.Name = key
+			d.Spec.DeviceProperties[key] = property
+		}
+		if _, exist := properties[property.Name]; exist {
+			collided = true
+		}
+		properties[property.Name] = property
+	}
+	if !collided {
+		d.Spec.DeviceProperties = properties
+	}
+}
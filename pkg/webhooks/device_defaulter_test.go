@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/controllers"
+	"github.com/openyurtio/device-controller/pkg/controllers/util"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestDefaultDevice(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "default",
+		Labels: map[string]string{util.NodePoolLabel: testNodePool},
+	}}
+	r := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ns).Build()
+
+	d := &devicev1alpha1.Device{
+		ObjectMeta: metav1.ObjectMeta{Name: "thermometer-01", Namespace: "default"},
+		Spec: devicev1alpha1.DeviceSpec{
+			DeviceProperties: map[string]devicev1alpha1.DesiredPropertyState{
+				"Temperature": {DesiredValue: "25"},
+				"humidity":    {Name: "Humidity"},
+				"SetPoint":    {Name: "SetPoint", DesiredValue: "20"},
+			},
+		},
+	}
+	if err := defaultNodePool(context.TODO(), r, d, &d.Spec.NodePool); err != nil {
+		t.Fatal(err)
+	}
+	defaultEdgeXObjectName(d)
+	defaultDevice(d)
+
+	if d.Spec.NodePool != testNodePool {
+		t.Errorf("expected nodePool %s, got %s", testNodePool, d.Spec.NodePool)
+	}
+	if name := d.Labels[controllers.EdgeXObjectName]; name != "thermometer-01" {
+		t.Errorf("expected edgex object name thermometer-01, got %s", name)
+	}
+	if d.Spec.AdminState != devicev1alpha1.UnLocked || d.Spec.OperatingState != devicev1alpha1.Up {
+		t.Errorf("expected states UNLOCKED and UP, got %s and %s", d.Spec.AdminState, d.Spec.OperatingState)
+	}
+	expected := map[string]devicev1alpha1.DesiredPropertyState{
+		"Temperature": {Name: "Temperature", DesiredValue: "25"},
+		"Humidity":    {Name: "Humidity"},
+		"SetPoint":    {Name: "SetPoint", DesiredValue: "20"},
+	}
+	if !reflect.DeepEqual(d.Spec.DeviceProperties, expected) {
+		t.Errorf("expected properties %v, got %v", expected, d.Spec.DeviceProperties)
+	}
+
+	// the properties are left in place when their names collide, the validator denies them
+	d.Spec.DeviceProperties = map[string]devicev1alpha1.DesiredPropertyState{
+		"humidity": {Name: "Humidity"},
+		"SetPoint": {DesiredValue: "20"},
+		"setpoint": {Name: "SetPoint", DesiredValue: "30"},
+	}
+	defaultDevice(d)
+	expected = map[string]devicev1alpha1.DesiredPropertyState{
+		"humidity": {Name: "Humidity"},
+		"SetPoint": {Name: "SetPoint", DesiredValue: "20"},
+		"setpoint": {Name: "SetPoint", DesiredValue: "30"},
+	}
+	if !reflect.DeepEqual(d.Spec.DeviceProperties, expected) {
+		t.Errorf("expected properties %v, got %v", expected, d.Spec.DeviceProperties)
+	}
+
+	// the nodePool label of the object takes precedence over the namespace
+	ds := &devicev1alpha1.DeviceService{ObjectMeta: metav1.ObjectMeta{
+		Name:      "device-virtual",
+		Namespace: "default",
+		Labels:    map[string]string{util.NodePoolLabel: "beijing"},
+	}}
+	if err := defaultNodePool(context.TODO(), r, ds, &ds.Spec.NodePool); err != nil {
+		t.Fatal(err)
+	}
+	if ds.Spec.NodePool != "beijing" {
+		t.Errorf("expected nodePool beijing, got %s", ds.Spec.NodePool)
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/controllers"
+	"github.com/openyurtio/device-controller/pkg/controllers/util"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-device-openyurt-io-v1alpha1-device,mutating=false,failurePolicy=fail,sideEffects=None,groups=device.openyurt.io,resources=devices,verbs=create;update,versions=v1alpha1,name=vdevice.kb.io,admissionReviewVersions=v1
+
+// DeviceValidator validates the devices created or updated on OpenYurt
+type DeviceValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &DeviceValidator{}
+var _ admission.DecoderInjector = &DeviceValidator{}
+
+func (v *DeviceValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var d devicev1alpha1.Device
+	if err := v.decoder.Decode(req, &d); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	// the device being deleted only waits for its finalizer to be removed
+	if !d.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	var old *devicev1alpha1.Device
+	if req.Operation == admissionv1.Update {
+		old = &devicev1alpha1.Device{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	allErrs, err := v.validateDevice(ctx, &d, old)
+	if err != nil {
+		klog.V(4).ErrorS(err, "failed to validate the device", "DeviceName", d.GetName())
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(allErrs) != 0 {
+		return admission.Denied(allErrs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+func (v *DeviceValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// validateDevice checks the fields of the device, the references to the deviceProfile and deviceService
+// are only checked when they are set or changed, so that the existing devices can always be updated
+func (v *DeviceValidator) validateDevice(ctx context.Context, d, old *devicev1alpha1.Device) (field.ErrorList, error) {
+	specPath := field.NewPath("spec")
+	allErrs := validateAdminState(specPath.Child("adminState"), d.Spec.AdminState)
+	allErrs = append(allErrs, validateOperatingState(specPath.Child("operatingState"), d.Spec.OperatingState)...)
+
+	if old == nil || old.Spec.Service != d.Spec.Service || old.Spec.NodePool != d.Spec.NodePool {
+		ds, err := findDeviceService(ctx, v.Client, d.Namespace, d.Spec.NodePool, d.Spec.Service)
+		if err != nil {
+			return nil, err
+		}
+		if ds == nil {
+			allErrs = append(allErrs, field.NotFound(specPath.Child("serviceName"), d.Spec.Service))
+		}
+	}
+
+	propertiesChanged := old == nil || old.Spec.Profile != d.Spec.Profile || old.Spec.NodePool != d.Spec.NodePool ||
+		!propertiesEqual(old.Spec.DeviceProperties, d.Spec.DeviceProperties)
+	if !propertiesChanged {
+		return allErrs, nil
+	}
+	dp, err := util.FindDeviceProfile(ctx, v.Client, d.Namespace, d.Spec.NodePool, d.Spec.Profile, controllers.EdgeXObjectName)
+	if err != nil {
+		return nil, err
+	}
+	if dp == nil {
+		allErrs = append(allErrs, field.NotFound(specPath.Child("profileName"), d.Spec.Profile))
+		return allErrs, nil
+	}
+	allErrs = append(allErrs, validateDeviceProperties(specPath.Child("deviceProperties"), d.Spec.DeviceProperties, dp)...)
+	return allErrs, nil
+}
+
+// validateDeviceProperties checks that every property is keyed by its name and is a deviceResource or deviceCommand
+// of the deviceProfile, and the desired values can be written to them
+func validateDeviceProperties(fldPath *field.Path, properties map[string]devicev1alpha1.DesiredPropertyState, dp *devicev1alpha1.DeviceProfile) field.ErrorList {
+	var allErrs field.ErrorList
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		property := properties[key]
+		propertyPath := fldPath.Key(key)
+		if property.Name != key {
+			allErrs = append(allErrs, field.Invalid(propertyPath.Child("name"), property.Name,
+				"must be the same as the key, the name collides with another property"))
+			continue
+		}
+		dr, dc := util.FindProfileProperty(dp, key)
+		if dr == nil && dc == nil {
+			allErrs = append(allErrs, field.NotFound(propertyPath, fmt.Sprintf("deviceResource or deviceCommand %s of deviceProfile %s", key, dp.Name)))
+			continue
+		}
+		var readWrite string
+		if dc != nil {
+			readWrite = dc.ReadWrite
+		} else {
+			readWrite = dr.Properties.ReadWrite
+		}
+		if !strings.ContainsAny(readWrite, "RW") {
+			allErrs = append(allErrs, field.Forbidden(propertyPath, fmt.Sprintf("%s is neither readable nor writable", key)))
+			continue
+		}
+		allErrs = append(allErrs, validatePropertyIntervals(propertyPath, property)...)
+		if len(property.DesiredParameters) != 0 {
+			allErrs = append(allErrs, validateDesiredParameters(propertyPath, key, property, readWrite, dc, dp)...)
+			continue
+		}
+		if property.DesiredValue == "" {
+			continue
+		}
+		valuePath := propertyPath.Child("desiredValue")
+		if !strings.Contains(readWrite, "W") {
+			allErrs = append(allErrs, field.Forbidden(valuePath, fmt.Sprintf("%s is not writable", key)))
+			continue
+		}
+		if dc != nil {
+			// a single value is only sent to the commands with one parameter
+			if len(dc.ResourceOperations) != 1 {
+				continue
+			}
+			if dr = util.FindDeviceResource(dp, dc.ResourceOperations[0].DeviceResource); dr == nil {
+				continue
+			}
+		}
+		if err := validateResourceValue(dr, property.DesiredValue); err != nil {
+			allErrs = append(allErrs, field.Invalid(valuePath, property.DesiredValue, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// validateDesiredParameters checks that the property is a writable deviceCommand, every parameter is a deviceResource
+// operated by the command whose value can be written to the deviceResource, and no deviceResource of the command is missing
+func validateDesiredParameters(fldPath *field.Path, key string, property devicev1alpha1.DesiredPropertyState, readWrite string,
+	dc *devicev1alpha1.DeviceProfileCommand, dp *devicev1alpha1.DeviceProfile) field.ErrorList {
+	var allErrs field.ErrorList
+	parametersPath := fldPath.Child("desiredParameters")
+	if property.DesiredValue != "" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("desiredValue"), property.DesiredValue, "must be empty when desiredParameters are set"))
+	}
+	if !strings.Contains(readWrite, "W") {
+		return append(allErrs, field.Forbidden(parametersPath, fmt.Sprintf("%s is not writable", key)))
+	}
+	if dc == nil {
+		return append(allErrs, field.Forbidden(parametersPath, fmt.Sprintf("%s is a deviceResource, only deviceCommands take parameters", key)))
+	}
+	names := make([]string, 0, len(property.DesiredParameters))
+	for name := range property.DesiredParameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := property.DesiredParameters[name]
+		operated := false
+		for _, ro := range dc.ResourceOperations {
+			if ro.DeviceResource == name {
+				operated = true
+				break
+			}
+		}
+		if !operated {
+			allErrs = append(allErrs, field.NotFound(parametersPath.Key(name), fmt.Sprintf("deviceResource %s operated by deviceCommand %s", name, key)))
+			continue
+		}
+		if dr := util.FindDeviceResource(dp, name); dr != nil {
+			if err := validateResourceValue(dr, value); err != nil {
+				allErrs = append(allErrs, field.Invalid(parametersPath.Key(name), value, err.Error()))
+			}
+		}
+	}
+	for _, ro := range dc.ResourceOperations {
+		if _, ok := property.DesiredParameters[ro.DeviceResource]; !ok {
+			allErrs = append(allErrs, field.Required(parametersPath.Key(ro.DeviceResource),
+				fmt.Sprintf("deviceResource %s is operated by deviceCommand %s", ro.DeviceResource, key)))
+		}
+	}
+	return allErrs
+}
+
+// validatePropertyIntervals checks the refresh interval and the max staleness of the property are not negative
+func validatePropertyIntervals(fldPath *field.Path, property devicev1alpha1.DesiredPropertyState) field.ErrorList {
+	var allErrs field.ErrorList
+	if property.RefreshInterval != nil && property.RefreshInterval.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("refreshInterval"), property.RefreshInterval.Duration.String(), "must not be negative"))
+	}
+	if property.MaxStaleness != nil && property.MaxStaleness.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxStaleness"), property.MaxStaleness.Duration.String(), "must not be negative"))
+	}
+	return allErrs
+}
+
+// validateAdminState checks the admin state is valid if it is set
+func validateAdminState(fldPath *field.Path, adminState devicev1alpha1.AdminState) field.ErrorList {
+	switch adminState {
+	case "", devicev1alpha1.Locked, devicev1alpha1.UnLocked:
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(fldPath, adminState,
+		[]string{string(devicev1alpha1.Locked), string(devicev1alpha1.UnLocked)})}
+}
+
+// validateOperatingState checks the operating state is valid if it is set
+func validateOperatingState(fldPath *field.Path, operatingState devicev1alpha1.OperatingState) field.ErrorList {
+	switch operatingState {
+	case "", devicev1alpha1.Up, devicev1alpha1.Down, devicev1alpha1.Unknown:
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(fldPath, operatingState,
+		[]string{string(devicev1alpha1.Up), string(devicev1alpha1.Down), string(devicev1alpha1.Unknown)})}
+}
+
+func propertiesEqual(a, b map[string]devicev1alpha1.DesiredPropertyState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, va := range a {
+		if vb, ok := b[k]; !ok || va.Name != vb.Name || va.DesiredValue != vb.DesiredValue || !reflect.DeepEqual(va.DesiredParameters, vb.DesiredParameters) ||
+			!durationsEqual(va.RefreshInterval, vb.RefreshInterval) || !durationsEqual(va.MaxStaleness, vb.MaxStaleness) {
+			return false
+		}
+	}
+	return true
+}
+
+func durationsEqual(a, b *metav1.Duration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Duration == b.Duration
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/controllers"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testNodePool = "hangzhou"
+
+func newTestDeviceValidator(t *testing.T) *DeviceValidator {
+	scheme := runtime.NewScheme()
+	if err := devicev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	dp := &devicev1alpha1.DeviceProfile{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testNodePool + "-thermometer",
+			Namespace: "default",
+			Labels:    map[string]string{controllers.EdgeXObjectName: "Thermometer"},
+		},
+		Spec: devicev1alpha1.DeviceProfileSpec{
+			NodePool: testNodePool,
+			DeviceResources: []devicev1alpha1.DeviceResource{
+				{Name: "Temperature", Properties: devicev1alpha1.ResourceProperties{ReadWrite: "RW", ValueType: "Int16", Minimum: "-40", Maximum: "125"}},
+				{Name: "Humidity", Properties: devicev1alpha1.ResourceProperties{ReadWrite: "R", ValueType: "Float32"}},
+			},
+			DeviceCommands: []devicev1alpha1.DeviceProfileCommand{
+				{Name: "SetPoint", ReadWrite: "RW", ResourceOperations: []devicev1alpha1.ResourceOperation{{DeviceResource: "Temperature"}}},
+				{Name: "Climate", ReadWrite: "RW", ResourceOperations: []devicev1alpha1.ResourceOperation{
+					{DeviceResource: "Temperature"}, {DeviceResource: "Humidity"},
+				}},
+			},
+		},
+	}
+	ds := &devicev1alpha1.DeviceService{
+		ObjectMeta: metav1.ObjectMeta{Name: "device-virtual", Namespace: "default"},
+		Spec:       devicev1alpha1.DeviceServiceSpec{NodePool: testNodePool},
+	}
+	return &DeviceValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(dp, ds).Build()}
+}
+
+func TestValidateDevice(t *testing.T) {
+	v := newTestDeviceValidator(t)
+	tests := []struct {
+		name   string
+		mutate func(d *devicev1alpha1.Device)
+		errs   []string
+	}{
+		{
+			name:   "valid device",
+			mutate: func(d *devicev1alpha1.Device) {},
+		},
+		{
+			name: "invalid states",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.AdminState = "locked"
+				d.Spec.OperatingState = "ENABLED"
+			},
+			errs: []string{"spec.adminState", "spec.operatingState"},
+		},
+		{
+			name: "profile and service of another nodepool",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.NodePool = "beijing"
+			},
+			errs: []string{"spec.serviceName", "spec.profileName"},
+		},
+		{
+			name: "unknown property",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Pressure"] = devicev1alpha1.DesiredPropertyState{Name: "Pressure"}
+			},
+			errs: []string{"spec.deviceProperties[Pressure]"},
+		},
+		{
+			name: "colliding property names",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["temperature"] = devicev1alpha1.DesiredPropertyState{Name: "Temperature", DesiredValue: "30"}
+			},
+			errs: []string{"spec.deviceProperties[temperature].name"},
+		},
+		{
+			name: "read-only property",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Humidity"] = devicev1alpha1.DesiredPropertyState{Name: "Humidity", DesiredValue: "50"}
+			},
+			errs: []string{"spec.deviceProperties[Humidity].desiredValue: Forbidden"},
+		},
+		{
+			name: "value out of range",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Temperature"] = devicev1alpha1.DesiredPropertyState{Name: "Temperature", DesiredValue: "200"}
+			},
+			errs: []string{"greater than the maximum 125"},
+		},
+		{
+			name: "command value of wrong type",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["SetPoint"] = devicev1alpha1.DesiredPropertyState{Name: "SetPoint", DesiredValue: "warm"}
+			},
+			errs: []string{"not a valid Int16"},
+		},
+		{
+			name: "command parameters",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Climate"] = devicev1alpha1.DesiredPropertyState{Name: "Climate",
+					DesiredParameters: map[string]string{"Temperature": "20", "Humidity": "40.5"}}
+			},
+		},
+		{
+			name: "invalid command parameters",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Climate"] = devicev1alpha1.DesiredPropertyState{Name: "Climate", DesiredValue: "20",
+					DesiredParameters: map[string]string{"Temperature": "200", "Pressure": "1"}}
+			},
+			errs: []string{"spec.deviceProperties[Climate].desiredValue", "spec.deviceProperties[Climate].desiredParameters[Pressure]",
+				"greater than the maximum 125", "spec.deviceProperties[Climate].desiredParameters[Humidity]: Required"},
+		},
+		{
+			name: "missing command parameters",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Climate"] = devicev1alpha1.DesiredPropertyState{Name: "Climate",
+					DesiredParameters: map[string]string{"Temperature": "20"}}
+			},
+			errs: []string{"spec.deviceProperties[Climate].desiredParameters[Humidity]: Required"},
+		},
+		{
+			name: "parameters of a deviceResource",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Temperature"] = devicev1alpha1.DesiredPropertyState{Name: "Temperature",
+					DesiredParameters: map[string]string{"Temperature": "20"}}
+			},
+			errs: []string{"spec.deviceProperties[Temperature].desiredParameters: Forbidden"},
+		},
+		{
+			name: "negative refresh interval",
+			mutate: func(d *devicev1alpha1.Device) {
+				d.Spec.DeviceProperties["Humidity"] = devicev1alpha1.DesiredPropertyState{Name: "Humidity",
+					RefreshInterval: &metav1.Duration{Duration: -time.Minute}, MaxStaleness: &metav1.Duration{Duration: time.Minute}}
+			},
+			errs: []string{"spec.deviceProperties[Humidity].refreshInterval"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &devicev1alpha1.Device{
+				ObjectMeta: metav1.ObjectMeta{Name: "thermometer-01", Namespace: "default"},
+				Spec: devicev1alpha1.DeviceSpec{
+					NodePool: testNodePool,
+					Profile:  "Thermometer",
+					Service:  "device-virtual",
+					DeviceProperties: map[string]devicev1alpha1.DesiredPropertyState{
+						"Temperature": {Name: "Temperature", DesiredValue: "25"},
+						"Humidity":    {Name: "Humidity"},
+					},
+				},
+			}
+			tt.mutate(d)
+			allErrs, err := v.validateDevice(context.TODO(), d, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(allErrs) != len(tt.errs) {
+				t.Fatalf("expected %d errors, got %v", len(tt.errs), allErrs)
+			}
+			for i, e := range tt.errs {
+				if !strings.Contains(allErrs[i].Error(), e) {
+					t.Errorf("expected error %q to contain %q", allErrs[i].Error(), e)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDeviceProfile(t *testing.T) {
+	dp := &devicev1alpha1.DeviceProfile{
+		Spec: devicev1alpha1.DeviceProfileSpec{
+			DeviceResources: []devicev1alpha1.DeviceResource{
+				{Name: "Temperature", Properties: devicev1alpha1.ResourceProperties{ReadWrite: "RW", ValueType: "Int16"}},
+				{Name: "Mode", Properties: devicev1alpha1.ResourceProperties{ReadWrite: "X", ValueType: "Enum"}},
+			},
+			DeviceCommands: []devicev1alpha1.DeviceProfileCommand{
+				{Name: "SetPoint", ReadWrite: "RW", ResourceOperations: []devicev1alpha1.ResourceOperation{
+					{DeviceResource: "Temperature"}, {DeviceResource: "Pressure"},
+				}},
+			},
+		},
+	}
+	allErrs := validateDeviceProfile(dp)
+	expected := []string{
+		"spec.deviceResources[1].properties.valueType",
+		"spec.deviceResources[1].properties.readWrite",
+		"spec.deviceCommands[0].resourceOperations[1].deviceResource",
+	}
+	if len(allErrs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), allErrs)
+	}
+	for i, e := range expected {
+		if allErrs[i].Field != e {
+			t.Errorf("expected error of %s, got %v", e, allErrs[i])
+		}
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/mutate-device-openyurt-io-v1alpha1-deviceprofile,mutating=true,failurePolicy=fail,sideEffects=None,groups=device.openyurt.io,resources=deviceprofiles,verbs=create;update,versions=v1alpha1,name=mdeviceprofile.kb.io,admissionReviewVersions=v1
+
+// DeviceProfileDefaulter sets the defaults of the deviceProfiles created or updated on OpenYurt
+type DeviceProfileDefaulter struct {
+	// Reader reads the namespaces of the deviceProfiles, it should not be backed by the cache of the manager
+	Reader  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &DeviceProfileDefaulter{}
+var _ admission.DecoderInjector = &DeviceProfileDefaulter{}
+
+func (m *DeviceProfileDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var dp devicev1alpha1.DeviceProfile
+	if err := m.decoder.Decode(req, &dp); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !dp.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if err := defaultNodePool(ctx, m.Reader, &dp, &dp.Spec.NodePool); err != nil {
+		klog.V(4).ErrorS(err, "failed to default the nodePool of the deviceProfile", "DeviceProfileName", dp.GetName())
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	defaultEdgeXObjectName(&dp)
+
+	marshaled, err := json.Marshal(&dp)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+func (m *DeviceProfileDefaulter) InjectDecoder(d *admission.Decoder) error {
+	m.decoder = d
+	return nil
+}
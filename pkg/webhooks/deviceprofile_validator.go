@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"net/http"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-device-openyurt-io-v1alpha1-deviceprofile,mutating=false,failurePolicy=fail,sideEffects=None,groups=device.openyurt.io,resources=deviceprofiles,verbs=create;update,versions=v1alpha1,name=vdeviceprofile.kb.io,admissionReviewVersions=v1
+
+// DeviceProfileValidator validates the deviceProfiles created or updated on OpenYurt
+type DeviceProfileValidator struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &DeviceProfileValidator{}
+var _ admission.DecoderInjector = &DeviceProfileValidator{}
+
+func (v *DeviceProfileValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var dp devicev1alpha1.DeviceProfile
+	if err := v.decoder.Decode(req, &dp); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !dp.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+	if allErrs := validateDeviceProfile(&dp); len(allErrs) != 0 {
+		return admission.Denied(allErrs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+func (v *DeviceProfileValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// validateDeviceProfile checks the value types and permissions of the deviceResources,
+// and that the deviceCommands only operate on the deviceResources of the deviceProfile
+func validateDeviceProfile(dp *devicev1alpha1.DeviceProfile) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	resources := sets.NewString()
+	for i, dr := range dp.Spec.DeviceResources {
+		drPath := specPath.Child("deviceResources").Index(i)
+		if resources.Has(dr.Name) {
+			allErrs = append(allErrs, field.Duplicate(drPath.Child("name"), dr.Name))
+		}
+		resources.Insert(dr.Name)
+		if vt := dr.Properties.ValueType; vt != "" && !isValidValueType(vt) {
+			allErrs = append(allErrs, field.Invalid(drPath.Child("properties", "valueType"), vt, "unsupported value type"))
+		}
+		allErrs = append(allErrs, validateReadWrite(drPath.Child("properties", "readWrite"), dr.Properties.ReadWrite)...)
+	}
+
+	for i, dc := range dp.Spec.DeviceCommands {
+		dcPath := specPath.Child("deviceCommands").Index(i)
+		allErrs = append(allErrs, validateReadWrite(dcPath.Child("readWrite"), dc.ReadWrite)...)
+		for j, ro := range dc.ResourceOperations {
+			if !resources.Has(ro.DeviceResource) {
+				allErrs = append(allErrs, field.NotFound(dcPath.Child("resourceOperations").Index(j).Child("deviceResource"), ro.DeviceResource))
+			}
+		}
+	}
+	return allErrs
+}
+
+func validateReadWrite(fldPath *field.Path, readWrite string) field.ErrorList {
+	switch readWrite {
+	case "", "R", "W", "RW", "WR":
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(fldPath, readWrite, []string{"R", "W", "RW", "WR"})}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/mutate-device-openyurt-io-v1alpha1-deviceservice,mutating=true,failurePolicy=fail,sideEffects=None,groups=device.openyurt.io,resources=deviceservices,verbs=create;update,versions=v1alpha1,name=mdeviceservice.kb.io,admissionReviewVersions=v1
+
+// DeviceServiceDefaulter sets the defaults of the deviceServices created or updated on OpenYurt
+type DeviceServiceDefaulter struct {
+	// Reader reads the namespaces of the deviceServices, it should not be backed by the cache of the manager
+	Reader  client.Reader
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &DeviceServiceDefaulter{}
+var _ admission.DecoderInjector = &DeviceServiceDefaulter{}
+
+func (m *DeviceServiceDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var ds devicev1alpha1.DeviceService
+	if err := m.decoder.Decode(req, &ds); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !ds.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	if err := defaultNodePool(ctx, m.Reader, &ds, &ds.Spec.NodePool); err != nil {
+		klog.V(4).ErrorS(err, "failed to default the nodePool of the deviceService", "DeviceServiceName", ds.GetName())
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	defaultEdgeXObjectName(&ds)
+	if ds.Spec.AdminState == "" {
+		ds.Spec.AdminState = devicev1alpha1.UnLocked
+	}
+
+	marshaled, err := json.Marshal(&ds)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+func (m *DeviceServiceDefaulter) InjectDecoder(d *admission.Decoder) error {
+	m.decoder = d
+	return nil
+}
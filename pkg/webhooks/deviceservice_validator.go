@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"net/http"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+//+kubebuilder:webhook:path=/validate-device-openyurt-io-v1alpha1-deviceservice,mutating=false,failurePolicy=fail,sideEffects=None,groups=device.openyurt.io,resources=deviceservices,verbs=create;update,versions=v1alpha1,name=vdeviceservice.kb.io,admissionReviewVersions=v1
+
+// DeviceServiceValidator validates the deviceServices created or updated on OpenYurt
+type DeviceServiceValidator struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = &DeviceServiceValidator{}
+var _ admission.DecoderInjector = &DeviceServiceValidator{}
+
+func (v *DeviceServiceValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var ds devicev1alpha1.DeviceService
+	if err := v.decoder.Decode(req, &ds); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !ds.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+	if allErrs := validateAdminState(field.NewPath("spec", "adminState"), ds.Spec.AdminState); len(allErrs) != 0 {
+		return admission.Denied(allErrs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+func (v *DeviceServiceValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/controllers/util"
+)
+
+// isValidValueType checks whether the value type is supported by EdgeX,
+// the array types are the numeric, bool and string types suffixed with "Array"
+func isValidValueType(valueType string) bool {
+	vt := strings.ToLower(valueType)
+	if vt == "binary" || vt == "object" {
+		return true
+	}
+	vt = strings.TrimSuffix(vt, "array")
+	_, isInt := util.IntValueTypes[vt]
+	_, isUint := util.UintValueTypes[vt]
+	_, isFloat := util.FloatValueTypes[vt]
+	return isInt || isUint || isFloat || vt == "bool" || vt == "string"
+}
+
+// validateResourceValue checks that the value can be set to the deviceResource,
+// it must be parsable as the value type and must be between the minimum and maximum of the resource
+func validateResourceValue(dr *devicev1alpha1.DeviceResource, value string) error {
+	vt := strings.ToLower(dr.Properties.ValueType)
+	if strings.HasSuffix(vt, "array") {
+		elems, err := util.SplitArrayValue(value)
+		if err != nil {
+			return fmt.Errorf("value %s is not a valid %s", value, dr.Properties.ValueType)
+		}
+		for _, e := range elems {
+			if err := validateScalarValue(dr, strings.TrimSuffix(vt, "array"), e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return validateScalarValue(dr, vt, value)
+}
+
+func validateScalarValue(dr *devicev1alpha1.DeviceResource, vt, value string) error {
+	var number float64
+	if bits, ok := util.IntValueTypes[vt]; ok {
+		v, err := strconv.ParseInt(value, 10, bits)
+		if err != nil {
+			return fmt.Errorf("value %s is not a valid %s", value, dr.Properties.ValueType)
+		}
+		number = float64(v)
+	} else if bits, ok := util.UintValueTypes[vt]; ok {
+		v, err := strconv.ParseUint(value, 10, bits)
+		if err != nil {
+			return fmt.Errorf("value %s is not a valid %s", value, dr.Properties.ValueType)
+		}
+		number = float64(v)
+	} else if bits, ok := util.FloatValueTypes[vt]; ok {
+		v, err := strconv.ParseFloat(value, bits)
+		if err != nil {
+			return fmt.Errorf("value %s is not a valid %s", value, dr.Properties.ValueType)
+		}
+		number = v
+	} else {
+		switch vt {
+		case "bool":
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("value %s is not a valid %s", value, dr.Properties.ValueType)
+			}
+		case "object":
+			if !json.Valid([]byte(value)) {
+				return fmt.Errorf("value %s is not a valid %s", value, dr.Properties.ValueType)
+			}
+		}
+		return nil
+	}
+
+	if dr.Properties.Minimum != "" {
+		if min, err := strconv.ParseFloat(dr.Properties.Minimum, 64); err == nil && number < min {
+			return fmt.Errorf("value %s is less than the minimum %s", value, dr.Properties.Minimum)
+		}
+	}
+	if dr.Properties.Maximum != "" {
+		if max, err := strconv.ParseFloat(dr.Properties.Maximum, 64); err == nil && number > max {
+			return fmt.Errorf("value %s is greater than the maximum %s", value, dr.Properties.Maximum)
+		}
+	}
+	return nil
+}
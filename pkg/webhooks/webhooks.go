@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+
+	devicev1alpha1 "github.com/openyurtio/device-controller/apis/device.openyurt.io/v1alpha1"
+	"github.com/openyurtio/device-controller/pkg/controllers"
+	"github.com/openyurtio/device-controller/pkg/controllers/util"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	MutateDevicePath          = "/mutate-device-openyurt-io-v1alpha1-device"
+	MutateDeviceProfilePath   = "/mutate-device-openyurt-io-v1alpha1-deviceprofile"
+	MutateDeviceServicePath   = "/mutate-device-openyurt-io-v1alpha1-deviceservice"
+	ValidateDevicePath        = "/validate-device-openyurt-io-v1alpha1-device"
+	ValidateDeviceProfilePath = "/validate-device-openyurt-io-v1alpha1-deviceprofile"
+	ValidateDeviceServicePath = "/validate-device-openyurt-io-v1alpha1-deviceservice"
+)
+
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get
+
+// SetupWebhooksWithManager registers the admission webhooks of the device resources to the webhook server of the manager
+func SetupWebhooksWithManager(mgr ctrl.Manager) error {
+	server := mgr.GetWebhookServer()
+	server.Register(MutateDevicePath, &webhook.Admission{Handler: &DeviceDefaulter{Reader: mgr.GetAPIReader()}})
+	server.Register(MutateDeviceProfilePath, &webhook.Admission{Handler: &DeviceProfileDefaulter{Reader: mgr.GetAPIReader()}})
+	server.Register(MutateDeviceServicePath, &webhook.Admission{Handler: &DeviceServiceDefaulter{Reader: mgr.GetAPIReader()}})
+	server.Register(ValidateDevicePath, &webhook.Admission{Handler: &DeviceValidator{Client: mgr.GetClient()}})
+	server.Register(ValidateDeviceProfilePath, &webhook.Admission{Handler: &DeviceProfileValidator{}})
+	server.Register(ValidateDeviceServicePath, &webhook.Admission{Handler: &DeviceServiceValidator{}})
+	return nil
+}
+
+// defaultNodePool fills the empty nodePool of the object with the nodePool label of the object,
+// or the nodePool label of its namespace if the object has none
+func defaultNodePool(ctx context.Context, r client.Reader, obj client.Object, nodePool *string) error {
+	if *nodePool != "" {
+		return nil
+	}
+	if np, ok := obj.GetLabels()[util.NodePoolLabel]; ok {
+		*nodePool = np
+		return nil
+	}
+	var ns corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, &ns); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	*nodePool = ns.Labels[util.NodePoolLabel]
+	return nil
+}
+
+// defaultEdgeXObjectName labels the object with its name on the edge platform,
+// the objects created on OpenYurt have the same names on the edge platform
+func defaultEdgeXObjectName(obj client.Object) {
+	if obj.GetName() == "" {
+		return
+	}
+	labels := obj.GetLabels()
+	if _, ok := labels[controllers.EdgeXObjectName]; ok {
+		return
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[controllers.EdgeXObjectName] = obj.GetName()
+	obj.SetLabels(labels)
+}
+
+// findDeviceService returns the deviceService in the namespace and nodePool which has the given name on the edge platform
+func findDeviceService(ctx context.Context, c client.Client, namespace, nodePool, edgeName string) (*devicev1alpha1.DeviceService, error) {
+	var dsl devicev1alpha1.DeviceServiceList
+	if err := c.List(ctx, &dsl, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range dsl.Items {
+		ds := &dsl.Items[i]
+		if ds.Spec.NodePool == nodePool && util.GetEdgeDeviceServiceName(ds, controllers.EdgeXObjectName) == edgeName {
+			return ds, nil
+		}
+	}
+	return nil, nil
+}